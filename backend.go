@@ -0,0 +1,73 @@
+package gowsl
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/governor"
+	"github.com/ubuntu/gowsl/backend/logging"
+	"github.com/ubuntu/gowsl/internal/backend/windows"
+)
+
+type configQueryType int
+
+const configQuery configQueryType = iota
+
+// contextConfig is the configuration added to a context, along with the client built from it.
+// The client is built once per context, so that the distros created with the same context
+// share the same back-end, and are thus equal.
+type contextConfig struct {
+	opts   clientOptions
+	client *Client
+}
+
+// withConfig adds the configuration of the context, modified by f, to the context.
+func withConfig(ctx context.Context, f func(*clientOptions)) context.Context {
+	var opts clientOptions
+	if cfg, ok := ctx.Value(configQuery).(contextConfig); ok {
+		opts = cfg.opts
+	}
+	f(&opts)
+
+	return context.WithValue(ctx, configQuery, contextConfig{opts: opts, client: newClient(opts)})
+}
+
+// WithBackend adds a back-end to the context. GoWSL functions called with this context, and
+// distros created with it, use that back-end instead of the real WSL. Use backend.Chain
+// to decorate a back-end, the real one included:
+//
+//	b := backend.Chain(wsl.DefaultBackend(), recorder, dryRun)
+//	ctx = wsl.WithBackend(ctx, b)
+func WithBackend(ctx context.Context, b backend.Backend) context.Context {
+	return withConfig(ctx, func(o *clientOptions) { o.backend = b })
+}
+
+// DefaultBackend returns the back-end that GoWSL uses when none is added to the context,
+// which makes real calls to WSL.
+func DefaultBackend() backend.Backend {
+	return windows.Backend{}
+}
+
+// WithLogger adds a logger to the context. GoWSL functions called with this context, and distros
+// created with it, log every call they make to the back-end, along with the wsl.exe commands it
+// runs. See package logging for details, and for the options.
+func WithLogger(ctx context.Context, logger *slog.Logger, opts ...logging.Option) context.Context {
+	return withConfig(ctx, func(o *clientOptions) { o.logger = logging.Middleware(logger, opts...) })
+}
+
+// WithGovernor adds a governor to the context. GoWSL functions called with this context, and
+// distros created with it, run wsl.exe only when the governor allows it, and retry it when it
+// fails transiently. Share the same governor between contexts to limit all of them together.
+// It has no effect on the back-ends added with WithBackend.
+func WithGovernor(ctx context.Context, g *governor.Governor) context.Context {
+	return withConfig(ctx, func(o *clientOptions) { o.governor = g })
+}
+
+// clientFromContext returns the client with the configuration added to the context.
+func clientFromContext(ctx context.Context) *Client {
+	if cfg, ok := ctx.Value(configQuery).(contextConfig); ok {
+		return cfg.client
+	}
+	return newClient(clientOptions{})
+}
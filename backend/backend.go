@@ -0,0 +1,125 @@
+// Package backend defines all the actions that a back-end to GoWSL must
+// be able to perform in order to run, or otherwise mock WSL.
+//
+// GoWSL uses the real WSL by default. Use gowsl.WithBackend to plug in another
+// back-end, and Chain to decorate one back-end with others, for instance to
+// record or proxy the calls it receives.
+package backend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/ubuntu/gowsl/internal/flags"
+	"github.com/ubuntu/gowsl/internal/state"
+)
+
+// State is the state of a distro, as reported by Backend.State. See the
+// constants in GoWSL, such as gowsl.Running.
+type State = state.State
+
+// WslFlags are the flags of a distro's configuration, as in Win32's WSL_DISTRIBUTION_FLAGS.
+type WslFlags = flags.WslFlags
+
+// ErrNotExist is the error returned by all back-ends when a distro does not exist.
+var ErrNotExist = errors.New("distro does not exist")
+
+// ErrAbandoned is the error returned by back-ends that stop waiting for an operation that cannot
+// be cancelled, because its context is done. The operation may still complete in the background.
+var ErrAbandoned = errors.New("operation abandoned")
+
+// RegistryKey mocks a very small subset of behaviours of a Windows Registry key, enough
+// for GoWSL to do the limited amount of traversal and reading that it needs.
+type RegistryKey interface {
+	Close() error
+	Field(name string) (string, error)
+	SubkeyNames() ([]string, error)
+}
+
+// Backend defines what a back-end to GoWSL must be able to do or mock.
+//
+// All methods take a context. Back-ends return promptly once it is done, even when the operation
+// cannot be cancelled: they then return an error wrapping both ErrAbandoned and the context's
+// error. The context passed to WslLaunch only limits the launch: kill the process to stop it.
+type Backend interface {
+	// Registry
+	OpenLxssRegistry(ctx context.Context, path string) (RegistryKey, error)
+
+	// Appx management
+	RemoveAppxFamily(ctx context.Context, packageFamilyName string) error
+
+	// wsl.exe
+	State(ctx context.Context, distributionName string) (State, error)
+	Shutdown(ctx context.Context) error
+	Terminate(ctx context.Context, distroName string) error
+	SetAsDefault(ctx context.Context, distroName string) error
+	Install(ctx context.Context, appxName string) error
+	Import(ctx context.Context, distributionName, sourcePath, destinationPath string) error
+
+	// Win32
+	WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags WslFlags) error
+	WslGetDistributionConfiguration(ctx context.Context, distroName string, distributionVersion *uint8, defaultUID *uint32, wslDistributionFlags *WslFlags, defaultEnvironmentVariables *map[string]string) error
+	WslLaunch(ctx context.Context, distroName string, command string, useCWD bool, stdin *os.File, stdout *os.File, stderr *os.File) (*os.Process, error)
+	WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (uint32, error)
+	WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) error
+	WslUnregisterDistribution(ctx context.Context, distributionName string) error
+}
+
+// Exec describes a command that a back-end ran to do its job, such as wsl.exe.
+type Exec struct {
+	Args     []string      // Command line, starting with the executable
+	Stderr   string        // Standard error
+	ExitCode int           // Exit code, or -1 if the command could not start or was killed
+	Duration time.Duration // Time it took to run
+}
+
+// ExecReporter is implemented by back-ends that run commands to do their job, such as the
+// real one. WithExecHook returns a copy of the back-end that calls the hook after each
+// command, for instance to log it.
+type ExecReporter interface {
+	WithExecHook(hook func(Exec)) Backend
+}
+
+// RootSharer is implemented by back-ends that share the filesystem of their distros with the
+// host, such as the real one via \\wsl.localhost. GoWSL then accesses the files of a distro
+// directly, instead of running commands inside of it. Middlewares that decorate such back-ends
+// should implement it too, using ShareRoot.
+type RootSharer interface {
+	// ShareRoot returns the directory where the filesystem of the distro is shared, or false
+	// if it is not.
+	ShareRoot(distroName string) (root string, ok bool)
+}
+
+// ShareRoot returns the directory where the back-end shares the filesystem of the distro, if
+// it implements RootSharer.
+func ShareRoot(b Backend, distroName string) (root string, ok bool) {
+	s, ok := b.(RootSharer)
+	if !ok {
+		return "", false
+	}
+	return s.ShareRoot(distroName)
+}
+
+// Middleware decorates a back-end with additional behaviour. Middlewares usually embed
+// the back-end they receive, and override the methods they are interested in:
+//
+//	type dryRun struct{ backend.Backend }
+//
+//	func (dryRun) WslUnregisterDistribution(ctx context.Context, distributionName string) error {
+//		log.Printf("Would unregister %s", distributionName)
+//		return nil
+//	}
+//
+//	b = backend.Chain(b, func(b backend.Backend) backend.Backend { return dryRun{b} })
+type Middleware func(Backend) Backend
+
+// Chain decorates a back-end with the middlewares. The first middleware is the
+// outermost one, so it is the first to receive the calls.
+func Chain(b Backend, middlewares ...Middleware) Backend {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		b = middlewares[i](b)
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package backend_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/cassette"
+	"github.com/ubuntu/gowsl/backend/logging"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+// tracer is a middleware that records the calls to State that go through it.
+type tracer struct {
+	backend.Backend
+
+	name  string
+	trace *[]string
+}
+
+func (t tracer) State(ctx context.Context, distributionName string) (backend.State, error) {
+	*t.trace = append(*t.trace, t.name)
+	return t.Backend.State(ctx, distributionName)
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		middlewares []string
+
+		want []string
+	}{
+		"Success with no middlewares":                 {},
+		"Success with one middleware":                 {middlewares: []string{"A"}, want: []string{"A"}},
+		"Success with the first middleware outermost": {middlewares: []string{"A", "B", "C"}, want: []string{"A", "B", "C"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var trace []string
+			var middlewares []backend.Middleware
+			for _, n := range tc.middlewares {
+				middlewares = append(middlewares, func(b backend.Backend) backend.Backend {
+					return tracer{Backend: b, name: n, trace: &trace}
+				})
+			}
+
+			m := mock.New()
+			b := backend.Chain(m, middlewares...)
+
+			_, err := b.State(context.Background(), "Ubuntu")
+			require.NoError(t, err, "State should have succeeded")
+			require.Equal(t, tc.want, trace, "Middlewares should have been called in order")
+			require.Len(t, m.Calls("State"), 1, "The call should have reached the back-end")
+		})
+	}
+}
+
+// sharer is a back-end that shares the filesystem of its distros in a directory.
+type sharer struct {
+	backend.Backend
+}
+
+func (sharer) ShareRoot(distroName string) (string, bool) {
+	return "/shares/" + distroName, true
+}
+
+func TestShareRoot(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := map[string]struct {
+		backend backend.Backend
+
+		want   string
+		wantOk bool
+	}{
+		"Success with a back-end that shares its distros": {backend: sharer{mock.New()}, want: "/shares/Ubuntu", wantOk: true},
+		"Success through a logger":                        {backend: logging.New(sharer{mock.New()}, logger), want: "/shares/Ubuntu", wantOk: true},
+		"Success through a recorder":                      {backend: cassette.NewRecorder(sharer{mock.New()}), want: "/shares/Ubuntu", wantOk: true},
+		"Success through a chain of middlewares": {
+			backend: backend.Chain(sharer{mock.New()}, logging.Middleware(logger), func(b backend.Backend) backend.Backend { return cassette.NewRecorder(b) }),
+			want:    "/shares/Ubuntu", wantOk: true,
+		},
+
+		"Error with a back-end that does not share its distros": {backend: mock.New()},
+		"Error through a logger":                                {backend: logging.New(mock.New(), logger)},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			root, ok := backend.ShareRoot(tc.backend, "Ubuntu")
+			require.Equal(t, tc.wantOk, ok, "Unexpected result of ShareRoot")
+			require.Equal(t, tc.want, root, "Unexpected shared root")
+		})
+	}
+}
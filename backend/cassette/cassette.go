@@ -0,0 +1,140 @@
+// Package cassette records the calls made to a back-end, and replays them later without it. This
+// makes tests written against the real WSL deterministic, and lets them run where WSL is not
+// available, such as on Linux CI.
+//
+// Record the calls once, on Windows:
+//
+//	rec := cassette.NewRecorder(gowsl.DefaultBackend())
+//	ctx = gowsl.WithBackend(ctx, rec)
+//	... // Run the test
+//	err = rec.Cassette().Save("testdata/cassette.yaml")
+//
+// Then replay them anywhere:
+//
+//	c, err := cassette.Load("testdata/cassette.yaml")
+//	...
+//	ctx = gowsl.WithBackend(ctx, cassette.NewPlayer(c))
+//
+// Commands launched in a distro replay their output, but ignore their standard input. Sessions
+// are not supported: they frame their commands with random markers, which never match the
+// recorded ones, so the Player refuses to start them.
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/backend"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotRecorded is the error returned by a Player when it receives a call that matches
+// none of the interactions left in its cassette.
+var ErrNotRecorded = errors.New("call not recorded in the cassette")
+
+// Cassette is a sequence of interactions with a back-end. It can be serialised as YAML or JSON:
+//
+//	interactions:
+//	  - method: WslRegisterDistribution
+//	    args: [Ubuntu, C:\images\ubuntu.tar.gz]
+//	  - method: WslLaunch
+//	    args: [Ubuntu, echo Hello && echo Oops >&2, "false"]
+//	    output:
+//	      - stdout: |
+//	          Hello
+//	      - stderr: |
+//	          Oops
+//	  - method: State
+//	    args: [Debian]
+//	    error: distro does not exist
+//	    errorKind: not-exist
+type Cassette struct {
+	Interactions []Interaction `json:"interactions" yaml:"interactions"`
+}
+
+// Interaction is a call to a back-end, with its outcome. Only the results of the method
+// called are set. Calls to the methods of registry keys are recorded as RegistryKey.<Method>,
+// with the path of the key as their first argument.
+type Interaction struct {
+	Method string   `json:"method"         yaml:"method"`
+	Args   []string `json:"args,omitempty" yaml:"args,omitempty,flow"` // Arguments, except for contexts and files
+
+	Error     string `json:"error,omitempty"     yaml:"error,omitempty"`     // Message of the error returned, if any
+	ErrorKind string `json:"errorKind,omitempty" yaml:"errorKind,omitempty"` // Well-known error that it matched, such as backend.ErrNotExist
+
+	State      backend.State     `json:"state,omitempty"      yaml:"state,omitempty"`      // State
+	Version    uint8             `json:"version,omitempty"    yaml:"version,omitempty"`    // WslGetDistributionConfiguration
+	DefaultUID uint32            `json:"defaultUid,omitempty" yaml:"defaultUid,omitempty"` // WslGetDistributionConfiguration
+	Flags      backend.WslFlags  `json:"flags,omitempty"      yaml:"flags,omitempty"`      // WslGetDistributionConfiguration
+	Env        map[string]string `json:"env,omitempty"        yaml:"env,omitempty"`        // WslGetDistributionConfiguration
+	Value      string            `json:"value,omitempty"      yaml:"value,omitempty"`      // RegistryKey.Field
+	Names      []string          `json:"names,omitempty"      yaml:"names,omitempty"`      // RegistryKey.SubkeyNames
+	ExitCode   uint32            `json:"exitCode,omitempty"   yaml:"exitCode,omitempty"`   // WslLaunch and WslLaunchInteractive
+	Output     []Chunk           `json:"output,omitempty"     yaml:"output,omitempty"`     // WslLaunch, in the order in which it was written
+}
+
+// Chunk is a piece of the output of a command launched with WslLaunch. Only one of its
+// fields is set.
+type Chunk struct {
+	Stdout string `json:"stdout,omitempty" yaml:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty" yaml:"stderr,omitempty"`
+}
+
+// String returns a human-readable representation of the call.
+func (i Interaction) String() string {
+	args := make([]string, 0, len(i.Args))
+	for _, a := range i.Args {
+		args = append(args, fmt.Sprintf("%q", a))
+	}
+	return fmt.Sprintf("%s(%s)", i.Method, strings.Join(args, ", "))
+}
+
+// newInteraction describes a call to the method with these arguments.
+func newInteraction(method string, args ...any) Interaction {
+	i := Interaction{Method: method}
+	for _, a := range args {
+		i.Args = append(i.Args, fmt.Sprint(a))
+	}
+	return i
+}
+
+// Matcher reports whether a call to a Player matches an interaction recorded in the cassette.
+// The call only has its Method and Args set.
+type Matcher func(call, recorded Interaction) bool
+
+// ExactMatch is the default Matcher. It matches calls with the same method and arguments.
+func ExactMatch(call, recorded Interaction) bool {
+	return call.Method == recorded.Method && slices.Equal(call.Args, recorded.Args)
+}
+
+// Load reads a cassette from a YAML or JSON file.
+func Load(path string) (c Cassette, err error) {
+	defer decorate.OnError(&err, "could not load cassette")
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		return Cassette{}, err
+	}
+
+	if err := yaml.Unmarshal(out, &c); err != nil {
+		return Cassette{}, err
+	}
+
+	return c, nil
+}
+
+// Save writes the cassette to a YAML file.
+func (c Cassette) Save(path string) (err error) {
+	defer decorate.OnError(&err, "could not save cassette")
+
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, out, 0600)
+}
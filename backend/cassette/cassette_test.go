@@ -0,0 +1,250 @@
+package cassette_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/cassette"
+	"github.com/ubuntu/gowsl/gowsltest"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rootfs := filepath.Join(dir, "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(rootfs, nil, 0600), "Setup: could not write rootfs")
+	path := filepath.Join(dir, "cassette.yaml")
+
+	t.Run("Record", func(t *testing.T) {
+		rec := cassette.NewRecorder(mock.New())
+		gowsltest.RunConformance(t, rec, rootfs)
+		require.NoError(t, rec.Cassette().Save(path), "Save should have succeeded")
+	})
+
+	c, err := cassette.Load(path)
+	require.NoError(t, err, "Load should have succeeded")
+	require.NotEmpty(t, c.Interactions, "The cassette should not be empty")
+
+	// The distros are named after the test, so we rename them in the cassette.
+	rename := strings.NewReplacer("Record", "Replay")
+	for i := range c.Interactions {
+		for j := range c.Interactions[i].Args {
+			c.Interactions[i].Args[j] = rename.Replace(c.Interactions[i].Args[j])
+		}
+		for j := range c.Interactions[i].Names {
+			c.Interactions[i].Names[j] = rename.Replace(c.Interactions[i].Names[j])
+		}
+		c.Interactions[i].Value = rename.Replace(c.Interactions[i].Value)
+	}
+
+	t.Run("Replay", func(t *testing.T) {
+		p := cassette.NewPlayer(c)
+		gowsltest.RunConformance(t, p, rootfs)
+		require.Empty(t, p.Unplayed(), "All the recorded interactions should have been replayed")
+	})
+}
+
+func TestLaunch(t *testing.T) {
+	t.Parallel()
+
+	// Output that is not valid UTF-8 must be replayed byte for byte.
+	binary := "\x00\xff\xfe binary \x80"
+
+	m := mock.New()
+	require.NoError(t, m.WslRegisterDistribution(context.Background(), "Ubuntu", "rootfs.tar.gz"), "Setup: could not register distro")
+	m.Handle(mock.Exact("greet"), func(_ context.Context, _ io.Reader, stdout, stderr io.Writer) int {
+		_, _ = io.WriteString(stdout, "Hello, world!\n")
+		_, _ = io.WriteString(stderr, binary)
+		return 42
+	})
+	// WSL reports its own errors as HRESULTs, which do not fit in a Linux exit code.
+	m.Handle(mock.Exact("wsl-error"), func(context.Context, io.Reader, io.Writer, io.Writer) int {
+		return 0x8007019e
+	})
+
+	rec := cassette.NewRecorder(m)
+	stdout, stderr, exitCode := launch(t, rec, "greet")
+	require.Equal(t, "Hello, world!\n", stdout, "Standard output should have been forwarded while recording")
+	require.Equal(t, binary, stderr, "Standard error should have been forwarded while recording")
+	require.Equal(t, uint32(42), exitCode, "The exit code should have been forwarded while recording")
+	_, _, exitCode = launch(t, rec, "wsl-error")
+	require.Equal(t, uint32(0x8007019e), exitCode, "Exit codes above 255 should have been forwarded in full while recording")
+
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	require.NoError(t, rec.Cassette().Save(path), "Save should have succeeded")
+	c, err := cassette.Load(path)
+	require.NoError(t, err, "Load should have succeeded")
+
+	p := cassette.NewPlayer(c)
+	stdout, stderr, exitCode = launch(t, p, "greet")
+	require.Equal(t, "Hello, world!\n", stdout, "Standard output should have been replayed")
+	require.Equal(t, binary, stderr, "Standard error should have been replayed")
+	require.Equal(t, uint32(42), exitCode, "The exit code should have been replayed")
+	_, _, exitCode = launch(t, p, "wsl-error")
+	require.Equal(t, uint32(0x8007019e), exitCode, "Exit codes above 255 should have been replayed in full")
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	m := mock.New()
+	require.NoError(t, m.WslRegisterDistribution(context.Background(), "Ubuntu", "rootfs.tar.gz"), "Setup: could not register distro")
+
+	rec := cassette.NewRecorder(m)
+	wantState, err := rec.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "Setup: State should have succeeded")
+	err = rec.Terminate(context.Background(), "Debian")
+	require.ErrorIs(t, err, backend.ErrNotExist, "Setup: Terminate should have failed")
+	require.NoError(t, rec.WslRegisterDistribution(context.Background(), "Debian", "/recorded/rootfs.tar.gz"), "Setup: WslRegisterDistribution should have succeeded")
+	require.NoError(t, rec.Shutdown(context.Background()), "Setup: Shutdown should have succeeded")
+
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	require.NoError(t, rec.Cassette().Save(path), "Setup: could not save cassette")
+	c, err := cassette.Load(path)
+	require.NoError(t, err, "Load should have succeeded")
+
+	// The tarball is elsewhere when replaying.
+	p := cassette.NewPlayer(c, cassette.WithMatcher(func(call, recorded cassette.Interaction) bool {
+		if call.Method == "WslRegisterDistribution" {
+			return call.Method == recorded.Method && call.Args[0] == recorded.Args[0]
+		}
+		return cassette.ExactMatch(call, recorded)
+	}))
+
+	got, err := p.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have been replayed")
+	require.Equal(t, wantState, got, "The state should have been replayed")
+
+	err = p.Terminate(context.Background(), "Debian")
+	require.ErrorIs(t, err, backend.ErrNotExist, "Replayed errors should match the same well-known errors")
+
+	_, err = p.State(context.Background(), "Ubuntu")
+	require.ErrorIs(t, err, cassette.ErrNotRecorded, "Interactions should be replayed only once")
+
+	err = p.WslRegisterDistribution(context.Background(), "Debian", "/replayed/rootfs.tar.gz")
+	require.NoError(t, err, "Calls should be matched with the custom matcher")
+
+	err = p.WslUnregisterDistribution(context.Background(), "Ubuntu")
+	require.ErrorIs(t, err, cassette.ErrNotRecorded, "Calls that were not recorded should fail")
+
+	unplayed := p.Unplayed()
+	require.Len(t, unplayed, 1, "Shutdown should not have been replayed")
+	require.Equal(t, "Shutdown", unplayed[0].Method, "Shutdown should not have been replayed")
+}
+
+func TestReplayOutputOrder(t *testing.T) {
+	t.Parallel()
+
+	p := cassette.NewPlayer(cassette.Cassette{Interactions: []cassette.Interaction{{
+		Method: "WslLaunch",
+		Args:   []string{"Ubuntu", "interleave", "false"},
+		Output: []cassette.Chunk{{Stdout: "one\n"}, {Stderr: "two\n"}, {Stdout: "three\n"}},
+	}}})
+
+	stdinR, stdinW, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	defer stdinR.Close()
+	stdinW.Close()
+
+	// Standard output and error are written to the same pipe, as with 2>&1.
+	r, w, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	defer r.Close()
+
+	process, err := p.WslLaunch(context.Background(), "Ubuntu", "interleave", false, stdinR, w, w)
+	w.Close()
+	require.NoError(t, err, "WslLaunch should have succeeded")
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err, "Could not read the output")
+	_, err = process.Wait()
+	require.NoError(t, err, "Wait should have succeeded")
+
+	require.Equal(t, "one\ntwo\nthree\n", string(out), "The output should have been replayed in the order in which it was recorded")
+}
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	m := mock.New()
+	require.NoError(t, m.WslRegisterDistribution(context.Background(), "Ubuntu", "rootfs.tar.gz"), "Setup: could not register distro")
+
+	rec := cassette.NewRecorder(m)
+	ctx := wsl.WithBackend(context.Background(), rec)
+	d := wsl.NewDistro(ctx, "Ubuntu")
+
+	s, err := d.Session(ctx)
+	require.NoError(t, err, "Setup: Session should have succeeded while recording")
+	require.NoError(t, s.Command(ctx, "exit 0").Run(), "Setup: the command should have succeeded while recording")
+	require.NoError(t, s.Close(), "Setup: Close should have succeeded")
+
+	ctx = wsl.WithBackend(context.Background(), cassette.NewPlayer(rec.Cassette()))
+	d = wsl.NewDistro(ctx, "Ubuntu")
+
+	_, err = d.Session(ctx)
+	require.Error(t, err, "Session should have failed when replaying")
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	_, err := cassette.Load(filepath.Join(dir, "does-not-exist.yaml"))
+	require.ErrorIs(t, err, os.ErrNotExist, "Load should fail with missing files")
+
+	path := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("interactions: [[["), 0600), "Setup: could not write cassette")
+	_, err = cassette.Load(path)
+	require.Error(t, err, "Load should fail with invalid cassettes")
+}
+
+// launch runs a command in the Ubuntu distro, and returns its output and exit code.
+func launch(t *testing.T, b backend.Backend, command string) (stdout, stderr string, exitCode uint32) {
+	t.Helper()
+
+	stdinR, stdinW, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	defer stdinR.Close()
+	stdinW.Close()
+
+	stdoutR, stdoutW, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	defer stdoutR.Close()
+
+	stderrR, stderrW, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	defer stderrR.Close()
+
+	p, err := b.WslLaunch(context.Background(), "Ubuntu", command, false, stdinR, stdoutW, stderrW)
+	stdoutW.Close()
+	stderrW.Close()
+	require.NoError(t, err, "WslLaunch should have succeeded")
+
+	var errOut []byte
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		errOut, _ = io.ReadAll(stderrR)
+	}()
+
+	out, err := io.ReadAll(stdoutR)
+	require.NoError(t, err, "Could not read stdout")
+	<-done
+
+	_, exitCode, ok, err := stubprocess.Wait(p)
+	require.NoError(t, err, "Wait should have succeeded")
+	require.True(t, ok, "The exit code of the stub process should be known")
+
+	return string(out), string(errOut), exitCode
+}
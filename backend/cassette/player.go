@@ -0,0 +1,264 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/errkind"
+	"github.com/ubuntu/gowsl/internal/session"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+)
+
+// Player is a back-end that replays the interactions in a cassette. Every call is answered with
+// the first interaction left in the cassette that it matches, which is then used up. Calls that
+// match none of them fail with ErrNotRecorded.
+//
+// Contexts are ignored: the calls whose context was done when they were recorded replay
+// the same error, and the others succeed regardless.
+type Player struct {
+	match        Matcher
+	interactions []Interaction
+	played       []bool
+	mu           sync.Mutex
+}
+
+type options struct {
+	match Matcher
+}
+
+// Option is an optional parameter for NewPlayer.
+type Option func(*options)
+
+// WithMatcher sets how calls are matched to the recorded interactions, for instance to ignore
+// paths that change from one run to the next. It defaults to ExactMatch.
+func WithMatcher(m Matcher) Option {
+	return func(o *options) {
+		o.match = m
+	}
+}
+
+// NewPlayer creates a Player that replays the cassette.
+func NewPlayer(c Cassette, args ...Option) *Player {
+	opts := options{
+		match: ExactMatch,
+	}
+
+	for _, f := range args {
+		f(&opts)
+	}
+
+	return &Player{
+		match:        opts.match,
+		interactions: c.Interactions,
+		played:       make([]bool, len(c.Interactions)),
+	}
+}
+
+// Unplayed returns the interactions that no call has matched yet, in order. Check that
+// it is empty at the end of a test to ensure that it made all the recorded calls.
+func (p *Player) Unplayed() []Interaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var unplayed []Interaction
+	for i, played := range p.played {
+		if !played {
+			unplayed = append(unplayed, p.interactions[i])
+		}
+	}
+	return unplayed
+}
+
+// play finds the interaction that answers the call to the method, and returns it along
+// with the error it recorded, if any.
+func (p *Player) play(method string, args ...any) (Interaction, error) {
+	call := newInteraction(method, args...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, recorded := range p.interactions {
+		if p.played[i] || !p.match(call, recorded) {
+			continue
+		}
+		p.played[i] = true
+
+		if recorded.Error != "" {
+			return recorded, &errkind.Error{Message: recorded.Error, Kind: recorded.ErrorKind}
+		}
+		return recorded, nil
+	}
+
+	return Interaction{}, fmt.Errorf("%w: %s", ErrNotRecorded, call)
+}
+
+// OpenLxssRegistry opens a registry key at the chosen path.
+func (p *Player) OpenLxssRegistry(_ context.Context, path string) (backend.RegistryKey, error) {
+	if _, err := p.play("OpenLxssRegistry", path); err != nil {
+		return nil, err
+	}
+	return playedKey{player: p, path: path}, nil
+}
+
+// RemoveAppxFamily uninstalls the Appx under the provided family name.
+func (p *Player) RemoveAppxFamily(_ context.Context, packageFamilyName string) error {
+	_, err := p.play("RemoveAppxFamily", packageFamilyName)
+	return err
+}
+
+// State returns the state of a particular distro as seen in `wsl.exe -l -v`.
+func (p *Player) State(_ context.Context, distributionName string) (backend.State, error) {
+	i, err := p.play("State", distributionName)
+	return i.State, err
+}
+
+// Shutdown shuts down all distros.
+func (p *Player) Shutdown(_ context.Context) error {
+	_, err := p.play("Shutdown")
+	return err
+}
+
+// Terminate shuts down a particular distro.
+func (p *Player) Terminate(_ context.Context, distroName string) error {
+	_, err := p.play("Terminate", distroName)
+	return err
+}
+
+// SetAsDefault sets a particular distribution as the default one.
+func (p *Player) SetAsDefault(_ context.Context, distroName string) error {
+	_, err := p.play("SetAsDefault", distroName)
+	return err
+}
+
+// Install installs a new distro from the Windows store.
+func (p *Player) Install(_ context.Context, appxName string) error {
+	_, err := p.play("Install", appxName)
+	return err
+}
+
+// Import creates a new distro from a source root filesystem.
+func (p *Player) Import(_ context.Context, distributionName, sourcePath, destinationPath string) error {
+	_, err := p.play("Import", distributionName, sourcePath, destinationPath)
+	return err
+}
+
+// WslConfigureDistribution configures the default UID and flags of a distro.
+func (p *Player) WslConfigureDistribution(_ context.Context, distributionName string, defaultUID uint32, wslDistributionFlags backend.WslFlags) error {
+	_, err := p.play("WslConfigureDistribution", distributionName, defaultUID, wslDistributionFlags)
+	return err
+}
+
+// WslGetDistributionConfiguration gets the configuration of a distro.
+func (p *Player) WslGetDistributionConfiguration(_ context.Context, distroName string,
+	distributionVersion *uint8,
+	defaultUID *uint32,
+	wslDistributionFlags *backend.WslFlags,
+	defaultEnvironmentVariables *map[string]string) error {
+	i, err := p.play("WslGetDistributionConfiguration", distroName)
+	if err != nil {
+		return err
+	}
+
+	*distributionVersion = i.Version
+	*defaultUID = i.DefaultUID
+	*wslDistributionFlags = i.Flags
+	*defaultEnvironmentVariables = i.Env
+
+	return nil
+}
+
+// WslLaunchInteractive runs a command in the distro, attached to the terminal.
+func (p *Player) WslLaunchInteractive(_ context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (uint32, error) {
+	i, err := p.play("WslLaunchInteractive", distributionName, command, useCurrentWorkingDirectory)
+	if err != nil {
+		return math.MaxUint32, err
+	}
+	return i.ExitCode, nil
+}
+
+// WslRegisterDistribution registers a new distro.
+func (p *Player) WslRegisterDistribution(_ context.Context, distributionName string, tarGzFilename string) error {
+	_, err := p.play("WslRegisterDistribution", distributionName, tarGzFilename)
+	return err
+}
+
+// WslUnregisterDistribution unregisters a distro.
+func (p *Player) WslUnregisterDistribution(_ context.Context, distributionName string) error {
+	_, err := p.play("WslUnregisterDistribution", distributionName)
+	return err
+}
+
+// WslLaunch replays a command launched in the distro. The process returned writes the recorded
+// standard output and error, in the order in which they were recorded, and exits with the
+// recorded exit code. Its standard input is ignored, which is why sessions cannot be replayed.
+func (p *Player) WslLaunch(_ context.Context, distroName string, command string, useCWD bool, _ *os.File, stdout *os.File, stderr *os.File) (*os.Process, error) {
+	if command == session.Shell {
+		return nil, errors.New("sessions cannot be replayed")
+	}
+
+	i, err := p.play("WslLaunch", distroName, command, useCWD)
+	if err != nil {
+		return nil, err
+	}
+
+	// The caller may close its files as soon as we return.
+	outDup, err := stubprocess.Dup(stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	errDup, err := stubprocess.Dup(stderr)
+	if err != nil {
+		outDup.Close()
+		return nil, err
+	}
+
+	process, err := stubprocess.Start(func(context.Context) int {
+		defer outDup.Close()
+		defer errDup.Close()
+
+		for _, c := range i.Output {
+			_, _ = outDup.WriteString(c.Stdout)
+			_, _ = errDup.WriteString(c.Stderr)
+		}
+
+		//nolint:gosec // Exit codes above MaxInt32 wrap around, as they do on Windows.
+		return int(i.ExitCode)
+	})
+	if err != nil {
+		outDup.Close()
+		errDup.Close()
+		return nil, err
+	}
+
+	return process, nil
+}
+
+// playedKey is a registry key whose calls are replayed.
+type playedKey struct {
+	player *Player
+	path   string
+}
+
+// Close releases the key.
+func (k playedKey) Close() error {
+	_, err := k.player.play("RegistryKey.Close", k.path)
+	return err
+}
+
+// Field returns the value of a field of the key.
+func (k playedKey) Field(name string) (string, error) {
+	i, err := k.player.play("RegistryKey.Field", k.path, name)
+	return i.Value, err
+}
+
+// SubkeyNames returns the names of the subkeys of the key.
+func (k playedKey) SubkeyNames() ([]string, error) {
+	i, err := k.player.play("RegistryKey.SubkeyNames", k.path)
+	return i.Names, err
+}
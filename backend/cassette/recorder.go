@@ -0,0 +1,343 @@
+package cassette
+
+import (
+	"context"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/errkind"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+)
+
+// Recorder decorates a back-end, and records all the calls it receives into a cassette.
+// Use it as a middleware with backend.Chain:
+//
+//	var rec *cassette.Recorder
+//	b = backend.Chain(b, func(b backend.Backend) backend.Backend {
+//		rec = cassette.NewRecorder(b)
+//		return rec
+//	})
+type Recorder struct {
+	backend.Backend
+
+	interactions []Interaction
+	mu           sync.Mutex
+}
+
+// NewRecorder creates a Recorder that forwards all calls to the back-end.
+func NewRecorder(b backend.Backend) *Recorder {
+	return &Recorder{Backend: b}
+}
+
+// Cassette returns the interactions recorded so far, in the order in which the calls returned.
+// Calls to WslLaunch return when their process exits.
+func (r *Recorder) Cassette() Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return Cassette{Interactions: slices.Clone(r.interactions)}
+}
+
+// record adds the interaction to the cassette, along with the error returned, if any.
+func (r *Recorder) record(i Interaction, err error) {
+	if err != nil {
+		i.Error = err.Error()
+		i.ErrorKind = errkind.Of(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.interactions = append(r.interactions, i)
+}
+
+// OpenLxssRegistry opens a registry key at the chosen path.
+func (r *Recorder) OpenLxssRegistry(ctx context.Context, path string) (backend.RegistryKey, error) {
+	key, err := r.Backend.OpenLxssRegistry(ctx, path)
+	r.record(newInteraction("OpenLxssRegistry", path), err)
+	if err != nil {
+		return nil, err
+	}
+	return recordedKey{RegistryKey: key, recorder: r, path: path}, nil
+}
+
+// RemoveAppxFamily uninstalls the Appx under the provided family name.
+func (r *Recorder) RemoveAppxFamily(ctx context.Context, packageFamilyName string) error {
+	err := r.Backend.RemoveAppxFamily(ctx, packageFamilyName)
+	r.record(newInteraction("RemoveAppxFamily", packageFamilyName), err)
+	return err
+}
+
+// State returns the state of a particular distro as seen in `wsl.exe -l -v`.
+func (r *Recorder) State(ctx context.Context, distributionName string) (backend.State, error) {
+	s, err := r.Backend.State(ctx, distributionName)
+	i := newInteraction("State", distributionName)
+	i.State = s
+	r.record(i, err)
+	return s, err
+}
+
+// Shutdown shuts down all distros.
+func (r *Recorder) Shutdown(ctx context.Context) error {
+	err := r.Backend.Shutdown(ctx)
+	r.record(newInteraction("Shutdown"), err)
+	return err
+}
+
+// Terminate shuts down a particular distro.
+func (r *Recorder) Terminate(ctx context.Context, distroName string) error {
+	err := r.Backend.Terminate(ctx, distroName)
+	r.record(newInteraction("Terminate", distroName), err)
+	return err
+}
+
+// SetAsDefault sets a particular distribution as the default one.
+func (r *Recorder) SetAsDefault(ctx context.Context, distroName string) error {
+	err := r.Backend.SetAsDefault(ctx, distroName)
+	r.record(newInteraction("SetAsDefault", distroName), err)
+	return err
+}
+
+// Install installs a new distro from the Windows store.
+func (r *Recorder) Install(ctx context.Context, appxName string) error {
+	err := r.Backend.Install(ctx, appxName)
+	r.record(newInteraction("Install", appxName), err)
+	return err
+}
+
+// Import creates a new distro from a source root filesystem.
+func (r *Recorder) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) error {
+	err := r.Backend.Import(ctx, distributionName, sourcePath, destinationPath)
+	r.record(newInteraction("Import", distributionName, sourcePath, destinationPath), err)
+	return err
+}
+
+// WslConfigureDistribution configures the default UID and flags of a distro.
+func (r *Recorder) WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags backend.WslFlags) error {
+	err := r.Backend.WslConfigureDistribution(ctx, distributionName, defaultUID, wslDistributionFlags)
+	r.record(newInteraction("WslConfigureDistribution", distributionName, defaultUID, wslDistributionFlags), err)
+	return err
+}
+
+// WslGetDistributionConfiguration gets the configuration of a distro.
+func (r *Recorder) WslGetDistributionConfiguration(ctx context.Context, distroName string,
+	distributionVersion *uint8,
+	defaultUID *uint32,
+	wslDistributionFlags *backend.WslFlags,
+	defaultEnvironmentVariables *map[string]string) error {
+	err := r.Backend.WslGetDistributionConfiguration(ctx, distroName, distributionVersion, defaultUID, wslDistributionFlags, defaultEnvironmentVariables)
+
+	i := newInteraction("WslGetDistributionConfiguration", distroName)
+	if err == nil {
+		i.Version = *distributionVersion
+		i.DefaultUID = *defaultUID
+		i.Flags = *wslDistributionFlags
+		i.Env = *defaultEnvironmentVariables
+	}
+	r.record(i, err)
+
+	return err
+}
+
+// WslLaunchInteractive runs a command in the distro, attached to the terminal.
+func (r *Recorder) WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (uint32, error) {
+	exitCode, err := r.Backend.WslLaunchInteractive(ctx, distributionName, command, useCurrentWorkingDirectory)
+	i := newInteraction("WslLaunchInteractive", distributionName, command, useCurrentWorkingDirectory)
+	i.ExitCode = exitCode
+	r.record(i, err)
+	return exitCode, err
+}
+
+// WslRegisterDistribution registers a new distro.
+func (r *Recorder) WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) error {
+	err := r.Backend.WslRegisterDistribution(ctx, distributionName, tarGzFilename)
+	r.record(newInteraction("WslRegisterDistribution", distributionName, tarGzFilename), err)
+	return err
+}
+
+// WslUnregisterDistribution unregisters a distro.
+func (r *Recorder) WslUnregisterDistribution(ctx context.Context, distributionName string) error {
+	err := r.Backend.WslUnregisterDistribution(ctx, distributionName)
+	r.record(newInteraction("WslUnregisterDistribution", distributionName), err)
+	return err
+}
+
+// WslLaunch runs a command in the distro. Its standard output and error are recorded as they are
+// forwarded to the caller's files. The process returned is a stand-in for the real one: it exits
+// with the same exit code, and killing it kills the real process. The interaction is recorded
+// when the process exits.
+func (r *Recorder) WslLaunch(ctx context.Context, distroName string, command string, useCWD bool, stdin *os.File, stdout *os.File, stderr *os.File) (*os.Process, error) {
+	i := newInteraction("WslLaunch", distroName, command, useCWD)
+
+	// The caller may close its files as soon as we return.
+	var files []*os.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	for _, f := range []*os.File{stdout, stderr} {
+		dup, err := stubprocess.Dup(f)
+		if err != nil {
+			closeFiles()
+			return nil, err
+		}
+		files = append(files, dup)
+	}
+
+	// The real process writes into these pipes, which we copy into the caller's files.
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		closeFiles()
+		return nil, err
+	}
+	files = append(files, stdoutR)
+
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutW.Close()
+		closeFiles()
+		return nil, err
+	}
+	files = append(files, stderrR)
+
+	p, err := r.Backend.WslLaunch(ctx, distroName, command, useCWD, stdin, stdoutW, stderrW)
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		closeFiles()
+		r.record(i, err)
+		return nil, err
+	}
+
+	stand, err := stubprocess.Start(func(ctx context.Context) int {
+		defer closeFiles()
+
+		// Killing the stand-in kills the real process.
+		stop := context.AfterFunc(ctx, func() { _ = p.Kill() })
+		defer stop()
+
+		var out output
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			out.tee(files[0], files[2], false)
+		}()
+		go func() {
+			defer wg.Done()
+			out.tee(files[1], files[3], true)
+		}()
+
+		// Back-ends such as the mock launch stub processes, which truncate their exit code on Linux.
+		ps, full, ok, err := stubprocess.Wait(p)
+		wg.Wait()
+
+		i.Output = out.chunks
+		i.ExitCode = math.MaxUint32
+		if ok {
+			i.ExitCode = full
+		} else if err == nil && ps.ExitCode() >= 0 {
+			i.ExitCode = uint32(ps.ExitCode()) //nolint:gosec // Exit codes are never negative here.
+		}
+		r.record(i, nil)
+
+		//nolint:gosec // Exit codes above MaxInt32 wrap around, as they do on Windows.
+		return int(i.ExitCode)
+	})
+	if err != nil {
+		_ = p.Kill()
+		closeFiles()
+		return nil, err
+	}
+
+	return stand, nil
+}
+
+// ShareRoot returns the directory where the recorded back-end shares the filesystem of the
+// distro, if it does. Accessing it is not recorded.
+func (r *Recorder) ShareRoot(distroName string) (string, bool) {
+	return backend.ShareRoot(r.Backend, distroName)
+}
+
+// output is the output of a command, recorded in the order in which it is read.
+type output struct {
+	chunks []Chunk
+	mu     sync.Mutex
+}
+
+// tee copies everything from r into w, as long as it can be written to, and records it as
+// standard output or error.
+func (o *output) tee(w io.Writer, r io.Reader, stderr bool) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			o.write(buf[:n], stderr)
+		}
+		if w != nil && n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				// The caller stopped reading, but the output is still recorded.
+				w = nil
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write records a piece of the output, along with the previous one if it went to the same stream.
+func (o *output) write(b []byte, stderr bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	last := len(o.chunks) - 1
+	switch {
+	case last >= 0 && stderr && o.chunks[last].Stderr != "":
+		o.chunks[last].Stderr += string(b)
+	case last >= 0 && !stderr && o.chunks[last].Stdout != "":
+		o.chunks[last].Stdout += string(b)
+	case stderr:
+		o.chunks = append(o.chunks, Chunk{Stderr: string(b)})
+	default:
+		o.chunks = append(o.chunks, Chunk{Stdout: string(b)})
+	}
+}
+
+// recordedKey is a registry key whose calls are recorded.
+type recordedKey struct {
+	backend.RegistryKey
+	recorder *Recorder
+	path     string
+}
+
+// Close releases the key.
+func (k recordedKey) Close() error {
+	err := k.RegistryKey.Close()
+	k.recorder.record(newInteraction("RegistryKey.Close", k.path), err)
+	return err
+}
+
+// Field returns the value of a field of the key.
+func (k recordedKey) Field(name string) (string, error) {
+	value, err := k.RegistryKey.Field(name)
+	i := newInteraction("RegistryKey.Field", k.path, name)
+	i.Value = value
+	k.recorder.record(i, err)
+	return value, err
+}
+
+// SubkeyNames returns the names of the subkeys of the key.
+func (k recordedKey) SubkeyNames() ([]string, error) {
+	names, err := k.RegistryKey.SubkeyNames()
+	i := newInteraction("RegistryKey.SubkeyNames", k.path)
+	i.Names = names
+	k.recorder.record(i, err)
+	return names, err
+}
@@ -0,0 +1,223 @@
+// Package governor limits how many commands run at once, and retries those that fail
+// transiently. The real back-end uses it to keep parallel calls from overwhelming wsl.exe:
+//
+//	g := governor.New(governor.Policy{MaxConcurrent: 2, MaxAttempts: 3})
+//	ctx = gowsl.WithGovernor(ctx, g)
+//
+// Commands waiting for their turn are served in the order in which they arrived.
+package governor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTransient matches the errors that are worth retrying, such as timeouts of wsl.exe.
+var ErrTransient = errors.New("transient error")
+
+// Transient marks the error as transient, so that it matches ErrTransient. Its message is unchanged.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return transientError{err}
+}
+
+type transientError struct {
+	error
+}
+
+func (err transientError) Unwrap() error {
+	return err.error
+}
+
+func (transientError) Is(target error) bool {
+	return target == ErrTransient
+}
+
+// Policy configures a Governor. Zero values take their defaults.
+type Policy struct {
+	MaxConcurrent  int                   // Commands that run at once. Defaults to 1, which serialises them.
+	MaxAttempts    int                   // Attempts per command, retries included. Defaults to 1, which disables retries.
+	InitialBackoff time.Duration         // Time to wait before the first retry, doubled after each one. Defaults to 100ms.
+	MaxBackoff     time.Duration         // Longest time to wait before a retry. Defaults to 5s.
+	Retryable      func(err error) bool  // Reports whether a failed command is retried. Defaults to errors that match ErrTransient.
+	ObserveWait    func(d time.Duration) // Called with the time that each command waited for its turn, for instance to feed a histogram.
+}
+
+// Metrics are statistics about the commands run through a Governor.
+type Metrics struct {
+	Started   int           // Attempts that got their turn to run
+	Retries   int           // Attempts that were retries
+	Running   int           // Attempts running right now
+	Queued    int           // Attempts waiting for their turn right now
+	TotalWait time.Duration // Time that the started attempts waited for their turn, in total
+	MaxWait   time.Duration // Longest time that an attempt waited for its turn
+}
+
+// MeanWait returns the mean time that the started attempts waited for their turn.
+func (m Metrics) MeanWait() time.Duration {
+	if m.Started == 0 {
+		return 0
+	}
+	return m.TotalWait / time.Duration(m.Started)
+}
+
+// Governor limits how many commands run at once, and retries them according to its policy.
+// It is safe for concurrent use. Create it with New.
+type Governor struct {
+	policy Policy
+
+	queue   []chan struct{} // Attempts waiting for their turn, in order of arrival
+	metrics Metrics
+	mu      sync.Mutex
+}
+
+// New creates a Governor with the policy.
+func New(p Policy) *Governor {
+	if p.MaxConcurrent <= 0 {
+		p.MaxConcurrent = 1
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 5 * time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = func(err error) bool { return errors.Is(err, ErrTransient) }
+	}
+
+	return &Governor{policy: p}
+}
+
+// Metrics returns the statistics of the commands run so far.
+func (g *Governor) Metrics() Metrics {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m := g.metrics
+	m.Queued = len(g.queue)
+	return m
+}
+
+// Do runs f once it is its turn, and retries it with exponential backoff while it fails with
+// retryable errors, up to the maximum number of attempts. Retries wait for their turn again.
+// It returns the error of the last attempt, or that of the context if it is done first.
+func (g *Governor) Do(ctx context.Context, f func(context.Context) error) error {
+	backoff := g.policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		if err := g.acquire(ctx, attempt > 1); err != nil {
+			return err
+		}
+
+		err := f(ctx)
+		g.release()
+
+		if err == nil || attempt >= g.policy.MaxAttempts || !g.policy.Retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff = min(2*backoff, g.policy.MaxBackoff)
+	}
+}
+
+// Once runs f once it is its turn, without retrying it. Use it for commands that are not
+// idempotent, which may have had an effect even if they failed.
+func (g *Governor) Once(ctx context.Context, f func(context.Context) error) error {
+	if err := g.acquire(ctx, false); err != nil {
+		return err
+	}
+	defer g.release()
+
+	return f(ctx)
+}
+
+// acquire waits for a turn to run.
+func (g *Governor) acquire(ctx context.Context, retry bool) error {
+	start := time.Now()
+
+	g.mu.Lock()
+	if g.metrics.Running < g.policy.MaxConcurrent && len(g.queue) == 0 {
+		g.metrics.Running++
+		g.mu.Unlock()
+		g.started(0, retry)
+		return nil
+	}
+
+	turn := make(chan struct{})
+	g.queue = append(g.queue, turn)
+	g.mu.Unlock()
+
+	select {
+	case <-turn:
+		g.started(time.Since(start), retry)
+		return nil
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i, t := range g.queue {
+		if t == turn {
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			return ctx.Err()
+		}
+	}
+
+	// We were handed the turn in the meantime, so we pass it on.
+	g.releaseLocked()
+	return ctx.Err()
+}
+
+// started records that an attempt started after waiting for its turn.
+func (g *Governor) started(wait time.Duration, retry bool) {
+	g.mu.Lock()
+	g.metrics.Started++
+	if retry {
+		g.metrics.Retries++
+	}
+	g.metrics.TotalWait += wait
+	g.metrics.MaxWait = max(g.metrics.MaxWait, wait)
+	g.mu.Unlock()
+
+	if g.policy.ObserveWait != nil {
+		g.policy.ObserveWait(wait)
+	}
+}
+
+// release ends a turn, and hands it over to the next attempt in the queue, if any.
+func (g *Governor) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.releaseLocked()
+}
+
+func (g *Governor) releaseLocked() {
+	if len(g.queue) == 0 {
+		g.metrics.Running--
+		return
+	}
+
+	// The turn goes straight to the next in line, which still counts as running, so that
+	// newcomers cannot jump the queue.
+	next := g.queue[0]
+	g.queue = g.queue[1:]
+	close(next)
+}
@@ -0,0 +1,201 @@
+package governor_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/backend/governor"
+)
+
+func TestConcurrency(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		maxConcurrent int
+
+		want int
+	}{
+		"Commands are serialised by default":     {want: 1},
+		"Commands run up to the maximum at once": {maxConcurrent: 3, want: 3},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := governor.New(governor.Policy{MaxConcurrent: tc.maxConcurrent})
+
+			var running, peak atomic.Int32
+			var wg sync.WaitGroup
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := g.Do(context.Background(), func(context.Context) error {
+						n := running.Add(1)
+						defer running.Add(-1)
+						for {
+							p := peak.Load()
+							if n <= p || peak.CompareAndSwap(p, n) {
+								break
+							}
+						}
+						time.Sleep(5 * time.Millisecond)
+						return nil
+					})
+					require.NoError(t, err, "Do should have succeeded")
+				}()
+			}
+			wg.Wait()
+
+			require.EqualValues(t, tc.want, peak.Load(), "Unexpected number of commands running at once")
+
+			m := g.Metrics()
+			require.Equal(t, 20, m.Started, "All commands should have started")
+			require.Zero(t, m.Running, "No command should be running")
+			require.Zero(t, m.Queued, "No command should be queued")
+			require.Positive(t, m.MaxWait, "Commands should have waited for their turn")
+			require.Positive(t, m.MeanWait(), "Commands should have waited for their turn")
+		})
+	}
+}
+
+func TestFairness(t *testing.T) {
+	t.Parallel()
+
+	var waits []time.Duration
+	var mu sync.Mutex
+	g := governor.New(governor.Policy{ObserveWait: func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+	}})
+
+	// The first command holds the turn until all the others are queued.
+	release := make(chan struct{})
+	go func() {
+		_ = g.Do(context.Background(), func(context.Context) error {
+			<-release
+			return nil
+		})
+	}()
+	require.Eventually(t, func() bool { return g.Metrics().Running == 1 }, 10*time.Second, time.Millisecond, "Setup: the first command should have started")
+
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = g.Do(context.Background(), func(context.Context) error {
+				order = append(order, i)
+				return nil
+			})
+		}()
+		require.Eventually(t, func() bool { return g.Metrics().Queued == i+1 }, 10*time.Second, time.Millisecond, "Setup: command %d should have been queued", i)
+	}
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order, "Commands should run in the order in which they arrived")
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, waits, 11, "The wait of every command should have been observed")
+}
+
+func TestRetries(t *testing.T) {
+	t.Parallel()
+
+	errFailure := errors.New("failure")
+
+	testCases := map[string]struct {
+		err         error
+		maxAttempts int
+		retryable   func(error) bool
+		once        bool // Run the command via Once instead of Do
+
+		wantAttempts int
+		wantErr      bool
+	}{
+		"Transient errors are retried":             {err: governor.Transient(errFailure), maxAttempts: 3, wantAttempts: 3, wantErr: true},
+		"Errors are retried if the policy says so": {err: errFailure, maxAttempts: 3, retryable: func(error) bool { return true }, wantAttempts: 3, wantErr: true},
+		"Retries stop once the command succeeds":   {err: governor.Transient(errFailure), maxAttempts: 5, wantAttempts: 3},
+		"Other errors are not retried":             {err: errFailure, maxAttempts: 3, wantAttempts: 1, wantErr: true},
+		"Errors are not retried by default":        {err: governor.Transient(errFailure), wantAttempts: 1, wantErr: true},
+		"Commands run once are not retried":        {err: governor.Transient(errFailure), maxAttempts: 3, once: true, wantAttempts: 1, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := governor.New(governor.Policy{
+				MaxAttempts:    tc.maxAttempts,
+				InitialBackoff: time.Millisecond,
+				Retryable:      tc.retryable,
+			})
+
+			do := g.Do
+			if tc.once {
+				do = g.Once
+			}
+
+			var attempts int
+			err := do(context.Background(), func(context.Context) error {
+				attempts++
+				if !tc.wantErr && attempts == tc.wantAttempts {
+					return nil
+				}
+				return tc.err
+			})
+
+			require.Equal(t, tc.wantAttempts, attempts, "Unexpected number of attempts")
+			require.Equal(t, tc.wantAttempts-1, g.Metrics().Retries, "Unexpected number of retries")
+			if !tc.wantErr {
+				require.NoError(t, err, "Do should have succeeded")
+				return
+			}
+			require.ErrorIs(t, err, errFailure, "Do should have returned the error of the last attempt")
+			require.Equal(t, errFailure.Error(), err.Error(), "Transient errors should keep their message")
+		})
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	t.Parallel()
+
+	g := governor.New(governor.Policy{})
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = g.Do(context.Background(), func(context.Context) error {
+			<-release
+			return nil
+		})
+	}()
+	require.Eventually(t, func() bool { return g.Metrics().Running == 1 }, 10*time.Second, time.Millisecond, "Setup: the first command should have started")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := g.Do(ctx, func(context.Context) error {
+		require.Fail(t, "Commands should not run once their context is done")
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded, "Do should fail when the context is done before its turn")
+	require.Zero(t, g.Metrics().Queued, "Cancelled commands should leave the queue")
+
+	close(release)
+	<-done
+
+	require.NoError(t, g.Do(context.Background(), func(context.Context) error { return nil }), "The governor should still work")
+}
@@ -0,0 +1,21 @@
+// Package local is a back-end to GoWSL that runs distros on a Linux host, so that code written
+// against GoWSL can be exercised end-to-end without Windows.
+//
+// Each distro is the root filesystem extracted from its tarball, and its commands are chrooted into
+// it, in user and mount namespaces so that no privileges are needed. The metadata that WSL keeps in
+// the registry is kept in a JSON file instead, so that distros outlive the process that registered
+// them:
+//
+//	b, err := local.New(filepath.Join(os.Getenv("HOME"), ".local/share/gowsl"))
+//	...
+//	ctx = gowsl.WithBackend(ctx, b)
+//
+// The differences with WSL are:
+//   - Commands run as root in their user namespace, whatever the default UID of the distro.
+//   - Distros are only reported as Running because of the processes started by the same Backend.
+//   - There is no Microsoft Store, so Install and RemoveAppxFamily fail with errors.ErrUnsupported.
+//   - Every call can be cancelled, so none is abandoned: registrations are rolled back, and
+//     interactive commands are killed.
+//
+// This package only builds on Linux.
+package local
@@ -0,0 +1,504 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/rootfs"
+	"github.com/ubuntu/gowsl/internal/state"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+)
+
+// defaultEnvironment is the default environment of new distros.
+var defaultEnvironment = map[string]string{
+	"HOSTTYPE": "x86_64",
+	"LANG":     "en_US.UTF-8",
+	"PATH":     "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/usr/games:/usr/local/games",
+	"TERM":     "xterm-256color",
+}
+
+// validName matches the names that WSL accepts for distros.
+var validName = regexp.MustCompile(`^[A-Za-z0-9-_\.]+$`)
+
+// Backend runs distros on the host. Create it with New.
+type Backend struct {
+	dir         string
+	idleTimeout time.Duration
+
+	// Processes running in each distro, and the time when the last one exited, by GUID.
+	processes   map[string]map[*process]struct{}
+	lastExit    map[string]time.Time
+	processesMu sync.Mutex
+}
+
+// DefaultIdleTimeout is the time after which WSL terminates a distro that has
+// no processes left.
+const DefaultIdleTimeout = 8 * time.Second
+
+type options struct {
+	idleTimeout time.Duration
+}
+
+// Option is an optional parameter for New.
+type Option func(*options)
+
+// WithIdleTimeout sets the time after which distros with no processes left are reported
+// as Stopped. It defaults to DefaultIdleTimeout.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = timeout
+	}
+}
+
+// process is a command running in a distro.
+type process struct {
+	cmd  *exec.Cmd
+	done chan struct{} // Closed when the command exits
+}
+
+// kill kills the command, along with the processes it started.
+func (p *process) kill() {
+	// The command leads its own process group.
+	_ = syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// New creates a back-end that keeps its distros in dir, which is created if needed. Back-ends
+// in the same directory, even in different processes, share the same distros.
+func New(dir string, args ...Option) (b *Backend, err error) {
+	defer decorate.OnError(&err, "could not create local back-end")
+
+	opts := options{
+		idleTimeout: DefaultIdleTimeout,
+	}
+
+	for _, f := range args {
+		f(&opts)
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &Backend{
+		dir:         dir,
+		idleTimeout: opts.idleTimeout,
+		processes:   make(map[string]map[*process]struct{}),
+		lastExit:    make(map[string]time.Time),
+	}, nil
+}
+
+// RemoveAppxFamily fails: there is no Microsoft Store on Linux.
+func (b *Backend) RemoveAppxFamily(context.Context, string) error {
+	return fmt.Errorf("local back-end: RemoveAppxFamily: %w", errors.ErrUnsupported)
+}
+
+// Install fails: there is no Microsoft Store on Linux.
+func (b *Backend) Install(context.Context, string) error {
+	return fmt.Errorf("local back-end: Install: %w", errors.ErrUnsupported)
+}
+
+// State returns the state of a particular distro. As in WSL, distros are Running while processes
+// run in them, and until they have been idle for the idle timeout. Only the processes started by
+// this back-end count.
+func (b *Backend) State(ctx context.Context, distributionName string) (s state.State, err error) {
+	defer decorate.OnError(&err, "local back-end: State")
+
+	st, err := b.read(ctx)
+	if err != nil {
+		return state.Error, err
+	}
+
+	GUID, _, err := st.find(distributionName)
+	if errors.Is(err, backend.ErrNotExist) {
+		return state.NotRegistered, nil
+	}
+
+	b.processesMu.Lock()
+	defer b.processesMu.Unlock()
+
+	if len(b.processes[GUID]) > 0 {
+		return state.Running, nil
+	}
+	if last, ok := b.lastExit[GUID]; ok && time.Since(last) < b.idleTimeout {
+		return state.Running, nil
+	}
+	return state.Stopped, nil
+}
+
+// Shutdown kills all processes in all distros.
+func (b *Backend) Shutdown(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.processesMu.Lock()
+	GUIDs := make([]string, 0, len(b.processes)+len(b.lastExit))
+	for GUID := range b.processes {
+		GUIDs = append(GUIDs, GUID)
+	}
+	for GUID := range b.lastExit {
+		GUIDs = append(GUIDs, GUID)
+	}
+	b.processesMu.Unlock()
+
+	for _, GUID := range GUIDs {
+		b.terminate(GUID)
+	}
+
+	return nil
+}
+
+// Terminate kills all processes in a particular distro.
+func (b *Backend) Terminate(ctx context.Context, distroName string) (err error) {
+	defer decorate.OnError(&err, "local back-end: Terminate")
+
+	s, err := b.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	GUID, _, err := s.find(distroName)
+	if err != nil {
+		return err
+	}
+
+	b.terminate(GUID)
+	return nil
+}
+
+// terminate kills all processes in the distro, and waits for them to exit.
+func (b *Backend) terminate(GUID string) {
+	b.processesMu.Lock()
+	var processes []*process
+	for p := range b.processes[GUID] {
+		processes = append(processes, p)
+	}
+	b.processesMu.Unlock()
+
+	for _, p := range processes {
+		p.kill()
+	}
+	for _, p := range processes {
+		<-p.done
+	}
+
+	b.processesMu.Lock()
+	delete(b.lastExit, GUID)
+	b.processesMu.Unlock()
+}
+
+// SetAsDefault sets a particular distribution as the default one.
+func (b *Backend) SetAsDefault(ctx context.Context, distroName string) (err error) {
+	defer decorate.OnError(&err, "local back-end: SetAsDefault")
+
+	return b.update(ctx, func(s *store) error {
+		GUID, _, err := s.find(distroName)
+		if err != nil {
+			return err
+		}
+		s.DefaultDistribution = GUID
+		return nil
+	})
+}
+
+// Import creates a new distro from a tarball, whose root filesystem is kept in destinationPath.
+// It fails if destinationPath already holds a root filesystem.
+func (b *Backend) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) (err error) {
+	defer decorate.OnError(&err, "local back-end: Import")
+
+	destinationPath, err = filepath.Abs(destinationPath)
+	if err != nil {
+		return err
+	}
+
+	return b.register(ctx, distributionName, sourcePath, destinationPath)
+}
+
+// WslRegisterDistribution registers a new distro from a tarball, whose root filesystem is kept in
+// the back-end's directory.
+func (b *Backend) WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) (err error) {
+	defer decorate.OnError(&err, "local back-end: WslRegisterDistribution")
+
+	return b.register(ctx, distributionName, tarGzFilename, "")
+}
+
+// register extracts the tarball into basePath, or into a new directory in the back-end's
+// directory if it is empty, and adds the distro to the metadata.
+func (b *Backend) register(ctx context.Context, distributionName, tarball, basePath string) error {
+	if !validName.MatchString(distributionName) {
+		return errors.New("name contains invalid characters")
+	}
+
+	if s, err := b.read(ctx); err != nil {
+		return err
+	} else if _, _, err := s.find(distributionName); err == nil {
+		return errors.New("distro already exists")
+	}
+
+	GUID, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("could not generate UUID: %v", err)
+	}
+	guidStr := fmt.Sprintf("{%s}", GUID)
+
+	if basePath == "" {
+		basePath = filepath.Join(b.dir, GUID.String())
+	}
+
+	d := &distro{
+		Name:       distributionName,
+		BasePath:   basePath,
+		DefaultUID: 0,
+		Flags:      backend.WslFlags(0xf),
+		Env:        defaultEnvironment,
+	}
+
+	// Extracting the tarball can take a while, so we do it before locking the metadata.
+	if err := os.MkdirAll(d.BasePath, 0700); err != nil {
+		return fmt.Errorf("could not create root filesystem: %v", err)
+	}
+
+	// The root filesystem is removed if registering fails, so it must not hold anything else.
+	if err := os.Mkdir(d.root(), 0700); errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("could not create root filesystem: %q already exists", d.root())
+	} else if err != nil {
+		return fmt.Errorf("could not create root filesystem: %v", err)
+	}
+
+	err = rootfs.Extract(d.root(), tarball)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = b.update(ctx, func(s *store) error {
+			if _, _, err := s.find(distributionName); err == nil {
+				return errors.New("distro already exists")
+			}
+
+			s.Distros[guidStr] = d
+			if s.DefaultDistribution == "" {
+				s.DefaultDistribution = guidStr
+			}
+			return nil
+		})
+	}
+
+	if err != nil {
+		_ = b.removeRoot(d)
+		return err
+	}
+
+	return nil
+}
+
+// WslUnregisterDistribution kills all processes in the distro, and removes it along with its root filesystem.
+func (b *Backend) WslUnregisterDistribution(ctx context.Context, distributionName string) (err error) {
+	defer decorate.OnError(&err, "local back-end: WslUnregisterDistribution")
+
+	var GUID string
+	var d *distro
+	err = b.update(ctx, func(s *store) (err error) {
+		GUID, d, err = s.find(distributionName)
+		if err != nil {
+			return err
+		}
+		s.remove(GUID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.terminate(GUID)
+	return b.removeRoot(d)
+}
+
+// removeRoot removes the root filesystem of the distro, as well as its base path if
+// it is in the back-end's directory.
+func (b *Backend) removeRoot(d *distro) error {
+	dir := d.root()
+	if filepath.Dir(d.BasePath) == b.dir {
+		dir = d.BasePath
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("could not remove root filesystem: %v", err)
+	}
+	return nil
+}
+
+// WslConfigureDistribution configures the default UID and flags of a distro.
+func (b *Backend) WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags backend.WslFlags) (err error) {
+	defer decorate.OnError(&err, "local back-end: WslConfigureDistribution")
+
+	return b.update(ctx, func(s *store) error {
+		_, d, err := s.find(distributionName)
+		if err != nil {
+			return err
+		}
+		d.DefaultUID = defaultUID
+		d.Flags = wslDistributionFlags
+		return nil
+	})
+}
+
+// WslGetDistributionConfiguration gets the configuration of a distro. Its version is always 2.
+func (b *Backend) WslGetDistributionConfiguration(ctx context.Context, distroName string,
+	distributionVersion *uint8,
+	defaultUID *uint32,
+	wslDistributionFlags *backend.WslFlags,
+	defaultEnvironmentVariables *map[string]string) (err error) {
+	defer decorate.OnError(&err, "local back-end: WslGetDistributionConfiguration")
+
+	s, err := b.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, d, err := s.find(distroName)
+	if err != nil {
+		return err
+	}
+
+	*distributionVersion = 2
+	*defaultUID = d.DefaultUID
+	*wslDistributionFlags = d.Flags
+	*defaultEnvironmentVariables = d.Env
+
+	return nil
+}
+
+// WslLaunchInteractive runs a command in the distro, attached to the terminal. The command is
+// killed if the context is done before it exits.
+func (b *Backend) WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (exitCode uint32, err error) {
+	defer decorate.OnError(&err, "local back-end: WslLaunchInteractive")
+
+	p, err := b.start(ctx, distributionName, command, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		return math.MaxUint32, err
+	}
+
+	// Unlike in WSL, the command can be killed when the context is done.
+	stop := context.AfterFunc(ctx, p.kill)
+	defer stop()
+
+	<-p.done
+	if err := ctx.Err(); err != nil {
+		return math.MaxUint32, err
+	}
+
+	code := p.cmd.ProcessState.ExitCode()
+	if code < 0 {
+		return math.MaxUint32, nil
+	}
+	return uint32(code), nil //nolint:gosec // Exit codes are never negative here.
+}
+
+// WslLaunch runs a command in the distro. The process returned is a stand-in for the real one: it
+// exits with the same exit code, and killing it kills the command. Commands always start in the
+// home directory of the distro, as the current working directory is not part of its filesystem.
+func (b *Backend) WslLaunch(ctx context.Context, distroName string, command string, useCWD bool, stdin *os.File, stdout *os.File, stderr *os.File) (_ *os.Process, err error) {
+	defer decorate.OnError(&err, "local back-end: WslLaunch")
+
+	p, err := b.start(ctx, distroName, command, stdin, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	stand, err := stubprocess.Start(func(ctx context.Context) int {
+		// Killing the stand-in kills the command.
+		stop := context.AfterFunc(ctx, p.kill)
+		defer stop()
+
+		<-p.done
+		return p.cmd.ProcessState.ExitCode()
+	})
+	if err != nil {
+		p.kill()
+		return nil, err
+	}
+
+	return stand, nil
+}
+
+// start starts a command in the distro, which is tracked until it exits.
+func (b *Backend) start(ctx context.Context, distroName, command string, stdin, stdout, stderr *os.File) (*process, error) {
+	s, err := b.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	GUID, d, err := s.find(distroName)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := rootfs.Command(context.Background(), d.root(), command)
+	cmd.Env = environment(d.Env)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr.Setpgid = true
+
+	p := &process{cmd: cmd, done: make(chan struct{})}
+
+	// The command starts with the lock held, so that Terminate cannot miss it.
+	b.processesMu.Lock()
+	defer b.processesMu.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start command: %v", err)
+	}
+
+	if b.processes[GUID] == nil {
+		b.processes[GUID] = make(map[*process]struct{})
+	}
+	b.processes[GUID][p] = struct{}{}
+
+	go func() {
+		_ = cmd.Wait()
+
+		b.processesMu.Lock()
+		delete(b.processes[GUID], p)
+		if len(b.processes[GUID]) == 0 {
+			delete(b.processes, GUID)
+		}
+		b.lastExit[GUID] = time.Now()
+		b.processesMu.Unlock()
+
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// environment returns the environment of the commands in a distro.
+func environment(env map[string]string) []string {
+	vars := []string{"HOME=/root"}
+	for k, v := range env {
+		if k == "HOME" {
+			continue
+		}
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return vars
+}
@@ -0,0 +1,221 @@
+package local_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/local"
+	"github.com/ubuntu/gowsl/gowsltest"
+	"github.com/ubuntu/gowsl/internal/state"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	b, err := local.New(t.TempDir())
+	require.NoError(t, err, "Setup: could not create back-end")
+
+	gowsltest.RunConformance(t, b, emptyRootfs(t))
+}
+
+func TestDistro(t *testing.T) {
+	t.Parallel()
+
+	b, err := local.New(t.TempDir())
+	require.NoError(t, err, "Setup: could not create back-end")
+	ctx := wsl.WithBackend(context.Background(), b)
+
+	d := wsl.NewDistro(ctx, "Ubuntu")
+	require.NoError(t, d.Register(ctx, emptyRootfs(t)), "Register should have succeeded")
+
+	out, err := d.Command(ctx, "echo Hello from $HOME").Output()
+	require.NoError(t, err, "Command should have succeeded")
+	require.Equal(t, "Hello from /root\n", string(out), "Unexpected output")
+
+	s, err := d.State(ctx)
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, wsl.Running, s, "The distro should be running after a command")
+
+	require.NoError(t, d.Terminate(ctx), "Terminate should have succeeded")
+	require.NoError(t, d.Unregister(ctx), "Unregister should have succeeded")
+
+	registered, err := d.IsRegistered(ctx)
+	require.NoError(t, err, "IsRegistered should have succeeded")
+	require.False(t, registered, "The distro should not be registered after unregistering it")
+}
+
+func TestPersistence(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	b, err := local.New(dir)
+	require.NoError(t, err, "Setup: could not create back-end")
+	require.NoError(t, b.WslRegisterDistribution(context.Background(), "Ubuntu", emptyRootfs(t)), "Setup: could not register distro")
+	require.NoError(t, b.WslConfigureDistribution(context.Background(), "Ubuntu", 1000, 0x7), "Setup: could not configure distro")
+
+	other, err := local.New(dir)
+	require.NoError(t, err, "Setup: could not create second back-end")
+
+	s, err := other.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, state.Stopped, s, "Distros should be shared by back-ends in the same directory")
+
+	var (
+		version  uint8
+		uid      uint32
+		wslFlags backend.WslFlags
+		env      map[string]string
+	)
+	err = other.WslGetDistributionConfiguration(context.Background(), "Ubuntu", &version, &uid, &wslFlags, &env)
+	require.NoError(t, err, "WslGetDistributionConfiguration should have succeeded")
+	require.Equal(t, uint32(1000), uid, "The configuration should be shared by back-ends in the same directory")
+	require.Equal(t, backend.WslFlags(0x7), wslFlags, "The configuration should be shared by back-ends in the same directory")
+
+	require.NoError(t, other.WslUnregisterDistribution(context.Background(), "Ubuntu"), "WslUnregisterDistribution should have succeeded")
+	s, err = b.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, state.NotRegistered, s, "Unregistered distros should be gone for all back-ends in the same directory")
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	b, err := local.New(t.TempDir())
+	require.NoError(t, err, "Setup: could not create back-end")
+
+	dest := t.TempDir()
+	require.NoError(t, b.Import(context.Background(), "Ubuntu", emptyRootfs(t), dest), "Import should have succeeded")
+	require.DirExists(t, filepath.Join(dest, "rootfs"), "The root filesystem should be in the destination")
+
+	stdout, exitCode := launch(t, b, "Ubuntu", "echo Hello > /hello && cat /hello")
+	require.Equal(t, 0, exitCode, "The command should have succeeded")
+	require.Equal(t, "Hello\n", stdout, "Unexpected output")
+	require.FileExists(t, filepath.Join(dest, "rootfs", "hello"), "Commands should write inside the root filesystem")
+
+	require.NoError(t, b.WslUnregisterDistribution(context.Background(), "Ubuntu"), "WslUnregisterDistribution should have succeeded")
+	require.NoDirExists(t, filepath.Join(dest, "rootfs"), "The root filesystem should be removed with the distro")
+	require.DirExists(t, dest, "The destination should not be removed")
+}
+
+func TestImportExistingRoot(t *testing.T) {
+	t.Parallel()
+
+	b, err := local.New(t.TempDir())
+	require.NoError(t, err, "Setup: could not create back-end")
+
+	dest := t.TempDir()
+	keep := filepath.Join(dest, "rootfs", "keep")
+	require.NoError(t, os.MkdirAll(filepath.Dir(keep), 0700), "Setup: could not create existing root filesystem")
+	require.NoError(t, os.WriteFile(keep, nil, 0600), "Setup: could not write file in existing root filesystem")
+
+	err = b.Import(context.Background(), "Ubuntu", emptyRootfs(t), dest)
+	require.Error(t, err, "Import should have failed with an existing root filesystem")
+	require.FileExists(t, keep, "The existing root filesystem should have been left alone")
+
+	s, err := b.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, state.NotRegistered, s, "The distro should not have been registered")
+}
+
+func TestTerminate(t *testing.T) {
+	t.Parallel()
+
+	b, err := local.New(t.TempDir())
+	require.NoError(t, err, "Setup: could not create back-end")
+	require.NoError(t, b.WslRegisterDistribution(context.Background(), "Ubuntu", emptyRootfs(t)), "Setup: could not register distro")
+
+	stdin, _ := pipe(t)
+	_, stdout := pipe(t)
+	_, stderr := pipe(t)
+
+	p, err := b.WslLaunch(context.Background(), "Ubuntu", "sleep infinity", false, stdin, stdout, stderr)
+	require.NoError(t, err, "WslLaunch should have succeeded")
+
+	s, err := b.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, state.Running, s, "Distros should be running while a command runs in them")
+
+	require.NoError(t, b.Terminate(context.Background(), "Ubuntu"), "Terminate should have succeeded")
+
+	s, err = b.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, state.Stopped, s, "Distros should be stopped once terminated")
+
+	exited := make(chan struct{})
+	go func() {
+		_, _ = p.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "The process should have exited when the distro was terminated")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	t.Parallel()
+
+	b, err := local.New(t.TempDir())
+	require.NoError(t, err, "Setup: could not create back-end")
+
+	require.ErrorIs(t, b.Install(context.Background(), "Ubuntu"), errors.ErrUnsupported, "Install should not be supported")
+	require.ErrorIs(t, b.RemoveAppxFamily(context.Background(), "Ubuntu"), errors.ErrUnsupported, "RemoveAppxFamily should not be supported")
+}
+
+// emptyRootfs returns the path to an empty tarball.
+func emptyRootfs(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(path, nil, 0600), "Setup: could not write rootfs")
+
+	return path
+}
+
+// launch runs a command in the distro, and returns its output and exit code.
+func launch(t *testing.T, b backend.Backend, distro, command string) (stdout string, exitCode int) {
+	t.Helper()
+
+	stdinR, stdinW := pipe(t)
+	stdinW.Close()
+	stdoutR, stdoutW := pipe(t)
+	_, stderrW := pipe(t)
+
+	p, err := b.WslLaunch(context.Background(), distro, command, false, stdinR, stdoutW, stderrW)
+	require.NoError(t, err, "WslLaunch should have succeeded")
+	stdoutW.Close()
+	stderrW.Close()
+
+	out, err := io.ReadAll(stdoutR)
+	require.NoError(t, err, "Could not read stdout")
+
+	ps, err := p.Wait()
+	require.NoError(t, err, "Wait should have succeeded")
+
+	return string(out), ps.ExitCode()
+}
+
+// pipe creates a pipe, which is closed during cleanup.
+func pipe(t *testing.T) (r, w *os.File) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+
+	return r, w
+}
@@ -0,0 +1,68 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+// RegistryKey is a snapshot of the metadata of the distros, laid out as in the Lxss registry key.
+type RegistryKey struct {
+	path    string
+	fields  map[string]string
+	subkeys []string
+}
+
+// OpenLxssRegistry opens a registry key at the chosen path: "." for the root
+// key, with the default distro, and the GUID of a distro for its own key.
+func (b *Backend) OpenLxssRegistry(ctx context.Context, path string) (backend.RegistryKey, error) {
+	s, err := b.read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("local back-end: registry: %w", err)
+	}
+
+	if path == "." {
+		key := &RegistryKey{path: path, fields: make(map[string]string)}
+		if s.DefaultDistribution != "" {
+			key.fields["DefaultDistribution"] = s.DefaultDistribution
+		}
+		for GUID := range s.Distros {
+			key.subkeys = append(key.subkeys, GUID)
+		}
+		return key, nil
+	}
+
+	d, ok := s.Distros[path]
+	if !ok {
+		return nil, fmt.Errorf("local back-end: registry: could not open %s: %w", path, fs.ErrNotExist)
+	}
+
+	return &RegistryKey{
+		path: path,
+		fields: map[string]string{
+			"DistributionName": d.Name,
+			"BasePath":         d.BasePath,
+		},
+	}, nil
+}
+
+// Close releases the key.
+func (r *RegistryKey) Close() error {
+	return nil
+}
+
+// Field obtains the value of a field.
+func (r *RegistryKey) Field(name string) (string, error) {
+	v, ok := r.fields[name]
+	if !ok {
+		return "", fmt.Errorf("registry: could not access field %q in %s: %w", name, r.path, fs.ErrNotExist)
+	}
+	return v, nil
+}
+
+// SubkeyNames returns the names of the key's children.
+func (r *RegistryKey) SubkeyNames() ([]string, error) {
+	return r.subkeys, nil
+}
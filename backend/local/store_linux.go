@@ -0,0 +1,163 @@
+package local
+
+// This file contains the metadata of the distros, which WSL keeps in the Lxss registry key.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+const (
+	storeFile = "lxss.json"
+	lockFile  = "lxss.lock"
+)
+
+// store is the metadata of all the distros.
+type store struct {
+	DefaultDistribution string             `json:"defaultDistribution,omitempty"` // GUID of the default distro
+	Distros             map[string]*distro `json:"distros"`                       // Distros by GUID
+}
+
+// distro is the metadata of a distro.
+type distro struct {
+	Name       string            `json:"name"`
+	BasePath   string            `json:"basePath"` // Directory with the root filesystem of the distro, in its rootfs subdirectory
+	DefaultUID uint32            `json:"defaultUid"`
+	Flags      backend.WslFlags  `json:"flags"`
+	Env        map[string]string `json:"env"`
+}
+
+// root returns the directory with the root filesystem of the distro.
+func (d *distro) root() string {
+	return filepath.Join(d.BasePath, "rootfs")
+}
+
+// find returns the GUID and metadata of the distro, whose name is case-insensitive.
+func (s *store) find(distroName string) (GUID string, d *distro, err error) {
+	for GUID, d := range s.Distros {
+		if strings.EqualFold(d.Name, distroName) {
+			return GUID, d, nil
+		}
+	}
+	return "", nil, backend.ErrNotExist
+}
+
+// remove removes the distro, and makes another one the default if it was the default.
+func (s *store) remove(GUID string) {
+	delete(s.Distros, GUID)
+	if s.DefaultDistribution != GUID {
+		return
+	}
+
+	s.DefaultDistribution = ""
+	if len(s.Distros) > 0 {
+		GUIDs := make([]string, 0, len(s.Distros))
+		for g := range s.Distros {
+			GUIDs = append(GUIDs, g)
+		}
+		s.DefaultDistribution = slices.Min(GUIDs)
+	}
+}
+
+// read returns the metadata of all the distros.
+func (b *Backend) read(ctx context.Context) (s store, err error) {
+	if err := ctx.Err(); err != nil {
+		return store{}, err
+	}
+
+	unlock, err := b.lock(syscall.LOCK_SH)
+	if err != nil {
+		return store{}, err
+	}
+	defer unlock()
+
+	return b.load()
+}
+
+// update applies f to the metadata of all the distros, and saves them unless it fails.
+// Other processes cannot access the metadata in the meantime.
+func (b *Backend) update(ctx context.Context, f func(*store) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	unlock, err := b.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	if err := f(&s); err != nil {
+		return err
+	}
+
+	return b.save(s)
+}
+
+// lock locks the metadata against other processes, and returns the function that unlocks it.
+func (b *Backend) lock(how int) (unlock func(), err error) {
+	f, err := os.OpenFile(filepath.Join(b.dir, lockFile), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not lock metadata: %v", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not lock metadata: %v", err)
+	}
+
+	// Closing the file releases the lock.
+	return func() { f.Close() }, nil
+}
+
+func (b *Backend) load() (s store, err error) {
+	out, err := os.ReadFile(filepath.Join(b.dir, storeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return store{Distros: make(map[string]*distro)}, nil
+	} else if err != nil {
+		return store{}, fmt.Errorf("could not read metadata: %v", err)
+	}
+
+	if err := json.Unmarshal(out, &s); err != nil {
+		return store{}, fmt.Errorf("could not parse metadata: %v", err)
+	}
+
+	if s.Distros == nil {
+		s.Distros = make(map[string]*distro)
+	}
+
+	return s, nil
+}
+
+func (b *Backend) save(s store) error {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal metadata: %v", err)
+	}
+
+	// Writing to another file and renaming it ensures that readers never see partial writes.
+	tmp := filepath.Join(b.dir, storeFile+".tmp")
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return fmt.Errorf("could not write metadata: %v", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(b.dir, storeFile)); err != nil {
+		return fmt.Errorf("could not write metadata: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+// Package logging decorates back-ends to log every call they receive with log/slog, and to trace
+// them with hooks:
+//
+//	b = backend.Chain(b, logging.Middleware(slog.Default()))
+//
+// or, for the back-end selected by GoWSL:
+//
+//	ctx = gowsl.WithLogger(ctx, slog.Default())
+//
+// Successful calls are logged at the debug level, and failed calls at the error level, along with
+// the distro involved, their duration and their result. Back-ends that run commands to do their
+// job, such as the real one with wsl.exe, also have each command logged with its standard error,
+// as long as they implement backend.ExecReporter.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+// Call describes a call to a back-end, for tracers.
+type Call struct {
+	Method string // Name of the method
+	Distro string // Name of the distro involved, if any
+}
+
+// Tracer is called when a call to the back-end starts, for instance to start a span. It returns
+// the context for the call, which is passed on to the back-end by the methods that take one, and
+// a function to call with the outcome of the call when it returns, for instance to end the span.
+type Tracer func(ctx context.Context, c Call) (context.Context, func(err error))
+
+type options struct {
+	tracer Tracer
+}
+
+// Option is an optional parameter for New and Middleware.
+type Option func(*options)
+
+// WithTracer sets a tracer that is called around every call to the back-end.
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+// Backend logs every call to the back-end it decorates. Create it with New.
+type Backend struct {
+	backend.Backend
+	logger *slog.Logger
+	tracer Tracer
+}
+
+// New decorates the back-end to log every call it receives to the logger. If the back-end
+// implements backend.ExecReporter, the commands it runs are logged too.
+func New(b backend.Backend, logger *slog.Logger, args ...Option) *Backend {
+	var opts options
+	for _, f := range args {
+		f(&opts)
+	}
+
+	l := &Backend{
+		logger: logger,
+		tracer: opts.tracer,
+	}
+
+	if r, ok := b.(backend.ExecReporter); ok {
+		b = r.WithExecHook(l.logExec)
+	}
+	l.Backend = b
+
+	return l
+}
+
+// Middleware returns a middleware that decorates back-ends with New.
+func Middleware(logger *slog.Logger, args ...Option) backend.Middleware {
+	return func(b backend.Backend) backend.Backend {
+		return New(b, logger, args...)
+	}
+}
+
+// call starts logging and tracing a call to the method. The returned function must be called
+// with the outcome of the call when it returns, along with attributes that describe its
+// arguments and results:
+//
+//	ctx, done := b.call(ctx, "Method", distroName)
+//	err := b.Backend.Method(ctx, distroName)
+//	done(err, slog.String("result", "something"))
+func (b *Backend) call(ctx context.Context, method, distro string) (context.Context, func(error, ...slog.Attr)) {
+	end := func(error) {}
+	if b.tracer != nil {
+		ctx, end = b.tracer(ctx, Call{Method: method, Distro: distro})
+	}
+
+	start := time.Now()
+
+	return ctx, func(err error, result ...slog.Attr) {
+		end(err)
+
+		attrs := []slog.Attr{slog.String("method", method)}
+		if distro != "" {
+			attrs = append(attrs, slog.String("distro", distro))
+		}
+		attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+		attrs = append(attrs, result...)
+
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+			b.logger.LogAttrs(ctx, slog.LevelError, "WSL back-end call failed", attrs...)
+			return
+		}
+
+		b.logger.LogAttrs(ctx, slog.LevelDebug, "WSL back-end call", attrs...)
+	}
+}
+
+// logExec logs a command run by the back-end. Failed commands are logged at the warning
+// level, as the error of the call that ran them is logged too.
+func (b *Backend) logExec(e backend.Exec) {
+	level := slog.LevelDebug
+	if e.ExitCode != 0 {
+		level = slog.LevelWarn
+	}
+
+	b.logger.LogAttrs(context.Background(), level, "WSL back-end command",
+		slog.String("command", strings.Join(e.Args, " ")),
+		slog.Int("exitCode", e.ExitCode),
+		slog.Duration("duration", e.Duration),
+		slog.String("stderr", strings.TrimSpace(e.Stderr)),
+	)
+}
+
+// OpenLxssRegistry opens a registry key at the chosen path.
+func (b *Backend) OpenLxssRegistry(ctx context.Context, path string) (backend.RegistryKey, error) {
+	ctx, done := b.call(ctx, "OpenLxssRegistry", "")
+	key, err := b.Backend.OpenLxssRegistry(ctx, path)
+	done(err, slog.String("path", path))
+	return key, err
+}
+
+// RemoveAppxFamily uninstalls the Appx under the provided family name.
+func (b *Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string) error {
+	ctx, done := b.call(ctx, "RemoveAppxFamily", "")
+	err := b.Backend.RemoveAppxFamily(ctx, packageFamilyName)
+	done(err, slog.String("packageFamilyName", packageFamilyName))
+	return err
+}
+
+// State returns the state of a particular distro as seen in `wsl.exe -l -v`.
+func (b *Backend) State(ctx context.Context, distributionName string) (backend.State, error) {
+	ctx, done := b.call(ctx, "State", distributionName)
+	s, err := b.Backend.State(ctx, distributionName)
+	if err != nil {
+		done(err)
+		return s, err
+	}
+	done(nil, slog.String("state", s.String()))
+	return s, err
+}
+
+// Shutdown shuts down all distros.
+func (b *Backend) Shutdown(ctx context.Context) error {
+	ctx, done := b.call(ctx, "Shutdown", "")
+	err := b.Backend.Shutdown(ctx)
+	done(err)
+	return err
+}
+
+// Terminate shuts down a particular distro.
+func (b *Backend) Terminate(ctx context.Context, distroName string) error {
+	ctx, done := b.call(ctx, "Terminate", distroName)
+	err := b.Backend.Terminate(ctx, distroName)
+	done(err)
+	return err
+}
+
+// SetAsDefault sets a particular distribution as the default one.
+func (b *Backend) SetAsDefault(ctx context.Context, distroName string) error {
+	ctx, done := b.call(ctx, "SetAsDefault", distroName)
+	err := b.Backend.SetAsDefault(ctx, distroName)
+	done(err)
+	return err
+}
+
+// Install installs a new distro from the Windows store.
+func (b *Backend) Install(ctx context.Context, appxName string) error {
+	ctx, done := b.call(ctx, "Install", "")
+	err := b.Backend.Install(ctx, appxName)
+	done(err, slog.String("appxName", appxName))
+	return err
+}
+
+// Import creates a new distro from a source root filesystem.
+func (b *Backend) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) error {
+	ctx, done := b.call(ctx, "Import", distributionName)
+	err := b.Backend.Import(ctx, distributionName, sourcePath, destinationPath)
+	done(err, slog.String("sourcePath", sourcePath), slog.String("destinationPath", destinationPath))
+	return err
+}
+
+// WslConfigureDistribution configures the default UID and flags of a distro.
+func (b *Backend) WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags backend.WslFlags) error {
+	ctx, done := b.call(ctx, "WslConfigureDistribution", distributionName)
+	err := b.Backend.WslConfigureDistribution(ctx, distributionName, defaultUID, wslDistributionFlags)
+	done(err, slog.Any("defaultUid", defaultUID), slog.Any("flags", wslDistributionFlags))
+	return err
+}
+
+// WslGetDistributionConfiguration gets the configuration of a distro.
+func (b *Backend) WslGetDistributionConfiguration(ctx context.Context, distroName string,
+	distributionVersion *uint8,
+	defaultUID *uint32,
+	wslDistributionFlags *backend.WslFlags,
+	defaultEnvironmentVariables *map[string]string) error {
+	ctx, done := b.call(ctx, "WslGetDistributionConfiguration", distroName)
+	err := b.Backend.WslGetDistributionConfiguration(ctx, distroName, distributionVersion, defaultUID, wslDistributionFlags, defaultEnvironmentVariables)
+	if err != nil {
+		done(err)
+		return err
+	}
+	done(nil, slog.Any("version", *distributionVersion), slog.Any("defaultUid", *defaultUID), slog.Any("flags", *wslDistributionFlags))
+	return nil
+}
+
+// WslLaunch runs a command in the distro. The call is logged when the process starts.
+func (b *Backend) WslLaunch(ctx context.Context, distroName string, command string, useCWD bool, stdin *os.File, stdout *os.File, stderr *os.File) (*os.Process, error) {
+	ctx, done := b.call(ctx, "WslLaunch", distroName)
+	p, err := b.Backend.WslLaunch(ctx, distroName, command, useCWD, stdin, stdout, stderr)
+	if err != nil {
+		done(err, slog.String("command", command))
+		return nil, err
+	}
+	done(nil, slog.String("command", command), slog.Int("pid", p.Pid))
+	return p, nil
+}
+
+// WslLaunchInteractive runs a command in the distro, attached to the terminal.
+func (b *Backend) WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (uint32, error) {
+	ctx, done := b.call(ctx, "WslLaunchInteractive", distributionName)
+	exitCode, err := b.Backend.WslLaunchInteractive(ctx, distributionName, command, useCurrentWorkingDirectory)
+	if err != nil {
+		done(err, slog.String("command", command))
+		return exitCode, err
+	}
+	done(nil, slog.String("command", command), slog.Any("exitCode", exitCode))
+	return exitCode, nil
+}
+
+// WslRegisterDistribution registers a new distro.
+func (b *Backend) WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) error {
+	ctx, done := b.call(ctx, "WslRegisterDistribution", distributionName)
+	err := b.Backend.WslRegisterDistribution(ctx, distributionName, tarGzFilename)
+	done(err, slog.String("tarGzFilename", tarGzFilename))
+	return err
+}
+
+// WslUnregisterDistribution unregisters a distro.
+func (b *Backend) WslUnregisterDistribution(ctx context.Context, distributionName string) error {
+	ctx, done := b.call(ctx, "WslUnregisterDistribution", distributionName)
+	err := b.Backend.WslUnregisterDistribution(ctx, distributionName)
+	done(err)
+	return err
+}
+
+// ShareRoot returns the directory where the decorated back-end shares the filesystem of the
+// distro, if it does. Accessing it is not logged.
+func (b *Backend) ShareRoot(distroName string) (string, bool) {
+	return backend.ShareRoot(b.Backend, distroName)
+}
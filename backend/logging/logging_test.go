@@ -0,0 +1,157 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/logging"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+
+	m := mock.New()
+	require.NoError(t, m.WslRegisterDistribution(context.Background(), "Ubuntu", "rootfs.tar.gz"), "Setup: could not register distro")
+
+	logger, records := newLogger(t)
+	b := backend.Chain(m, logging.Middleware(logger))
+
+	_, err := b.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have succeeded")
+	err = b.Terminate(context.Background(), "Debian")
+	require.Error(t, err, "Terminate should have failed")
+
+	logs := records()
+	require.Len(t, logs, 2, "Every call should have been logged")
+
+	require.Equal(t, "DEBUG", logs[0]["level"], "Successful calls should be logged at the debug level")
+	require.Equal(t, "State", logs[0]["method"], "The method should have been logged")
+	require.Equal(t, "Ubuntu", logs[0]["distro"], "The distro should have been logged")
+	require.Equal(t, "Stopped", logs[0]["state"], "The result should have been logged")
+	require.Contains(t, logs[0], "duration", "The duration should have been logged")
+
+	require.Equal(t, "ERROR", logs[1]["level"], "Failed calls should be logged at the error level")
+	require.Equal(t, "Terminate", logs[1]["method"], "The method should have been logged")
+	require.Equal(t, "Debian", logs[1]["distro"], "The distro should have been logged")
+	require.Equal(t, err.Error(), logs[1]["error"], "The error should have been logged")
+}
+
+// execBackend is a back-end that reports a command for each call to Shutdown.
+type execBackend struct {
+	backend.Backend
+	hook func(backend.Exec)
+}
+
+func (b execBackend) WithExecHook(hook func(backend.Exec)) backend.Backend {
+	b.hook = hook
+	return b
+}
+
+func (b execBackend) Shutdown(context.Context) error {
+	if b.hook != nil {
+		b.hook(backend.Exec{Args: []string{"wsl.exe", "--shutdown"}, Stderr: "Access denied\r\n", ExitCode: 1, Duration: time.Second})
+	}
+	return errors.New("could not shut WSL down")
+}
+
+func TestLoggingCommands(t *testing.T) {
+	t.Parallel()
+
+	logger, records := newLogger(t)
+	b := logging.New(execBackend{Backend: mock.New()}, logger)
+
+	require.Error(t, b.Shutdown(context.Background()), "Shutdown should have failed")
+
+	logs := records()
+	require.Len(t, logs, 2, "The command and the call should have been logged")
+	require.Equal(t, "WARN", logs[0]["level"], "Failed commands should be logged at the warning level")
+	require.Equal(t, "wsl.exe --shutdown", logs[0]["command"], "The command line should have been logged")
+	require.Equal(t, "Access denied", logs[0]["stderr"], "The standard error should have been logged")
+	require.InDelta(t, 1, logs[0]["exitCode"], 0, "The exit code should have been logged")
+	require.Equal(t, "Shutdown", logs[1]["method"], "The call should have been logged after its command")
+}
+
+type spanKey struct{}
+
+// spanRecorder is a back-end that records the span in the context of the calls to Import.
+type spanRecorder struct {
+	backend.Backend
+	span *any
+}
+
+func (b spanRecorder) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) error {
+	*b.span = ctx.Value(spanKey{})
+	return b.Backend.Import(ctx, distributionName, sourcePath, destinationPath)
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	var spans []logging.Call
+	var ends []error
+	tracer := func(ctx context.Context, c logging.Call) (context.Context, func(error)) {
+		spans = append(spans, c)
+		return context.WithValue(ctx, spanKey{}, c.Method), func(err error) { ends = append(ends, err) }
+	}
+
+	rootfs := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(rootfs, nil, 0600), "Setup: could not write rootfs")
+
+	var span any
+	logger, _ := newLogger(t)
+	b := logging.New(spanRecorder{Backend: mock.New(), span: &span}, logger, logging.WithTracer(tracer))
+
+	require.NoError(t, b.Import(context.Background(), "Ubuntu", rootfs, t.TempDir()), "Import should have succeeded")
+	require.Equal(t, "Import", span, "The context returned by the tracer should have been passed on to the back-end")
+	require.Error(t, b.SetAsDefault(context.Background(), "Debian"), "SetAsDefault should have failed")
+
+	require.Equal(t, []logging.Call{{Method: "Import", Distro: "Ubuntu"}, {Method: "SetAsDefault", Distro: "Debian"}}, spans, "Every call should have been traced")
+	require.Len(t, ends, 2, "Every span should have ended")
+	require.NoError(t, ends[0], "The span of a successful call should end without error")
+	require.Error(t, ends[1], "The span of a failed call should end with its error")
+}
+
+// newLogger returns a logger at the debug level, and a function that returns the records it logged.
+func newLogger(t *testing.T) (*slog.Logger, func() []map[string]any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	logger := slog.New(slog.NewJSONHandler(&lockedWriter{w: &buf, mu: &mu}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	return logger, func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var records []map[string]any
+		dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+		for dec.More() {
+			var r map[string]any
+			require.NoError(t, dec.Decode(&r), "Could not decode log record")
+			records = append(records, r)
+		}
+		return records
+	}
+}
+
+type lockedWriter struct {
+	w  *bytes.Buffer
+	mu *sync.Mutex
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
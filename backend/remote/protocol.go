@@ -0,0 +1,131 @@
+package remote
+
+// This file contains the messages exchanged between the client and the server.
+//
+// Every method of the back-end is a POST request to /v1/<method>, with its arguments as a JSON
+// object in the body, and its results as a JSON object in the body of the response. Failed calls
+// respond with a non-2xx status and an errkind.Error, so that errors keep their identity.
+//
+// WslLaunch is the exception: its arguments are in the query string, the body of the request is
+// the standard input of the process, and the body of the response is a sequence of frames with
+// its standard output and error, followed by its exit code.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+const (
+	// apiPrefix is the prefix of the paths of all requests.
+	apiPrefix = "/v1/"
+
+	// deadlineHeader carries the deadline of the client's context, in RFC 3339 format.
+	deadlineHeader = "Gowsl-Deadline"
+)
+
+type registryArgs struct {
+	Path string `json:"path"`
+	Name string `json:"name,omitempty"`
+}
+
+type registryReply struct {
+	Value string   `json:"value,omitempty"`
+	Names []string `json:"names,omitempty"`
+}
+
+type distroArgs struct {
+	Distro string `json:"distro,omitempty"`
+}
+
+type stateReply struct {
+	State backend.State `json:"state"`
+}
+
+type removeAppxFamilyArgs struct {
+	PackageFamilyName string `json:"packageFamilyName"`
+}
+
+type installArgs struct {
+	AppxName string `json:"appxName"`
+}
+
+type importArgs struct {
+	Distro          string `json:"distro"`
+	SourcePath      string `json:"sourcePath"`
+	DestinationPath string `json:"destinationPath"`
+}
+
+type configurationArgs struct {
+	Distro     string           `json:"distro"`
+	DefaultUID uint32           `json:"defaultUid"`
+	Flags      backend.WslFlags `json:"flags"`
+}
+
+type configurationReply struct {
+	Version    uint8             `json:"version"`
+	DefaultUID uint32            `json:"defaultUid"`
+	Flags      backend.WslFlags  `json:"flags"`
+	Env        map[string]string `json:"env"`
+}
+
+type launchArgs struct {
+	Distro  string `json:"distro"`
+	Command string `json:"command"`
+	UseCWD  bool   `json:"useCwd"`
+}
+
+type launchReply struct {
+	ExitCode uint32 `json:"exitCode"`
+}
+
+type registerArgs struct {
+	Distro        string `json:"distro"`
+	TarGzFilename string `json:"tarGzFilename"`
+}
+
+// Streams of the frames in the response to WslLaunch.
+const (
+	streamStdout byte = iota + 1
+	streamStderr
+	streamExit  // The payload is the exit code, as a big-endian uint32
+	streamError // The payload is an errkind.Error in JSON
+)
+
+// maxFrameSize is the largest payload that a frame can carry.
+const maxFrameSize = 1 << 20
+
+// writeFrame writes a frame: the stream, the length of the payload as a big-endian uint32, and the payload.
+func writeFrame(w io.Writer, stream byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = stream
+	//nolint:gosec // Payloads are never larger than maxFrameSize.
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	if _, err := w.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readFrame reads a frame written by writeFrame.
+func readFrame(r io.Reader) (stream byte, payload []byte, err error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], payload, nil
+}
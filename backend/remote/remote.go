@@ -0,0 +1,425 @@
+// Package remote lets GoWSL manage the distros of another machine: a Server on the Windows host
+// serves its back-end over HTTP, and a Backend elsewhere (on Linux, for instance) forwards every
+// call to it.
+//
+//	b, err := remote.New("https://windows-host:8765", remote.WithToken(token))
+//	...
+//	ctx = gowsl.WithBackend(ctx, b)
+//
+// See cmd/gowsl-agent for a ready-made server.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/errkind"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+)
+
+// Backend forwards all calls to a remote Server. Calls run until the server responds, or their
+// context is done. The server then cancels them in turn.
+type Backend struct {
+	url    *url.URL
+	token  string
+	client *http.Client
+}
+
+type options struct {
+	token  string
+	client *http.Client
+}
+
+// Option is an optional parameter for New.
+type Option func(*options)
+
+// WithToken sets the token used to authenticate with the server.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to make the requests, for instance to trust the
+// certificate of the server. By default, http.DefaultClient is used.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// New creates a back-end that forwards all calls to the server at the URL.
+func New(serverURL string, opts ...Option) (b *Backend, err error) {
+	defer decorate.OnError(&err, "could not create remote back-end")
+
+	o := options{
+		client: http.DefaultClient,
+	}
+
+	for _, f := range opts {
+		f(&o)
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	return &Backend{
+		url:    u,
+		token:  o.token,
+		client: o.client,
+	}, nil
+}
+
+// newRequest creates a request to the server for the method. The deadline of the
+// context, if any, is forwarded to the server.
+func (b *Backend) newRequest(ctx context.Context, method string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := b.url.JoinPath(apiPrefix, method)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(deadlineHeader, deadline.Format(time.RFC3339Nano))
+	}
+
+	return req, nil
+}
+
+// do sends the request, and returns the response if it succeeded.
+func (b *Backend) do(req *http.Request) (*http.Response, error) {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	return nil, responseError(resp)
+}
+
+// responseError reads the error in the body of a failed response.
+func responseError(resp *http.Response) error {
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %v", resp.Status, err)
+	}
+
+	var e *errkind.Error
+	if json.Unmarshal(out, &e) != nil || e == nil || e.Message == "" {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(out)))
+	}
+
+	return e
+}
+
+// call calls the method on the server, and decodes its reply.
+func (b *Backend) call(ctx context.Context, method string, args, reply any) (err error) {
+	defer decorate.OnError(&err, "remote back-end: %s", method)
+
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	req, err := b.newRequest(ctx, method, nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if reply == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+// OpenLxssRegistry opens a registry key at the chosen path.
+func (b *Backend) OpenLxssRegistry(ctx context.Context, path string) (backend.RegistryKey, error) {
+	if err := b.call(ctx, "OpenLxssRegistry", registryArgs{Path: path}, nil); err != nil {
+		return nil, err
+	}
+	return &RegistryKey{backend: b, path: path}, nil
+}
+
+// RemoveAppxFamily uninstalls the Appx under the provided family name.
+func (b *Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string) error {
+	return b.call(ctx, "RemoveAppxFamily", removeAppxFamilyArgs{PackageFamilyName: packageFamilyName}, nil)
+}
+
+// State returns the state of a particular distro as seen in `wsl.exe -l -v`.
+func (b *Backend) State(ctx context.Context, distributionName string) (backend.State, error) {
+	var reply stateReply
+	if err := b.call(ctx, "State", distroArgs{Distro: distributionName}, &reply); err != nil {
+		return 0, err
+	}
+	return reply.State, nil
+}
+
+// Shutdown shuts down all distros.
+func (b *Backend) Shutdown(ctx context.Context) error {
+	return b.call(ctx, "Shutdown", distroArgs{}, nil)
+}
+
+// Terminate shuts down a particular distro.
+func (b *Backend) Terminate(ctx context.Context, distroName string) error {
+	return b.call(ctx, "Terminate", distroArgs{Distro: distroName}, nil)
+}
+
+// SetAsDefault sets a particular distribution as the default one.
+func (b *Backend) SetAsDefault(ctx context.Context, distroName string) error {
+	return b.call(ctx, "SetAsDefault", distroArgs{Distro: distroName}, nil)
+}
+
+// Install installs a new distro from the Windows store.
+func (b *Backend) Install(ctx context.Context, appxName string) error {
+	return b.call(ctx, "Install", installArgs{AppxName: appxName}, nil)
+}
+
+// Import creates a new distro from a source root filesystem. Paths refer to the server's files.
+func (b *Backend) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) error {
+	return b.call(ctx, "Import", importArgs{Distro: distributionName, SourcePath: sourcePath, DestinationPath: destinationPath}, nil)
+}
+
+// WslConfigureDistribution configures the default UID and flags of a distro.
+func (b *Backend) WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags backend.WslFlags) error {
+	return b.call(ctx, "WslConfigureDistribution", configurationArgs{
+		Distro:     distributionName,
+		DefaultUID: defaultUID,
+		Flags:      wslDistributionFlags,
+	}, nil)
+}
+
+// WslGetDistributionConfiguration gets the configuration of a distro.
+func (b *Backend) WslGetDistributionConfiguration(ctx context.Context, distroName string,
+	distributionVersion *uint8,
+	defaultUID *uint32,
+	wslDistributionFlags *backend.WslFlags,
+	defaultEnvironmentVariables *map[string]string) error {
+	var reply configurationReply
+	if err := b.call(ctx, "WslGetDistributionConfiguration", distroArgs{Distro: distroName}, &reply); err != nil {
+		return err
+	}
+
+	*distributionVersion = reply.Version
+	*defaultUID = reply.DefaultUID
+	*wslDistributionFlags = reply.Flags
+	*defaultEnvironmentVariables = reply.Env
+
+	return nil
+}
+
+// WslLaunchInteractive runs a command in the distro, attached to the terminal of the server.
+func (b *Backend) WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (uint32, error) {
+	var reply launchReply
+	if err := b.call(ctx, "WslLaunchInteractive", launchArgs{
+		Distro:  distributionName,
+		Command: command,
+		UseCWD:  useCurrentWorkingDirectory,
+	}, &reply); err != nil {
+		return math.MaxUint32, err
+	}
+	return reply.ExitCode, nil
+}
+
+// WslRegisterDistribution registers a new distro. The tarball refers to the server's files.
+func (b *Backend) WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) error {
+	return b.call(ctx, "WslRegisterDistribution", registerArgs{Distro: distributionName, TarGzFilename: tarGzFilename}, nil)
+}
+
+// WslUnregisterDistribution unregisters a distro.
+func (b *Backend) WslUnregisterDistribution(ctx context.Context, distributionName string) error {
+	return b.call(ctx, "WslUnregisterDistribution", distroArgs{Distro: distributionName}, nil)
+}
+
+// WslLaunch runs a command in the distro. Its standard streams are forwarded to and from the
+// server for as long as it runs. The process returned is a local stand-in for the remote one:
+// it exits with the same exit code, and killing it kills the remote process.
+func (b *Backend) WslLaunch(launchCtx context.Context, distroName string, command string, useCWD bool, stdin *os.File, stdout *os.File, stderr *os.File) (p *os.Process, err error) {
+	defer decorate.OnError(&err, "remote back-end: WslLaunch")
+
+	// The caller may close its files as soon as we return.
+	var files []*os.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	for _, f := range []*os.File{stdin, stdout, stderr} {
+		dup, err := stubprocess.Dup(f)
+		if err != nil {
+			closeFiles()
+			return nil, err
+		}
+		files = append(files, dup)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	query := url.Values{
+		"distro":  {distroName},
+		"command": {command},
+		"useCwd":  {strconv.FormatBool(useCWD)},
+	}
+
+	// The transport cannot interrupt a read of the standard input, so we do it
+	// ourselves when the request is cancelled.
+	body, bodyW := io.Pipe()
+	go func() {
+		_, err := io.Copy(bodyW, files[0])
+		bodyW.CloseWithError(err)
+	}()
+	context.AfterFunc(ctx, func() { body.CloseWithError(ctx.Err()) })
+
+	req, err := b.newRequest(ctx, "WslLaunch", query, body)
+	if err != nil {
+		cancel()
+		closeFiles()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// The context of the call only limits the launch, not the process.
+	stopLaunch := context.AfterFunc(launchCtx, cancel)
+	resp, err := b.client.Do(req)
+	stopLaunch()
+	if err == nil && launchCtx.Err() != nil {
+		err = launchCtx.Err()
+		cancel()
+		resp.Body.Close()
+	}
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = responseError(resp)
+		// The response cannot be closed until the request is done, which could
+		// otherwise wait for the standard input to be closed.
+		cancel()
+		resp.Body.Close()
+	}
+	if err != nil {
+		cancel()
+		closeFiles()
+		return nil, err
+	}
+
+	p, err = stubprocess.Start(func(stubCtx context.Context) int {
+		defer closeFiles()
+		defer resp.Body.Close()
+		defer cancel()
+
+		// Killing the stand-in cancels the request, which kills the remote process.
+		stop := context.AfterFunc(stubCtx, cancel)
+		defer stop()
+
+		code, err := relayFrames(resp.Body, files[1], files[2])
+		if err != nil {
+			fmt.Fprintf(files[2], "remote back-end: WslLaunch: %v\n", err)
+		}
+		//nolint:gosec // Exit codes above MaxInt32 wrap around, as they do on Windows.
+		return int(code)
+	})
+	if err != nil {
+		cancel()
+		closeFiles()
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// relayFrames writes the frames sent by the server to the standard output and error,
+// and returns the exit code of the remote process.
+func relayFrames(r io.Reader, stdout, stderr io.Writer) (uint32, error) {
+	for {
+		stream, payload, err := readFrame(r)
+		if errors.Is(err, io.EOF) {
+			return math.MaxUint32, errors.New("the server closed the stream without an exit code")
+		} else if err != nil {
+			return math.MaxUint32, err
+		}
+
+		switch stream {
+		case streamStdout:
+			_, _ = stdout.Write(payload)
+		case streamStderr:
+			_, _ = stderr.Write(payload)
+		case streamExit:
+			if len(payload) != 4 {
+				return math.MaxUint32, fmt.Errorf("invalid exit code of %d bytes", len(payload))
+			}
+			return binary.BigEndian.Uint32(payload), nil
+		case streamError:
+			var e *errkind.Error
+			if err := json.Unmarshal(payload, &e); err != nil || e == nil {
+				return math.MaxUint32, fmt.Errorf("invalid error: %v", err)
+			}
+			return math.MaxUint32, e
+		default:
+			return math.MaxUint32, fmt.Errorf("unknown stream %d", stream)
+		}
+	}
+}
+
+// RegistryKey is a registry key of the server. It holds no resources on the server:
+// every call opens the key, reads it, and closes it.
+type RegistryKey struct {
+	backend *Backend
+	path    string
+}
+
+// Close releases the key.
+func (k *RegistryKey) Close() error {
+	return nil
+}
+
+// Field obtains the value of a Field. The value must be a string.
+func (k *RegistryKey) Field(name string) (string, error) {
+	var reply registryReply
+	if err := k.backend.call(context.Background(), "RegistryField", registryArgs{Path: k.path, Name: name}, &reply); err != nil {
+		return "", err
+	}
+	return reply.Value, nil
+}
+
+// SubkeyNames returns a slice containing the names of the current key's children.
+func (k *RegistryKey) SubkeyNames() ([]string, error) {
+	var reply registryReply
+	if err := k.backend.call(context.Background(), "RegistrySubkeyNames", registryArgs{Path: k.path}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Names, nil
+}
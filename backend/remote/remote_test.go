@@ -0,0 +1,263 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/remote"
+	"github.com/ubuntu/gowsl/gowsltest"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+const token = "s3cr3t"
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		tls bool
+	}{
+		"Success over HTTP/1.1":       {},
+		"Success over HTTP/2 and TLS": {tls: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rootfs := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+			require.NoError(t, os.WriteFile(rootfs, nil, 0600), "Setup: could not write rootfs")
+
+			b := newRemote(t, mock.New(), tc.tls)
+			gowsltest.RunConformance(t, b, rootfs)
+		})
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		token string
+
+		wantErr bool
+	}{
+		"Success with the right token": {token: token},
+
+		"Error with the wrong token": {token: "not-" + token, wantErr: true},
+		"Error with no token":        {wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := mock.New()
+			s, err := remote.NewServer(m, token)
+			require.NoError(t, err, "Setup: could not create server")
+			server := httptest.NewServer(s)
+			defer server.Close()
+
+			b, err := remote.New(server.URL, remote.WithToken(tc.token))
+			require.NoError(t, err, "Setup: could not create client")
+
+			_, err = b.State(context.Background(), "Ubuntu")
+			if tc.wantErr {
+				require.Error(t, err, "State should have failed")
+				require.Empty(t, m.Calls(""), "No calls should have reached the back-end")
+				return
+			}
+			require.NoError(t, err, "State should have succeeded")
+			require.Len(t, m.Calls("State"), 1, "The call should have reached the back-end")
+		})
+	}
+}
+
+// deadlineRecorder is a middleware that sends the deadline of the contexts that Install receives.
+type deadlineRecorder struct {
+	backend.Backend
+	deadlines chan time.Time
+}
+
+func (r deadlineRecorder) Install(ctx context.Context, appxName string) error {
+	deadline, _ := ctx.Deadline()
+	r.deadlines <- deadline
+	return r.Backend.Install(ctx, appxName)
+}
+
+func TestContextPropagation(t *testing.T) {
+	t.Parallel()
+
+	c := mock.NewFakeClock(time.Now())
+	m := mock.New(mock.WithClock(c), mock.WithDurations(mock.Durations{Install: time.Hour}))
+	deadlines := make(chan time.Time, 1)
+	b := newRemote(t, deadlineRecorder{Backend: m, deadlines: deadlines}, false)
+
+	// Deadlines
+	deadline := time.Now().Add(time.Hour).Round(0)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- b.Install(ctx, "Ubuntu") }()
+
+	select {
+	case got := <-deadlines:
+		require.True(t, deadline.Equal(got), "The deadline should have been forwarded to the server")
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "Install should have reached the server")
+	}
+
+	// Cancellation
+	require.Eventually(t, func() bool { return c.Timers() == 1 }, 10*time.Second, time.Millisecond, "Setup: Install should have started")
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled, "Install should have been cancelled")
+
+	require.Eventually(t, func() bool {
+		calls := m.Calls("Install")
+		return len(calls) == 1 && errors.Is(calls[0].Err, context.Canceled)
+	}, 10*time.Second, time.Millisecond, "The cancellation should have reached the server")
+}
+
+func TestLaunch(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		tls bool
+	}{
+		"Success over HTTP/1.1":       {},
+		"Success over HTTP/2 and TLS": {tls: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := mock.New()
+			require.NoError(t, m.WslRegisterDistribution(context.Background(), "Ubuntu", "rootfs.tar.gz"), "Setup: could not register distro")
+
+			m.Handle(mock.Exact("cat"), func(_ context.Context, stdin io.Reader, stdout, _ io.Writer) int {
+				_, _ = io.Copy(stdout, stdin)
+				return 0
+			})
+
+			m.Handle(mock.Exact("wsl-error"), func(context.Context, io.Reader, io.Writer, io.Writer) int {
+				return int(wsl.ERROR_LINUX_SUBSYSTEM_NOT_PRESENT)
+			})
+
+			killed := make(chan struct{})
+			m.Handle(mock.Exact("sleep infinity"), func(ctx context.Context, _ io.Reader, _, _ io.Writer) int {
+				<-ctx.Done()
+				close(killed)
+				return 1
+			})
+
+			b := newRemote(t, m, tc.tls)
+
+			t.Run("Standard input is forwarded", func(t *testing.T) {
+				stdinR, stdinW := pipe(t)
+				stdoutR, stdoutW := pipe(t)
+				_, stderrW := pipe(t)
+
+				p, err := b.WslLaunch(context.Background(), "Ubuntu", "cat", false, stdinR, stdoutW, stderrW)
+				require.NoError(t, err, "WslLaunch should have succeeded")
+				stdoutW.Close()
+
+				_, err = stdinW.WriteString("Hello from the client!\n")
+				require.NoError(t, err, "Could not write to stdin")
+				stdinW.Close()
+
+				out, err := io.ReadAll(stdoutR)
+				require.NoError(t, err, "Could not read stdout")
+				require.Equal(t, "Hello from the client!\n", string(out), "Standard input should have been echoed")
+
+				ps, err := p.Wait()
+				require.NoError(t, err, "Wait should have succeeded")
+				require.Equal(t, 0, ps.ExitCode(), "Unexpected exit code")
+			})
+
+			t.Run("Exit codes are reported in full", func(t *testing.T) {
+				d := wsl.NewClient(wsl.WithClientBackend(b)).Distro("Ubuntu")
+
+				err := d.Command(context.Background(), "wsl-error").Run()
+				var exitErr *wsl.ExitError
+				require.ErrorAs(t, err, &exitErr, "Run should have failed with an ExitError")
+				require.Equal(t, wsl.OriginWindows, exitErr.Origin, "Exit codes above 255 should be attributed to Windows")
+				require.Equal(t, wsl.ERROR_LINUX_SUBSYSTEM_NOT_PRESENT, exitErr.HResult, "Unexpected HRESULT")
+			})
+
+			t.Run("Killing the process kills the remote one", func(t *testing.T) {
+				stdinR, _ := pipe(t)
+				_, stdoutW := pipe(t)
+				_, stderrW := pipe(t)
+
+				p, err := b.WslLaunch(context.Background(), "Ubuntu", "sleep infinity", false, stdinR, stdoutW, stderrW)
+				require.NoError(t, err, "WslLaunch should have succeeded")
+
+				require.NoError(t, p.Kill(), "Kill should have succeeded")
+				_, _ = p.Wait()
+
+				select {
+				case <-killed:
+				case <-time.After(10 * time.Second):
+					require.Fail(t, "The remote process should have been killed")
+				}
+			})
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := remote.New("ftp://example.com", remote.WithToken(token))
+	require.Error(t, err, "New should fail with unsupported schemes")
+
+	_, err = remote.NewServer(mock.New(), "")
+	require.Error(t, err, "NewServer should fail with an empty token")
+}
+
+// newRemote serves the back-end, and returns a client for it.
+func newRemote(t *testing.T, b backend.Backend, tls bool) *remote.Backend {
+	t.Helper()
+
+	s, err := remote.NewServer(b, token)
+	require.NoError(t, err, "Setup: could not create server")
+
+	server := httptest.NewUnstartedServer(s)
+	if tls {
+		server.EnableHTTP2 = true
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+
+	client, err := remote.New(server.URL, remote.WithToken(token), remote.WithHTTPClient(server.Client()))
+	require.NoError(t, err, "Setup: could not create client")
+
+	return client
+}
+
+// pipe creates a pipe, which is closed during cleanup.
+func pipe(t *testing.T) (r, w *os.File) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+
+	return r, w
+}
@@ -0,0 +1,428 @@
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/errkind"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+)
+
+// Server serves a back-end over HTTP, for Backend clients to use it remotely. Serve it with
+// TLS, so that the token is not sent in clear text and HTTP/2 can be used.
+type Server struct {
+	backend backend.Backend
+	token   string
+	mux     *http.ServeMux
+}
+
+// NewServer creates a server for the back-end. Clients must authenticate with the token,
+// which cannot be empty.
+//
+// Paths received from the clients, such as the tarballs to register distros from, refer
+// to the files of the machine that the server runs on.
+func NewServer(b backend.Backend, token string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("the token cannot be empty")
+	}
+
+	s := &Server{
+		backend: b,
+		token:   token,
+		mux:     http.NewServeMux(),
+	}
+
+	handlers := map[string]func(ctx context.Context, dec *json.Decoder) (any, error){
+		"OpenLxssRegistry":                s.openLxssRegistry,
+		"RegistryField":                   s.registryField,
+		"RegistrySubkeyNames":             s.registrySubkeyNames,
+		"RemoveAppxFamily":                s.removeAppxFamily,
+		"State":                           s.state,
+		"Shutdown":                        s.shutdown,
+		"Terminate":                       s.terminate,
+		"SetAsDefault":                    s.setAsDefault,
+		"Install":                         s.install,
+		"Import":                          s.importDistro,
+		"WslConfigureDistribution":        s.wslConfigureDistribution,
+		"WslGetDistributionConfiguration": s.wslGetDistributionConfiguration,
+		"WslLaunchInteractive":            s.wslLaunchInteractive,
+		"WslRegisterDistribution":         s.wslRegisterDistribution,
+		"WslUnregisterDistribution":       s.wslUnregisterDistribution,
+	}
+
+	for method, h := range handlers {
+		s.mux.HandleFunc("POST "+apiPrefix+method, func(w http.ResponseWriter, r *http.Request) {
+			reply, err := h(r.Context(), json.NewDecoder(r.Body))
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(reply)
+		})
+	}
+
+	s.mux.HandleFunc("POST "+apiPrefix+"WslLaunch", s.wslLaunch)
+
+	return s, nil
+}
+
+// ServeHTTP authenticates the request and passes it to the back-end, with a context
+// that is cancelled when the client cancels its own.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(s.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	if d := r.Header.Get(deadlineHeader); d != "" {
+		deadline, err := time.Parse(time.RFC3339Nano, d)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid deadline: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeError responds to a failed call.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		status = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errkind.New(err))
+}
+
+func (s *Server) openLxssRegistry(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args registryArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	k, err := s.backend.OpenLxssRegistry(ctx, args.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, k.Close()
+}
+
+func (s *Server) registryField(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args registryArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	k, err := s.backend.OpenLxssRegistry(ctx, args.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	value, err := k.Field(args.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return registryReply{Value: value}, nil
+}
+
+func (s *Server) registrySubkeyNames(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args registryArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	k, err := s.backend.OpenLxssRegistry(ctx, args.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	names, err := k.SubkeyNames()
+	if err != nil {
+		return nil, err
+	}
+
+	return registryReply{Names: names}, nil
+}
+
+func (s *Server) removeAppxFamily(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args removeAppxFamilyArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.RemoveAppxFamily(ctx, args.PackageFamilyName)
+}
+
+func (s *Server) state(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args distroArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	st, err := s.backend.State(ctx, args.Distro)
+	if err != nil {
+		return nil, err
+	}
+
+	return stateReply{State: st}, nil
+}
+
+func (s *Server) shutdown(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args distroArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.Shutdown(ctx)
+}
+
+func (s *Server) terminate(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args distroArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.Terminate(ctx, args.Distro)
+}
+
+func (s *Server) setAsDefault(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args distroArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.SetAsDefault(ctx, args.Distro)
+}
+
+func (s *Server) install(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args installArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.Install(ctx, args.AppxName)
+}
+
+func (s *Server) importDistro(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args importArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.Import(ctx, args.Distro, args.SourcePath, args.DestinationPath)
+}
+
+func (s *Server) wslConfigureDistribution(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args configurationArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.WslConfigureDistribution(ctx, args.Distro, args.DefaultUID, args.Flags)
+}
+
+func (s *Server) wslGetDistributionConfiguration(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args distroArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	var reply configurationReply
+	if err := s.backend.WslGetDistributionConfiguration(ctx, args.Distro, &reply.Version, &reply.DefaultUID, &reply.Flags, &reply.Env); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+func (s *Server) wslLaunchInteractive(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args launchArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	exitCode, err := s.backend.WslLaunchInteractive(ctx, args.Distro, args.Command, args.UseCWD)
+	if err != nil {
+		return nil, err
+	}
+
+	return launchReply{ExitCode: exitCode}, nil
+}
+
+func (s *Server) wslRegisterDistribution(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args registerArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.WslRegisterDistribution(ctx, args.Distro, args.TarGzFilename)
+}
+
+func (s *Server) wslUnregisterDistribution(ctx context.Context, dec *json.Decoder) (any, error) {
+	var args distroArgs
+	if err := dec.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.backend.WslUnregisterDistribution(ctx, args.Distro)
+}
+
+// wslLaunch launches the process, and streams its standard input, output and error
+// until it exits. The process is killed if the client goes away.
+func (s *Server) wslLaunch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	useCWD, err := strconv.ParseBool(q.Get("useCwd"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid useCwd: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// The standard input is read while the response is written. This also prevents
+	// the server from waiting for the end of the standard input if the launch fails.
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	p, stdin, stdout, stderr, err := s.launch(r.Context(), q.Get("distro"), q.Get("command"), useCWD)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer stdout.Close()
+	defer stderr.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		_ = p.Kill()
+		return
+	}
+
+	go func() {
+		defer stdin.Close()
+		_, _ = io.Copy(stdin, r.Body)
+	}()
+
+	// Frames from both streams are written one at a time.
+	var mu sync.Mutex
+	send := func(stream byte, payload []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := writeFrame(w, stream, payload); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	var wg sync.WaitGroup
+	for stream, f := range map[byte]*os.File{streamStdout: stdout, streamStderr: stderr} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buff := make([]byte, 32*1024)
+			for {
+				n, err := f.Read(buff)
+				if n > 0 {
+					if err := send(stream, buff[:n]); err != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.Context().Done():
+			_ = p.Kill()
+		case <-done:
+		}
+	}()
+
+	// Back-ends such as the mock launch stub processes, which truncate their exit code on Linux.
+	ps, exitCode, ok, err := stubprocess.Wait(p)
+	wg.Wait()
+
+	if err != nil {
+		out, _ := json.Marshal(errkind.New(err))
+		_ = send(streamError, out)
+		return
+	}
+
+	if !ok {
+		//nolint:gosec // Exit codes are unsigned on Windows, so negative ones wrap around.
+		exitCode = uint32(ps.ExitCode())
+	}
+
+	code := make([]byte, 4)
+	binary.BigEndian.PutUint32(code, exitCode)
+	_ = send(streamExit, code)
+}
+
+// launch launches the process with pipes for its standard streams. It returns the
+// ends of the pipes that the server uses.
+func (s *Server) launch(ctx context.Context, distro, command string, useCWD bool) (p *os.Process, stdin, stdout, stderr *os.File, err error) {
+	stdinR, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	defer stdinR.Close()
+
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return nil, nil, nil, nil, err
+	}
+	defer stdoutW.Close()
+
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		return nil, nil, nil, nil, err
+	}
+	defer stderrW.Close()
+
+	// The process has its own copies of its ends of the pipes, so ours are closed regardless.
+	p, err = s.backend.WslLaunch(ctx, distro, command, useCWD, stdinR, stdoutW, stderrW)
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		stderr.Close()
+		return nil, nil, nil, nil, err
+	}
+
+	return p, stdin, stdout, stderr, nil
+}
@@ -5,8 +5,6 @@ package gowsl
 import (
 	"context"
 
-	"github.com/ubuntu/gowsl/internal/backend"
-	"github.com/ubuntu/gowsl/internal/backend/windows"
 	"github.com/ubuntu/gowsl/mock"
 )
 
@@ -24,14 +22,7 @@ func MockAvailable() bool {
 }
 
 // WithMock adds the mock back-end to the context when GoWSL has been compiled with the gowslmock tag.
-// Otherwise, it panics.
+// Otherwise, it panics. Use WithBackend to add the mock back-end regardless of the build tags.
 func WithMock(ctx context.Context, m *mock.Backend) context.Context {
 	panic("Cannot use mock without build flag gowslmock")
 }
-
-func selectBackend(ctx context.Context) backend.Backend {
-	return windows.Backend{}
-}
-
-// ErrNotExist is the error returned when a distro does not exist.
-var ErrNotExist = windows.ErrNotExist
@@ -0,0 +1,126 @@
+package gowsl_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/governor"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+// dryRun is a middleware that pretends to unregister distros.
+type dryRun struct {
+	backend.Backend
+}
+
+func (dryRun) WslUnregisterDistribution(context.Context, string) error {
+	return nil
+}
+
+// uncomparable is a back-end that cannot be compared with ==.
+type uncomparable struct {
+	backend.Backend
+	_ []int
+}
+
+func TestWithBackend(t *testing.T) {
+	t.Parallel()
+
+	// WithBackend does not need the gowslmock build tag.
+	m := mock.New()
+	ctx := wsl.WithBackend(context.Background(), backend.Chain(m, func(b backend.Backend) backend.Backend {
+		return dryRun{b}
+	}))
+
+	d := wsl.NewDistro(ctx, uniqueDistroName(t))
+	require.NoError(t, d.Register(ctx, rootFS), "Register should have succeeded")
+	require.Len(t, m.Calls("WslRegisterDistribution"), 1, "Calls should have reached the back-end in the context")
+
+	require.NoError(t, d.Unregister(ctx), "Unregister should have succeeded")
+	require.Empty(t, m.Calls("WslUnregisterDistribution"), "Calls should have been intercepted by the middleware")
+
+	registered, err := d.IsRegistered(ctx)
+	require.NoError(t, err, "IsRegistered should have succeeded")
+	require.True(t, registered, "The distro should still be registered")
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ctx := wsl.WithLogger(wsl.WithBackend(context.Background(), mock.New()), logger)
+
+	d := wsl.NewDistro(ctx, uniqueDistroName(t))
+	require.NoError(t, d.Register(ctx, rootFS), "Register should have succeeded")
+
+	require.Contains(t, out.String(), "method=WslRegisterDistribution", "Calls to the back-end should have been logged")
+	require.Contains(t, out.String(), "distro="+d.Name(), "The distro should have been logged")
+}
+
+func TestDistroEqual(t *testing.T) {
+	t.Parallel()
+
+	m := mock.New()
+	logCtx := wsl.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	govCtx := wsl.WithGovernor(context.Background(), governor.New(governor.Policy{}))
+	uncomparableCtx := wsl.WithBackend(context.Background(), uncomparable{Backend: m})
+
+	testCases := map[string]struct {
+		ctx1, ctx2 context.Context
+		name2      string
+
+		want bool
+	}{
+		"Success with the default back-end":                         {ctx1: context.Background(), ctx2: context.Background(), want: true},
+		"Success with names that differ in casing only":             {ctx1: context.Background(), ctx2: context.Background(), name2: "UBUNTU", want: true},
+		"Success with the same back-end":                            {ctx1: wsl.WithBackend(context.Background(), m), ctx2: wsl.WithBackend(context.Background(), m), want: true},
+		"Success with the same context with a logger":               {ctx1: logCtx, ctx2: logCtx, want: true},
+		"Success with the same context with a governor":             {ctx1: govCtx, ctx2: govCtx, want: true},
+		"Success with a context derived from another":               {ctx1: logCtx, ctx2: context.WithoutCancel(logCtx), want: true},
+		"Success with the same uncomparable back-end":               {ctx1: uncomparableCtx, ctx2: context.WithoutCancel(uncomparableCtx), want: true},
+		"Error with different names":                                {ctx1: context.Background(), ctx2: context.Background(), name2: "Debian"},
+		"Error with the default and a different back-end":           {ctx1: context.Background(), ctx2: wsl.WithBackend(context.Background(), m)},
+		"Error with different back-ends":                            {ctx1: wsl.WithBackend(context.Background(), m), ctx2: wsl.WithBackend(context.Background(), mock.New())},
+		"Error with and without a logger":                           {ctx1: context.Background(), ctx2: logCtx},
+		"Error with uncomparable back-ends from different contexts": {ctx1: uncomparableCtx, ctx2: wsl.WithBackend(context.Background(), uncomparable{Backend: m})},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.name2 == "" {
+				tc.name2 = "ubuntu"
+			}
+
+			d1 := wsl.NewDistro(tc.ctx1, "ubuntu")
+			d2 := wsl.NewDistro(tc.ctx2, tc.name2)
+			require.Equal(t, tc.want, d1.Equal(d2), "Unexpected result of Equal")
+			require.Equal(t, tc.want, d2.Equal(d1), "Equal should be symmetric")
+		})
+	}
+}
+
+func TestRegisteredDistrosEqual(t *testing.T) {
+	t.Parallel()
+
+	ctx := wsl.WithBackend(context.Background(), mock.New())
+	ctx = wsl.WithLogger(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx = wsl.WithGovernor(ctx, governor.New(governor.Policy{}))
+
+	d := wsl.NewDistro(ctx, uniqueDistroName(t))
+	require.NoError(t, d.Register(ctx, rootFS), "Setup: Register should have succeeded")
+
+	distros, err := wsl.RegisteredDistros(ctx)
+	require.NoError(t, err, "RegisteredDistros should have succeeded")
+	require.Len(t, distros, 1, "The registered distro should have been listed")
+	require.True(t, d.Equal(distros[0]), "Registered distros should be equal to the distros created with the same context")
+}
@@ -0,0 +1,138 @@
+package gowsl
+
+// This file contains the Client, which holds the configuration that the
+// context-based functions read from the context.
+
+import (
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/governor"
+	"github.com/ubuntu/gowsl/backend/logging"
+	"github.com/ubuntu/gowsl/internal/backend/windows"
+)
+
+// Client gives access to WSL with an explicit configuration. Distros obtained from a client
+// use its back-end and configuration for their whole lifetime, regardless of the contexts
+// passed to their methods.
+//
+// The zero value is not usable: create clients with NewClient.
+type Client struct {
+	backend backend.Backend
+
+	// id identifies the back-end of the client. It is always comparable, so that comparing
+	// distros cannot panic.
+	id any
+}
+
+type clientOptions struct {
+	backend  backend.Backend
+	logger   backend.Middleware
+	governor *governor.Governor
+	timeout  time.Duration
+	wslExe   string
+}
+
+// ClientOption is an optional parameter for NewClient.
+type ClientOption func(*clientOptions)
+
+// WithClientBackend makes the client use the back-end instead of the real WSL.
+// See WithBackend.
+func WithClientBackend(b backend.Backend) ClientOption {
+	return func(o *clientOptions) {
+		o.backend = b
+	}
+}
+
+// WithClientLogger makes the client log every call it makes to the back-end.
+// See WithLogger.
+func WithClientLogger(logger *slog.Logger, opts ...logging.Option) ClientOption {
+	return func(o *clientOptions) {
+		o.logger = logging.Middleware(logger, opts...)
+	}
+}
+
+// WithClientRetryPolicy makes the client limit and retry its invocations of wsl.exe according
+// to the policy. See package governor for details. It has no effect on the back-ends set with
+// WithClientBackend.
+func WithClientRetryPolicy(p governor.Policy) ClientOption {
+	return func(o *clientOptions) {
+		o.governor = governor.New(p)
+	}
+}
+
+// WithClientTimeout sets how long the client waits for wsl.exe commands that are expected to
+// return quickly, such as terminating a distro. Long-running ones, such as installing a distro,
+// are only limited by their context. It has no effect on the back-ends set with WithClientBackend.
+func WithClientTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithClientWslExe makes the client run wsl.exe from the path provided, instead of looking it
+// up in the PATH. It has no effect on the back-ends set with WithClientBackend.
+func WithClientWslExe(path string) ClientOption {
+	return func(o *clientOptions) {
+		o.wslExe = path
+	}
+}
+
+// NewClient creates a client. By default, it makes real calls to WSL.
+func NewClient(args ...ClientOption) *Client {
+	var opts clientOptions
+	for _, f := range args {
+		f(&opts)
+	}
+	return newClient(opts)
+}
+
+// newClient creates a client with the options provided.
+func newClient(opts clientOptions) *Client {
+	b := opts.backend
+	if b == nil {
+		b = opts.windowsBackend()
+	}
+
+	if opts.logger != nil {
+		b = opts.logger(b)
+	}
+
+	c := &Client{backend: b}
+
+	// Clients that share a back-end give equal distros. Back-ends that cannot be compared,
+	// such as structs holding a map, are only shared by the distros of this client.
+	c.id = c
+	if reflect.ValueOf(b).Comparable() {
+		c.id = b
+	}
+
+	return c
+}
+
+// windowsBackend returns the real back-end with the options that apply to it. Each option is
+// only applied if set, so that clients without them share the default back-end.
+func (o clientOptions) windowsBackend() backend.Backend {
+	w := windows.Backend{}
+	if o.governor != nil {
+		w = w.WithGovernor(o.governor)
+	}
+	if o.timeout > 0 {
+		w = w.WithTimeout(o.timeout)
+	}
+	if o.wslExe != "" {
+		w = w.WithWslExe(o.wslExe)
+	}
+	return w
+}
+
+// Distro declares a distro, but does not register it nor check if it exists.
+func (c *Client) Distro(name string) Distro {
+	return Distro{
+		backend: c.backend,
+		id:      c.id,
+		name:    name,
+	}
+}
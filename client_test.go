@@ -0,0 +1,52 @@
+package gowsl_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	// Clients do not need the gowslmock build tag.
+	m := mock.New()
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := wsl.NewClient(wsl.WithClientBackend(m), wsl.WithClientLogger(logger))
+
+	ctx := context.Background()
+	name := uniqueDistroName(t)
+
+	d, err := c.Import(ctx, name, rootFS, t.TempDir())
+	require.NoError(t, err, "Import should have succeeded")
+	require.Len(t, m.Calls("Import"), 1, "Import should have reached the client's back-end")
+	require.Contains(t, out.String(), "method=Import", "Calls to the back-end should have been logged")
+	require.True(t, d.Equal(c.Distro(name)), "Distros of the same client should be equal")
+
+	distros, err := c.List(ctx)
+	require.NoError(t, err, "List should have succeeded")
+	require.True(t, slices.ContainsFunc(distros, d.Equal), "List should have returned the imported distro")
+
+	require.NoError(t, d.SetAsDefault(ctx), "SetAsDefault should have succeeded")
+	def, ok, err := c.DefaultDistro(ctx)
+	require.NoError(t, err, "DefaultDistro should have succeeded")
+	require.True(t, ok, "There should be a default distro")
+	require.True(t, d.Equal(def), "DefaultDistro should have returned the imported distro")
+
+	require.NoError(t, c.Shutdown(ctx), "Shutdown should have succeeded")
+	require.Len(t, m.Calls("Shutdown"), 1, "Shutdown should have reached the client's back-end")
+
+	other := wsl.NewClient(wsl.WithClientBackend(mock.New()))
+	require.False(t, d.Equal(other.Distro(name)), "Distros of different clients should not be equal")
+
+	registered, err := other.Distro(name).IsRegistered(ctx)
+	require.NoError(t, err, "IsRegistered should have succeeded")
+	require.False(t, registered, "Distros should only exist in their client's back-end")
+}
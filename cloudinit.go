@@ -0,0 +1,149 @@
+package gowsl
+
+// This file contains the provisioning of new distros with cloud-init.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+)
+
+// RegisterOption is an optional parameter for (*Distro).Register and Import.
+// Use any of the provided functions such as WithCloudInit().
+type RegisterOption func(*registerOptions)
+
+type registerOptions struct {
+	userData     []byte
+	cloudInitDir string
+}
+
+// WithCloudInit is an optional parameter for (*Distro).Register and Import that provisions
+// the new distro with the cloud-init user-data. It is placed where the WSL datasource of
+// cloud-init looks for it, and removed once cloud-init is done. Registering only returns
+// once `cloud-init status --wait` does.
+//
+// If cloud-init reports errors, registering returns a *CloudInitError with its status. The
+// distro is registered nonetheless, as it is if its status cannot be found out.
+func WithCloudInit(userData []byte) RegisterOption {
+	return func(o *registerOptions) {
+		o.userData = userData
+	}
+}
+
+// WithCloudInitDir is an optional parameter for (*Distro).Register and Import that sets the
+// directory WithCloudInit writes the user-data to. It defaults to .cloud-init in the home
+// directory of the Windows user, which is where the WSL datasource looks for it.
+func WithCloudInitDir(dir string) RegisterOption {
+	return func(o *registerOptions) {
+		o.cloudInitDir = dir
+	}
+}
+
+// CloudInitStatus is the status of cloud-init in a distro, as reported by `cloud-init status`.
+type CloudInitStatus struct {
+	Status            string              `json:"status"`             // Such as "done", "error" or "disabled"
+	ExtendedStatus    string              `json:"extended_status"`    // Such as "degraded done" when there were recoverable errors
+	Detail            string              `json:"detail"`             // Description of the status
+	Errors            []string            `json:"errors"`             // Errors that stopped cloud-init
+	RecoverableErrors map[string][]string `json:"recoverable_errors"` // Warnings and deprecations, by log level
+}
+
+// Failed returns true if cloud-init failed to provision the distro. Recoverable errors are not
+// failures.
+func (s CloudInitStatus) Failed() bool {
+	return s.Status == "error" || len(s.Errors) > 0
+}
+
+// CloudInitError is the error returned when cloud-init fails to provision a distro.
+type CloudInitError struct {
+	Status CloudInitStatus
+}
+
+// Error makes it so CloudInitError implements the error interface. It displays the errors
+// reported by cloud-init.
+func (e *CloudInitError) Error() string {
+	if len(e.Status.Errors) == 0 {
+		return fmt.Sprintf("cloud-init failed with status %q: %s", e.Status.Status, e.Status.Detail)
+	}
+	return fmt.Sprintf("cloud-init failed with status %q: %s", e.Status.Status, strings.Join(e.Status.Errors, "; "))
+}
+
+// CloudInitStatus waits for cloud-init to be done in the distro, and returns its status. It
+// starts the distro if needed, and fails if cloud-init is not installed.
+func (d *Distro) CloudInitStatus(ctx context.Context) (s CloudInitStatus, err error) {
+	defer decorate.OnError(&err, "could not get the cloud-init status of %s", d.name)
+
+	out, err := d.Command(ctx, "cloud-init status --wait --format=json").Output()
+
+	// cloud-init exits with 1 on errors and 2 on recoverable errors, and reports them all the same.
+	var exitErr *ExitError
+	if err != nil && (!errors.As(err, &exitErr) || len(out) == 0) {
+		return s, err
+	}
+
+	if err := json.Unmarshal(out, &s); err != nil {
+		return s, fmt.Errorf("could not parse the status: %v", err)
+	}
+
+	return s, nil
+}
+
+// provision registers the distro with the register function, and provisions it according to the options.
+func (d *Distro) provision(ctx context.Context, register func() error, opts registerOptions) (err error) {
+	if opts.userData == nil {
+		return register()
+	}
+
+	dir := opts.cloudInitDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("could not find the cloud-init directory: %v", err)
+		}
+		dir = filepath.Join(home, ".cloud-init")
+	}
+
+	// The WSL datasource looks for the user-data of each distro by name.
+	path := filepath.Join(dir, d.name+".user-data")
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("could not create the cloud-init directory: %v", err)
+	}
+
+	// The user-data of someone else must be neither overwritten nor removed.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("cloud-init user-data already exists at %s", path)
+	} else if err != nil {
+		return fmt.Errorf("could not write the cloud-init user-data: %v", err)
+	}
+	defer os.Remove(path)
+
+	_, err = f.Write(opts.userData)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return fmt.Errorf("could not write the cloud-init user-data: %v", err)
+	}
+
+	if err := register(); err != nil {
+		return err
+	}
+
+	status, err := d.CloudInitStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Failed() {
+		return &CloudInitError{Status: status}
+	}
+
+	return nil
+}
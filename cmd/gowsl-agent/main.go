@@ -0,0 +1,103 @@
+// Command gowsl-agent serves the WSL of a Windows host over HTTP, so that GoWSL can manage its
+// distros from another machine via the remote back-end:
+//
+//	set GOWSL_AGENT_TOKEN=s3cr3t
+//	gowsl-agent -addr :8765 -tls-cert cert.pem -tls-key key.pem
+//
+// Clients authenticate with the token in the GOWSL_AGENT_TOKEN environment variable. The agent
+// refuses to serve plain HTTP on addresses other than loopback ones, so that the token is not
+// sent in clear text over the network, unless -insecure is set. With -mock, the agent serves a
+// mock back-end instead, which works on Linux too.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/remote"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("gowsl-agent: %v", err)
+	}
+}
+
+func run() error {
+	addr := flag.String("addr", "127.0.0.1:8765", "address to listen on")
+	certFile := flag.String("tls-cert", "", "certificate to serve over TLS")
+	keyFile := flag.String("tls-key", "", "private key of the TLS certificate")
+	insecure := flag.Bool("insecure", false, "allow serving plain HTTP on addresses other than loopback ones")
+	useMock := flag.Bool("mock", false, "serve a mock back-end instead of the real WSL")
+	flag.Parse()
+
+	if (*certFile == "") != (*keyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be used together")
+	}
+
+	if *certFile == "" && !*insecure && !isLoopback(*addr) {
+		return fmt.Errorf("refusing to serve plain HTTP on %s, which is reachable from other machines: use -tls-cert and -tls-key, or -insecure", *addr)
+	}
+
+	var b backend.Backend = wsl.DefaultBackend()
+	if *useMock {
+		b = mock.New()
+	}
+
+	handler, err := remote.NewServer(b, os.Getenv("GOWSL_AGENT_TOKEN"))
+	if err != nil {
+		return fmt.Errorf("could not create server: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+
+	log.Printf("gowsl-agent: listening on %s", *addr)
+	if *certFile != "" {
+		err = server.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// isLoopback reports whether the address only listens on the loopback interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
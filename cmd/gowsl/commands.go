@@ -0,0 +1,506 @@
+package main
+
+// This file contains the subcommands of gowsl.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	wsl "github.com/ubuntu/gowsl"
+)
+
+// distroInfo describes a distro in the output of gowsl.
+type distroInfo struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	GUID    string `json:"guid,omitempty"`
+	Version uint8  `json:"version,omitempty"` // WSL version
+	Default bool   `json:"default"`
+}
+
+// configInfo describes the configuration of a distro in the output of gowsl.
+type configInfo struct {
+	DefaultUID    uint32            `json:"defaultUid"`
+	Interop       bool              `json:"interop"`
+	PathAppended  bool              `json:"pathAppended"`
+	DriveMounting bool              `json:"driveMounting"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// configKeys are the keys accepted by `gowsl config`, in the order they are printed.
+var configKeys = []string{"default-uid", "interop", "path-appended", "drive-mounting"}
+
+// get returns the value of a key of the configuration.
+func (c configInfo) get(key string) (any, error) {
+	switch key {
+	case "default-uid":
+		return c.DefaultUID, nil
+	case "interop":
+		return c.Interop, nil
+	case "path-appended":
+		return c.PathAppended, nil
+	case "drive-mounting":
+		return c.DriveMounting, nil
+	}
+	return nil, usageError{fmt.Sprintf("unknown key %q: use one of %s", key, strings.Join(configKeys, ", "))}
+}
+
+// describe returns the description of the distros, in the order provided.
+func (a *app) describe(ctx context.Context, distros ...wsl.Distro) ([]distroInfo, error) {
+	def, hasDefault, err := a.client.DefaultDistro(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]distroInfo, 0, len(distros))
+	for _, d := range distros {
+		s, err := d.State(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		info := distroInfo{Name: d.Name(), State: s.String()}
+		if s == wsl.NonRegistered {
+			infos = append(infos, info)
+			continue
+		}
+
+		guid, err := d.GUID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		info.GUID = guid.String()
+
+		conf, err := d.GetConfiguration(ctx)
+		if err != nil {
+			return nil, err
+		}
+		info.Version = conf.UndocumentedWSLVersion
+		info.Default = hasDefault && strings.EqualFold(def.Name(), d.Name())
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// printDistros prints the description of the distros, as a table for humans.
+func (a *app) printDistros(infos []distroInfo) {
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tSTATE\tVERSION\tGUID")
+	for _, i := range infos {
+		mark := " "
+		if i.Default {
+			mark = "*"
+		}
+		version := "-"
+		if i.Version != 0 {
+			version = strconv.Itoa(int(i.Version))
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\n", mark, i.Name, i.State, version, i.GUID)
+	}
+	w.Flush()
+}
+
+// printDistro prints the resulting state of a distro after a command changed it.
+func (a *app) printDistro(ctx context.Context, d wsl.Distro) error {
+	infos, err := a.describe(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	return a.print(infos[0], func(io.Writer) { a.printDistros(infos) })
+}
+
+// configuration returns the configuration of a distro.
+func configuration(ctx context.Context, d wsl.Distro) (configInfo, error) {
+	conf, err := d.GetConfiguration(ctx)
+	if err != nil {
+		return configInfo{}, err
+	}
+
+	return configInfo{
+		DefaultUID:    conf.DefaultUID,
+		Interop:       conf.InteropEnabled,
+		PathAppended:  conf.PathAppended,
+		DriveMounting: conf.DriveMountingEnabled,
+		Env:           conf.DefaultEnvironmentVariables,
+	}, nil
+}
+
+// printConfig prints the configuration for humans, one key per line.
+func printConfig(w io.Writer, c configInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	writeConfig(tw, c)
+	tw.Flush()
+}
+
+// writeConfig writes the configuration into a tabwriter, one key per line.
+func writeConfig(tw io.Writer, c configInfo) {
+	for _, key := range configKeys {
+		v, _ := c.get(key)
+		fmt.Fprintf(tw, "%s:\t%v\n", key, v)
+	}
+
+	env := make([]string, 0, len(c.Env))
+	for k, v := range c.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	fmt.Fprintf(tw, "env:\t%s\n", strings.Join(env, " "))
+}
+
+func list(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return err
+	}
+
+	distros, err := a.client.List(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(distros, func(i, j int) bool { return distros[i].Name() < distros[j].Name() })
+
+	infos, err := a.describe(ctx, distros...)
+	if err != nil {
+		return err
+	}
+
+	return a.print(infos, func(io.Writer) { a.printDistros(infos) })
+}
+
+func info(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return err
+	}
+
+	d := a.client.Distro(args[0])
+	infos, err := a.describe(ctx, d)
+	if err != nil {
+		return err
+	}
+	if infos[0].State == wsl.NonRegistered.String() {
+		return fmt.Errorf("distro %q: %w", d.Name(), wsl.ErrNotExist)
+	}
+
+	conf, err := configuration(ctx, d)
+	if err != nil {
+		return err
+	}
+
+	out := struct {
+		distroInfo
+		Config configInfo `json:"config"`
+	}{infos[0], conf}
+
+	return a.print(out, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(tw, "name:\t%s\n", out.Name)
+		fmt.Fprintf(tw, "state:\t%s\n", out.State)
+		fmt.Fprintf(tw, "guid:\t%s\n", out.GUID)
+		fmt.Fprintf(tw, "version:\t%d\n", out.Version)
+		fmt.Fprintf(tw, "default:\t%t\n", out.Default)
+		writeConfig(tw, conf)
+		tw.Flush()
+	})
+}
+
+func config(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return usageError{"missing subcommand: use get or set"}
+	}
+
+	switch args[0] {
+	case "get":
+		return configGet(ctx, a, args[1:])
+	case "set":
+		return configSet(ctx, a, args[1:])
+	}
+	return usageError{fmt.Sprintf("unknown subcommand %q: use get or set", args[0])}
+}
+
+func configGet(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 1, 2); err != nil {
+		return err
+	}
+
+	conf, err := configuration(ctx, a.client.Distro(args[0]))
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		return a.print(conf, func(w io.Writer) { printConfig(w, conf) })
+	}
+
+	v, err := conf.get(args[1])
+	if err != nil {
+		return err
+	}
+	return a.print(v, func(w io.Writer) { fmt.Fprintln(w, v) })
+}
+
+func configSet(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 3, 3); err != nil {
+		return err
+	}
+
+	d := a.client.Distro(args[0])
+	key, value := args[1], args[2]
+
+	var err error
+	switch key {
+	case "default-uid":
+		var uid uint64
+		if uid, err = strconv.ParseUint(value, 10, 32); err != nil {
+			return usageError{fmt.Sprintf("invalid UID %q", value)}
+		}
+		err = d.DefaultUID(ctx, uint32(uid))
+	case "interop", "path-appended", "drive-mounting":
+		var b bool
+		if b, err = strconv.ParseBool(value); err != nil {
+			return usageError{fmt.Sprintf("invalid boolean %q", value)}
+		}
+		switch key {
+		case "interop":
+			err = d.InteropEnabled(ctx, b)
+		case "path-appended":
+			err = d.PathAppended(ctx, b)
+		case "drive-mounting":
+			err = d.DriveMountingEnabled(ctx, b)
+		}
+	default:
+		_, err = configInfo{}.get(key)
+	}
+	if err != nil {
+		return err
+	}
+
+	conf, err := configuration(ctx, d)
+	if err != nil {
+		return err
+	}
+	return a.print(conf, func(w io.Writer) { printConfig(w, conf) })
+}
+
+// exitStatus turns the failure of a command in a distro into an exitError, so that gowsl exits
+// with the same code. Failures of WSL itself are returned as is, so that they are printed.
+func exitStatus(err error) error {
+	var target *wsl.ExitError
+	if errors.As(err, &target) && target.Origin == wsl.OriginLinux {
+		return exitError{int(target.ExitCode())}
+	}
+	return err
+}
+
+// commandStdin returns what to forward to the standard input of a command. Only pipes and
+// regular files are forwarded: other files, such as terminals, are copied into the command
+// until they are closed, which would keep it from returning.
+func commandStdin(r io.Reader) io.Reader {
+	f, ok := r.(*os.File)
+	if !ok {
+		return r
+	}
+
+	info, err := f.Stat()
+	if err != nil || (info.Mode()&os.ModeNamedPipe == 0 && !info.Mode().IsRegular()) {
+		return nil
+	}
+	return f
+}
+
+func runCommand(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 2, -1); err != nil {
+		return err
+	}
+
+	d := a.client.Distro(args[0])
+	cmd := d.Command(ctx, join(args[1:]))
+	cmd.Stdin = commandStdin(a.stdin)
+
+	if !a.json {
+		cmd.Stdout = a.stdout
+		cmd.Stderr = a.stderr
+		return exitStatus(cmd.Run())
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := exitStatus(cmd.Run())
+	var exitErr exitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+
+	out := struct {
+		ExitCode int    `json:"exitCode"`
+		Stdout   string `json:"stdout"`
+		Stderr   string `json:"stderr"`
+	}{exitErr.code, stdout.String(), stderr.String()}
+
+	if e := a.print(out, nil); e != nil {
+		return e
+	}
+	return err
+}
+
+func shell(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	useCWD := fs.Bool("cwd", false, "start in the current working directory")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+	args = fs.Args()
+
+	if err := checkArgs(args, 1, -1); err != nil {
+		return err
+	}
+
+	var opts []wsl.ShellOption
+	if *useCWD {
+		opts = append(opts, wsl.UseCWD())
+	}
+	if len(args) > 1 {
+		opts = append(opts, wsl.WithCommand(join(args[1:])))
+	}
+
+	d := a.client.Distro(args[0])
+	err := exitStatus(d.Shell(ctx, opts...))
+	var exitErr exitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+
+	if !a.json {
+		return err
+	}
+
+	out := struct {
+		ExitCode int `json:"exitCode"`
+	}{exitErr.code}
+
+	if e := a.print(out, nil); e != nil {
+		return e
+	}
+	return err
+}
+
+func importDistro(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 3, 3); err != nil {
+		return err
+	}
+
+	d, err := a.client.Import(ctx, args[0], args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	return a.printDistro(ctx, d)
+}
+
+func export(ctx context.Context, a *app, args []string) (err error) {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return err
+	}
+
+	d := a.client.Distro(args[0])
+	path := args[1]
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create tarball: %v", err)
+	}
+	defer func() {
+		if e := f.Close(); e != nil && err == nil {
+			err = fmt.Errorf("could not write tarball: %v", e)
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}()
+
+	// Other file systems, such as /proc or the Windows drives, are not part of the distro.
+	cmd := d.Command(ctx, "tar -c -p -z --numeric-owner --one-file-system -f - -C / .")
+	cmd.Stdout = f
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not export %s: %w", d.Name(), err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not write tarball: %v", err)
+	}
+
+	out := struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+	}{d.Name(), path, info.Size()}
+
+	return a.print(out, func(w io.Writer) {
+		fmt.Fprintf(w, "Exported %s to %s (%d bytes)\n", out.Name, out.Path, out.Size)
+	})
+}
+
+func register(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 2, 2); err != nil {
+		return err
+	}
+
+	d := a.client.Distro(args[0])
+	if err := d.Register(ctx, args[1]); err != nil {
+		return err
+	}
+
+	return a.printDistro(ctx, d)
+}
+
+func unregister(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return err
+	}
+
+	d := a.client.Distro(args[0])
+	if err := d.Unregister(ctx); err != nil {
+		return err
+	}
+
+	return a.printDistro(ctx, d)
+}
+
+func terminate(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 1, 1); err != nil {
+		return err
+	}
+
+	d := a.client.Distro(args[0])
+	if err := d.Terminate(ctx); err != nil {
+		return err
+	}
+
+	return a.printDistro(ctx, d)
+}
+
+func shutdown(ctx context.Context, a *app, args []string) error {
+	if err := checkArgs(args, 0, 0); err != nil {
+		return err
+	}
+
+	if err := a.client.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	return list(ctx, a, nil)
+}
@@ -0,0 +1,270 @@
+// Command gowsl manages WSL distros from the command line with GoWSL:
+//
+//	gowsl list
+//	gowsl --json info Ubuntu
+//	gowsl config set Ubuntu default-uid 1000
+//	gowsl run Ubuntu -- uname -a
+//
+// Run gowsl without arguments for the list of commands. With --json, every command prints a
+// single JSON value to stdout, and errors are printed as {"error": "..."} to stderr, so that
+// scripts can rely on the output. Commands that change a distro print its resulting state.
+//
+// With --mock, gowsl uses a mock back-end instead of the real WSL, which works on Linux too. Its
+// distros are forgotten when gowsl exits, unless --mock-dir is set: their state and root
+// filesystems are then kept in that directory, and found there by the next invocation.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// app holds what the commands need to do their job and print their results.
+type app struct {
+	client *wsl.Client
+	json   bool
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command is a subcommand of gowsl.
+type command struct {
+	usage string // Arguments of the command
+	help  string // One-line description
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"list":       {"", "List the registered distros with their state, GUID and version", list},
+	"info":       {"DISTRO", "Show the state and configuration of a distro", info},
+	"config":     {"get DISTRO [KEY] | set DISTRO KEY VALUE", "Get or set the configuration of a distro", config},
+	"run":        {"DISTRO [--] COMMAND...", "Run a command in a distro", runCommand},
+	"shell":      {"[-cwd] DISTRO [[--] COMMAND...]", "Start an interactive shell in a distro", shell},
+	"import":     {"DISTRO TARBALL DIRECTORY", "Import a distro from a tarball, with its disk in a directory", importDistro},
+	"export":     {"DISTRO TARBALL", "Export the root filesystem of a distro to a gzipped tarball", export},
+	"register":   {"DISTRO TARBALL", "Register a distro from a tarball", register},
+	"unregister": {"DISTRO", "Unregister a distro, deleting its files", unregister},
+	"terminate":  {"DISTRO", "Stop a distro", terminate},
+	"shutdown":   {"", "Stop all distros and the WSL virtual machine", shutdown},
+}
+
+// usageError is returned when gowsl is called with the wrong arguments.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// exitError is returned when the command run in a distro fails, so that gowsl exits with its code.
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// run runs gowsl with the arguments provided, and returns its exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gowsl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { printUsage(fs) }
+
+	jsonOutput := fs.Bool("json", false, "print the output as JSON")
+	useMock := fs.Bool("mock", false, "use a mock back-end instead of the real WSL")
+	mockDir := fs.String("mock-dir", "", "keep the distros of the mock back-end in this directory")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	a := &app{json: *jsonOutput, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		if fs.NArg() == 0 {
+			printUsage(fs)
+		} else {
+			a.printError(usageError{fmt.Sprintf("unknown command %q", fs.Arg(0))})
+		}
+		return 2
+	}
+
+	if *mockDir != "" && !*useMock {
+		a.printError(usageError{"--mock-dir requires --mock"})
+		return 2
+	}
+
+	err := func() (err error) {
+		var opts []wsl.ClientOption
+		if *useMock {
+			m, save, err := openMock(*mockDir)
+			if err != nil {
+				return err
+			}
+			defer func() { err = errors.Join(err, save()) }()
+			opts = append(opts, wsl.WithClientBackend(m))
+		}
+		a.client = wsl.NewClient(opts...)
+
+		return cmd.run(ctx, a, fs.Args()[1:])
+	}()
+
+	var exitErr exitError
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case errors.As(err, &usageErr):
+		a.printError(fmt.Errorf("%s: %v\nusage: gowsl %s %s", fs.Arg(0), err, fs.Arg(0), cmd.usage))
+		return 2
+	default:
+		a.printError(err)
+		return 1
+	}
+}
+
+func printUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: gowsl [--json] [--mock [--mock-dir DIR]] COMMAND [ARGS]")
+	fmt.Fprintln(w, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-11s %s\n", name, commands[name].help)
+	}
+
+	fmt.Fprintln(w, "\nOptions:")
+	fs.PrintDefaults()
+}
+
+// openMock creates the mock back-end, sandboxed so that commands run in the distros do not touch
+// the host. If dir is set, the back-end is loaded from it, and the returned function saves the
+// back-end into it. Otherwise, it removes the sandbox.
+func openMock(dir string) (m *mock.Backend, save func() error, err error) {
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "gowsl-mock-*")
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not create mock sandbox: %v", err)
+		}
+		return mock.New(mock.WithSandbox(tmp)), func() error { return os.RemoveAll(tmp) }, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("could not create mock directory: %v", err)
+	}
+
+	path := filepath.Join(dir, "state.yaml")
+	m = mock.New(mock.WithSandbox(dir))
+
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		if m, err = mock.Load(f, mock.WithSandbox(dir)); err != nil {
+			return nil, nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("could not open mock state: %v", err)
+	}
+
+	save = func() error {
+		if err := os.WriteFile(path, []byte(m.Snapshot().String()), 0600); err != nil {
+			return fmt.Errorf("could not save mock state: %v", err)
+		}
+		return nil
+	}
+
+	return m, save, nil
+}
+
+// print prints v as JSON, or calls text to print it for humans.
+func (a *app) print(v any, text func(w io.Writer)) error {
+	if !a.json {
+		text(a.stdout)
+		return nil
+	}
+
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printError prints the error to stderr, as JSON if requested.
+func (a *app) printError(err error) {
+	if !a.json {
+		fmt.Fprintf(a.stderr, "gowsl: %v\n", err)
+		return
+	}
+
+	out, _ := json.Marshal(map[string]string{"error": err.Error()})
+	fmt.Fprintf(a.stderr, "%s\n", out)
+}
+
+// checkArgs checks that the command received between minArgs and maxArgs arguments. A negative
+// maxArgs means no limit.
+func checkArgs(args []string, minArgs, maxArgs int) error {
+	if len(args) < minArgs {
+		return usageError{"not enough arguments"}
+	}
+	if maxArgs >= 0 && len(args) > maxArgs {
+		return usageError{fmt.Sprintf("unexpected argument %q", args[maxArgs])}
+	}
+	return nil
+}
+
+// join joins the words of a command line, quoting them so that the shell reads each of them as
+// a single word. A leading "--" is dropped.
+func join(words []string) string {
+	if len(words) > 0 && words[0] == "--" {
+		words = words[1:]
+	}
+
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = shellQuote(w)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// plainWord matches the words that the shell reads as is.
+var plainWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s so that the shell reads it as a single word. Words that need no quoting
+// are left as is, to keep command lines readable in errors.
+func shellQuote(s string) string {
+	if plainWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
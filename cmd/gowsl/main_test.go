@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommands(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		args         []string
+		notInstalled bool
+
+		wantCode   int
+		wantOutput any    // Expected JSON output
+		wantError  string // Expected substring of the error
+	}{
+		"Success listing distros": {args: []string{"list"}, wantOutput: []any{
+			map[string]any{"name": "ubuntu", "state": "Stopped", "guid": guid, "version": 2.0, "default": true},
+		}},
+		"Success listing no distros": {args: []string{"list"}, notInstalled: true, wantOutput: []any{}},
+		"Success getting info": {args: []string{"info", "Ubuntu"}, wantOutput: map[string]any{
+			"name": "Ubuntu", "state": "Stopped", "guid": guid, "version": 2.0, "default": true,
+			"config": map[string]any{"defaultUid": 1000.0, "interop": true, "pathAppended": true, "driveMounting": true, "env": map[string]any{"LANG": "C.UTF-8"}},
+		}},
+		"Success getting the configuration": {args: []string{"config", "get", "Ubuntu"}, wantOutput: map[string]any{
+			"defaultUid": 1000.0, "interop": true, "pathAppended": true, "driveMounting": true, "env": map[string]any{"LANG": "C.UTF-8"},
+		}},
+		"Success getting a key":     {args: []string{"config", "get", "Ubuntu", "default-uid"}, wantOutput: 1000.0},
+		"Success setting a key":     {args: []string{"config", "set", "Ubuntu", "interop", "false"}, wantOutput: map[string]any{"defaultUid": 1000.0, "interop": false, "pathAppended": true, "driveMounting": true, "env": map[string]any{"LANG": "C.UTF-8"}}},
+		"Success running a command": {args: []string{"run", "Ubuntu", "--", "exit", "0"}, wantOutput: map[string]any{"exitCode": 0.0, "stdout": "", "stderr": ""}},
+		"Success running a command with arguments that need quoting": {args: []string{"run", "Ubuntu", "printf", "%s|", "two  spaces", "it's", "$HOME"}, wantOutput: map[string]any{
+			"exitCode": 0.0, "stdout": "two  spaces|it's|$HOME|", "stderr": "",
+		}},
+		"Success registering a distro": {args: []string{"register", "Debian", rootfs}, wantOutput: map[string]any{
+			"name": "Debian", "state": "Stopped", "guid": anyValue, "version": 2.0, "default": false,
+		}},
+		"Success importing a distro": {args: []string{"import", "Debian", rootfs, "{{dir}}/Debian"}, wantOutput: map[string]any{
+			"name": "Debian", "state": "Stopped", "guid": anyValue, "version": 2.0, "default": false,
+		}},
+		"Success unregistering a distro": {args: []string{"unregister", "Ubuntu"}, wantOutput: map[string]any{"name": "Ubuntu", "state": "NotRegistered", "default": false}},
+		"Success terminating a distro":   {args: []string{"terminate", "Ubuntu"}, wantOutput: map[string]any{"name": "Ubuntu", "state": "Stopped", "guid": guid, "version": 2.0, "default": true}},
+		"Success shutting WSL down": {args: []string{"shutdown"}, wantOutput: []any{
+			map[string]any{"name": "ubuntu", "state": "Stopped", "guid": guid, "version": 2.0, "default": true},
+		}},
+
+		"Error with the exit code of a failed command": {args: []string{"run", "Ubuntu", "exit", "42"}, wantCode: 42, wantOutput: map[string]any{"exitCode": 42.0, "stdout": "", "stderr": ""}},
+
+		"Error when getting info on a distro that is not registered":   {args: []string{"info", "Debian"}, wantCode: 1, wantError: "distro does not exist"},
+		"Error when registering a distro that is already registered":   {args: []string{"register", "Ubuntu", rootfs}, wantCode: 1, wantError: "already"},
+		"Error when terminating a distro that is not registered":       {args: []string{"terminate", "Debian"}, wantCode: 1, wantError: "distro does not exist"},
+		"Error when getting an unknown key":                            {args: []string{"config", "get", "Ubuntu", "color"}, wantCode: 2, wantError: "unknown key"},
+		"Error when setting a key to an invalid value":                 {args: []string{"config", "set", "Ubuntu", "default-uid", "-1"}, wantCode: 2, wantError: "invalid UID"},
+		"Error when a command has too many arguments":                  {args: []string{"terminate", "Ubuntu", "Debian"}, wantCode: 2, wantError: "unexpected argument"},
+		"Error when a command has too few arguments":                   {args: []string{"register", "Debian"}, wantCode: 2, wantError: "not enough arguments"},
+		"Error when the command is unknown":                            {args: []string{"frobnicate"}, wantCode: 2, wantError: "unknown command"},
+		"Error when the configuration subcommand is unknown":           {args: []string{"config", "reset", "Ubuntu"}, wantCode: 2, wantError: "unknown subcommand"},
+		"Error when the mock directory is set without the mock itself": {args: []string{"--mock-dir", "{{dir}}", "list"}, wantCode: 2, wantError: "requires --mock"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if !tc.notInstalled {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "state.yaml"), []byte(fixture), 0600), "Setup: could not write mock state")
+			}
+
+			args := tc.args
+			if !strings.HasPrefix(args[0], "--") {
+				args = append([]string{"--mock", "--mock-dir", dir, "--json"}, args...)
+			} else {
+				args = append([]string{"--json"}, args...)
+			}
+			for i := range args {
+				args[i] = strings.ReplaceAll(args[i], "{{dir}}", dir)
+				args[i] = strings.ReplaceAll(args[i], rootfs, filepath.Join(dir, rootfs))
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(dir, rootfs), nil, 0600), "Setup: could not write rootfs")
+
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+			require.Equal(t, tc.wantCode, code, "Unexpected exit code. Stderr: %s", stderr.String())
+
+			if tc.wantError != "" {
+				require.Empty(t, stdout.String(), "Nothing should have been printed to stdout")
+
+				var got map[string]string
+				require.NoError(t, json.Unmarshal(stderr.Bytes(), &got), "Errors should be printed as JSON")
+				require.Contains(t, got["error"], tc.wantError, "Unexpected error")
+				return
+			}
+
+			var got any
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &got), "The output should be valid JSON")
+			requireMatch(t, tc.wantOutput, got)
+		})
+	}
+}
+
+func TestMockDirPersists(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rootfs := filepath.Join(dir, "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(rootfs, nil, 0600), "Setup: could not write rootfs")
+
+	gowsl := func(args ...string) string {
+		t.Helper()
+
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), append([]string{"--mock", "--mock-dir", dir}, args...), strings.NewReader(""), &stdout, &stderr)
+		require.Zero(t, code, "gowsl %s should have succeeded. Stderr: %s", strings.Join(args, " "), stderr.String())
+		return stdout.String()
+	}
+
+	gowsl("register", "Ubuntu", rootfs)
+	gowsl("config", "set", "Ubuntu", "default-uid", "1000")
+	require.Equal(t, "1000\n", gowsl("config", "get", "Ubuntu", "default-uid"), "The configuration should have been kept between invocations")
+	require.Contains(t, gowsl("list"), "* ubuntu", "The distro should have been kept between invocations")
+
+	gowsl("unregister", "Ubuntu")
+	require.NotContains(t, gowsl("list"), "ubuntu", "The distro should have been unregistered")
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), nil, strings.NewReader(""), &stdout, &stderr)
+	require.Equal(t, 2, code, "gowsl without a command should fail")
+
+	for name := range commands {
+		require.Contains(t, stderr.String(), "\n  "+name+" ", "The usage should list every command")
+	}
+}
+
+const (
+	guid   = "0f12d75f-9a85-42f1-8d7e-54c16086aa2f"
+	rootfs = "rootfs.tar.gz"
+
+	// anyValue matches any value in requireMatch.
+	anyValue = "<any>"
+)
+
+const fixture = `
+defaultDistro: Ubuntu
+distros:
+  - name: Ubuntu
+    guid: "{` + guid + `}"
+    defaultUid: 1000
+    env:
+      LANG: C.UTF-8
+`
+
+// requireMatch checks that got is equal to want, except for the values of want that are anyValue.
+func requireMatch(t *testing.T, want, got any) {
+	t.Helper()
+
+	if m, ok := want.(map[string]any); ok {
+		gotMap, ok := got.(map[string]any)
+		require.True(t, ok, "Expected an object, got %v", got)
+
+		keys := func(m map[string]any) (k []string) {
+			for key := range m {
+				k = append(k, key)
+			}
+			return k
+		}
+		require.ElementsMatch(t, keys(m), keys(gotMap), "Unexpected fields in %v", got)
+
+		for key, v := range m {
+			if v == anyValue {
+				continue
+			}
+			require.Equal(t, v, gotMap[key], "Unexpected value for %q", key)
+		}
+		return
+	}
+
+	if s, ok := want.([]any); ok {
+		gotSlice, ok := got.([]any)
+		require.True(t, ok, "Expected an array, got %v", got)
+		require.Len(t, gotSlice, len(s), "Unexpected number of elements in %v", got)
+		for i := range s {
+			requireMatch(t, s[i], gotSlice[i])
+		}
+		return
+	}
+
+	require.Equal(t, want, got, "Unexpected value")
+}
@@ -0,0 +1,411 @@
+package gowsl
+
+// This file contains utilities to copy files between the host and a distro.
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ubuntu/decorate"
+)
+
+// CopyProgress reports the progress of a copy between the host and a distro.
+type CopyProgress struct {
+	Path  string // Path of the entry being copied, relative to the parent of the source
+	Files int    // Number of entries copied so far, including directories and links
+	Bytes int64  // Number of bytes of file contents copied so far
+}
+
+// CopyOption is an optional parameter for (*Distro).CopyTo and (*Distro).CopyFrom.
+// Use any of the provided functions such as WithProgress().
+type CopyOption func(*copyOptions)
+
+type copyOptions struct {
+	progress func(CopyProgress)
+}
+
+// WithProgress is an optional parameter for (*Distro).CopyTo and (*Distro).CopyFrom that
+// makes them report their progress to the given callback. The callback is called every
+// time an entry starts being copied, and every time a chunk of file contents is copied.
+func WithProgress(f func(CopyProgress)) CopyOption {
+	return func(o *copyOptions) {
+		o.progress = f
+	}
+}
+
+// CopyTo copies a file or directory from the host into the distro, similarly to `cp -a`.
+// The copy is named after distroPath, whose parent directories are created if needed.
+// Relative distro paths are relative to the home directory of the default user.
+//
+// Permissions and symlinks are preserved. Ownership is preserved if the distro's default
+// user is root.
+//
+// The files are streamed as a tar archive into the distro's `tar` command.
+func (d *Distro) CopyTo(ctx context.Context, hostPath, distroPath string, opts ...CopyOption) (err error) {
+	defer decorate.OnError(&err, "could not copy %q from the host into %q in distro %s", hostPath, distroPath, d.name)
+
+	options := copyOptions{}
+	for _, f := range opts {
+		f(&options)
+	}
+
+	if _, err := os.Lstat(hostPath); err != nil {
+		return err
+	}
+
+	// The archive contains the full path of the destination, so that tar creates any missing parents.
+	root := "."
+	if path.IsAbs(distroPath) {
+		root = "/"
+	}
+	prefix := strings.TrimPrefix(path.Clean(distroPath), "/")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := d.Command(ctx, fmt.Sprintf("tar -x -p --numeric-owner -f - -C %s", shellQuote(root)))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	writeErr := writeTar(stdin, hostPath, prefix, options.progress)
+	if writeErr != nil {
+		// The command cannot be trusted to finish if the archive is truncated.
+		cancel()
+	}
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return errors.Join(writeErr, err)
+	}
+
+	return writeErr
+}
+
+// CopyFrom copies a file or directory from the distro into the host, similarly to `cp -a`.
+// The copy is named after hostPath, whose parent directories are created if needed.
+// Relative distro paths are relative to the home directory of the default user.
+//
+// Permissions and symlinks are preserved. Ownership is preserved only if the current
+// process has the privileges to do so.
+//
+// The files are streamed as a tar archive out of the distro's `tar` command.
+func (d *Distro) CopyFrom(ctx context.Context, distroPath, hostPath string, opts ...CopyOption) (err error) {
+	defer decorate.OnError(&err, "could not copy %q from distro %s into %q in the host", distroPath, d.name, hostPath)
+
+	options := copyOptions{}
+	for _, f := range opts {
+		f(&options)
+	}
+
+	distroPath = path.Clean(distroPath)
+	dir, base := path.Split(distroPath)
+	if dir == "" {
+		dir = "."
+	}
+	if base == "" {
+		// Copying the root directory
+		base = "."
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Using the ./ prefix prevents base names starting with dashes from being interpreted as options.
+	cmd := d.Command(ctx, fmt.Sprintf("tar -c --numeric-owner -f - -C %s %s", shellQuote(dir), shellQuote("./"+base)))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	readErr := readTar(stdout, base, hostPath, options.progress)
+	if readErr != nil {
+		// The command would block trying to write the rest of the archive.
+		cancel()
+	} else {
+		// Draining the pipe so that tar's trailing padding does not make it block.
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return errors.Join(readErr, err)
+	}
+
+	return readErr
+}
+
+// writeTar writes the file or directory at src into w as a tar archive.
+// All entries are renamed so that src becomes dst.
+func writeTar(w io.Writer, src, dst string, progress func(CopyProgress)) error {
+	tw := tar.NewWriter(w)
+	reporter := newProgressReporter(progress)
+
+	err := filepath.WalkDir(src, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(dst, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		// Names are not portable across machines, so we rely only on numeric IDs.
+		hdr.Uname = ""
+		hdr.Gname = ""
+		hdr.Format = tar.FormatPAX
+
+		reporter.entry(path.Join(filepath.Base(src), filepath.ToSlash(rel)))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, io.TeeReader(f, reporter))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// readTar extracts the tar archive in r, whose entries are all under src, renaming src to dst.
+func readTar(r io.Reader, src, dst string, progress func(CopyProgress)) error {
+	tr := tar.NewReader(r)
+	reporter := newProgressReporter(progress)
+
+	dst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+
+	// Directories are made read-only (if needed) and timestamped after their contents are written.
+	type dirInfo struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dirInfo
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := extractPath(hdr.Name, src, dst)
+		if err != nil {
+			return err
+		}
+		reporter.entry(hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirInfo{path: target, hdr: hdr})
+			continue
+		case tar.TypeReg:
+			if err := extractFile(tr, target, hdr, reporter); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := replaceWith(target, func() error { return os.Symlink(hdr.Linkname, target) }); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			old, err := extractPath(hdr.Linkname, src, dst)
+			if err != nil {
+				return err
+			}
+			if err := replaceWith(target, func() error { return os.Link(old, target) }); err != nil {
+				return err
+			}
+		default:
+			// Devices, FIFOs, etc. cannot be reliably created on the host.
+			continue
+		}
+
+		if err := setMetadata(target, hdr); err != nil {
+			return err
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := setMetadata(dirs[i].path, dirs[i].hdr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractPath maps the name of an entry in an archive whose root is src, into the
+// host path where it must be extracted. It fails if the entry would escape dst.
+func extractPath(name, src, dst string) (string, error) {
+	name = path.Clean(name)
+	src = path.Clean(src)
+
+	var rel string
+	switch {
+	case src == ".":
+		rel = name
+	case name == src:
+		rel = "."
+	case strings.HasPrefix(name, src+"/"):
+		rel = strings.TrimPrefix(name, src+"/")
+	default:
+		return "", fmt.Errorf("unexpected entry %q in the archive", name)
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return "", fmt.Errorf("entry %q in the archive escapes the destination", name)
+	}
+
+	// Writing through symlinks could escape the destination as well.
+	target := dst
+	for _, component := range strings.Split(rel, "/") {
+		if component == "." {
+			continue
+		}
+		if info, err := os.Lstat(target); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("entry %q in the archive is under a symlink", name)
+		}
+		target = filepath.Join(target, component)
+	}
+
+	return target, nil
+}
+
+func extractFile(r io.Reader, target string, hdr *tar.Header, reporter *progressReporter) error {
+	if err := replaceWith(target, func() error { return nil }); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, hdr.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, io.TeeReader(r, reporter)); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// replaceWith removes any non-directory at target, and then calls create.
+func replaceWith(target string, create func() error) error {
+	if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	return create()
+}
+
+// setMetadata sets the mode, ownership, and modification time of an extracted entry.
+func setMetadata(target string, hdr *tar.Header) error {
+	// Ownership can only be preserved by privileged users (and never on Windows,
+	// where Geteuid returns -1).
+	if os.Geteuid() == 0 {
+		if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+
+	if hdr.Typeflag == tar.TypeSymlink {
+		// Neither the mode nor the time of symlinks can be portably set.
+		return nil
+	}
+
+	// Applied after chown, as it may clear the setuid and setgid bits.
+	if err := os.Chmod(target, hdr.FileInfo().Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, time.Time{}, hdr.ModTime)
+}
+
+// progressReporter keeps track of the progress of a copy and reports it to a callback.
+// It is an io.Writer so that it can count the bytes copied.
+type progressReporter struct {
+	callback func(CopyProgress)
+	status   CopyProgress
+}
+
+func newProgressReporter(callback func(CopyProgress)) *progressReporter {
+	if callback == nil {
+		callback = func(CopyProgress) {}
+	}
+	return &progressReporter{callback: callback}
+}
+
+// entry reports that a new entry started being copied.
+func (p *progressReporter) entry(name string) {
+	p.status.Path = name
+	p.status.Files++
+	p.callback(p.status)
+}
+
+func (p *progressReporter) Write(b []byte) (int, error) {
+	p.status.Bytes += int64(len(b))
+	p.callback(p.status)
+	return len(b), nil
+}
@@ -0,0 +1,187 @@
+package gowsl_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+)
+
+func TestCopy(t *testing.T) {
+	if runtime.GOOS == "windows" && wsl.MockAvailable() {
+		t.Skip("Copying files is not supported by the mock on Windows")
+	}
+	ctx := setupSandboxedBackend(t, context.Background())
+
+	realDistro := newTestDistro(t, ctx, rootFS)
+	fakeDistro := wsl.NewDistro(ctx, uniqueDistroName(t))
+
+	testCases := map[string]struct {
+		distro      *wsl.Distro
+		singleFile  bool
+		missingHost bool
+
+		wantCopyToErr   bool
+		wantCopyFromErr bool
+	}{
+		"Success copying a directory": {distro: &realDistro},
+		"Success copying a file":      {distro: &realDistro, singleFile: true},
+
+		"Error when the distro is not registered": {distro: &fakeDistro, wantCopyToErr: true, wantCopyFromErr: true},
+		"Error when the host path does not exist": {distro: &realDistro, missingHost: true, wantCopyToErr: true, wantCopyFromErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			src := filepath.Join(t.TempDir(), "source")
+			populateCopySource(t, src, tc.singleFile)
+			if tc.missingHost {
+				src = filepath.Join(t.TempDir(), "does-not-exist")
+			}
+
+			// With the mock, commands run on the host so we need a path we can clean up.
+			distroPath := "/tmp/" + uniqueDistroName(t) + "/nested/copy"
+			if wsl.MockAvailable() {
+				distroPath = filepath.ToSlash(filepath.Join(t.TempDir(), "nested", "copy"))
+			}
+
+			var progress []wsl.CopyProgress
+			err := tc.distro.CopyTo(ctx, src, distroPath, wsl.WithProgress(func(p wsl.CopyProgress) {
+				progress = append(progress, p)
+			}))
+			if tc.wantCopyToErr {
+				require.Error(t, err, "CopyTo should have failed")
+			} else {
+				require.NoError(t, err, "CopyTo should have succeeded")
+				requireCopyProgress(t, src, progress)
+			}
+
+			dst := filepath.Join(t.TempDir(), "deeply", "nested", "destination")
+
+			progress = nil
+			err = tc.distro.CopyFrom(ctx, distroPath, dst, wsl.WithProgress(func(p wsl.CopyProgress) {
+				progress = append(progress, p)
+			}))
+			if tc.wantCopyFromErr {
+				require.Error(t, err, "CopyFrom should have failed")
+				return
+			}
+			require.NoError(t, err, "CopyFrom should have succeeded")
+			requireCopyProgress(t, src, progress)
+
+			requireSameTree(t, src, dst)
+		})
+	}
+}
+
+func TestCopyFromMissingPath(t *testing.T) {
+	if runtime.GOOS == "windows" && wsl.MockAvailable() {
+		t.Skip("Copying files is not supported by the mock on Windows")
+	}
+	ctx := setupSandboxedBackend(t, context.Background())
+
+	d := newTestDistro(t, ctx, rootFS)
+
+	err := d.CopyFrom(ctx, "/this/path/does/not/exist", filepath.Join(t.TempDir(), "destination"))
+	require.Error(t, err, "CopyFrom should fail when the distro path does not exist")
+
+	var target *wsl.ExitError
+	require.ErrorAs(t, err, &target, "CopyFrom should return an ExitError when tar fails")
+	require.NotEmpty(t, target.Stderr, "The ExitError should contain tar's stderr")
+}
+
+// populateCopySource creates a file or a directory tree at path to be copied.
+func populateCopySource(t *testing.T, path string, singleFile bool) {
+	t.Helper()
+
+	if singleFile {
+		err := os.WriteFile(path, []byte("Hello, world!\n"), 0640)
+		require.NoError(t, err, "Setup: could not write file")
+		return
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(path, "subdir", "empty"), 0750), "Setup: could not create directories")
+	require.NoError(t, os.WriteFile(filepath.Join(path, "file.txt"), []byte("Hello, world!\n"), 0600), "Setup: could not write file")
+	require.NoError(t, os.WriteFile(filepath.Join(path, "subdir", "script.sh"), []byte("#!/bin/sh\nexit 0\n"), 0700), "Setup: could not write file")
+	require.NoError(t, os.WriteFile(filepath.Join(path, "-dashed"), nil, 0644), "Setup: could not write file")
+	require.NoError(t, os.Chmod(filepath.Join(path, "subdir", "empty"), 0500), "Setup: could not change permissions")
+
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink("subdir/script.sh", filepath.Join(path, "link")), "Setup: could not create symlink")
+	}
+}
+
+// requireSameTree checks that two file trees have the same structure, contents, and permissions.
+func requireSameTree(t *testing.T, want, got string) {
+	t.Helper()
+
+	err := filepath.WalkDir(want, func(path string, wantEntry fs.DirEntry, err error) error {
+		require.NoError(t, err, "could not walk source tree")
+
+		rel, err := filepath.Rel(want, path)
+		require.NoError(t, err, "could not find relative path")
+		gotPath := filepath.Join(got, rel)
+
+		wantInfo, err := wantEntry.Info()
+		require.NoError(t, err, "could not stat %q", path)
+		gotInfo, err := os.Lstat(gotPath)
+		require.NoError(t, err, "could not stat %q", gotPath)
+
+		require.Equal(t, wantInfo.Mode(), gotInfo.Mode(), "mismatched mode for %q", rel)
+
+		switch {
+		case wantInfo.Mode()&fs.ModeSymlink != 0:
+			wantLink, err := os.Readlink(path)
+			require.NoError(t, err, "could not read link %q", path)
+			gotLink, err := os.Readlink(gotPath)
+			require.NoError(t, err, "could not read link %q", gotPath)
+			require.Equal(t, wantLink, gotLink, "mismatched symlink target for %q", rel)
+		case wantInfo.Mode().IsRegular():
+			wantContents, err := os.ReadFile(path)
+			require.NoError(t, err, "could not read %q", path)
+			gotContents, err := os.ReadFile(gotPath)
+			require.NoError(t, err, "could not read %q", gotPath)
+			require.Equal(t, string(wantContents), string(gotContents), "mismatched contents for %q", rel)
+			require.WithinDuration(t, wantInfo.ModTime(), gotInfo.ModTime(), time.Second, "mismatched modification time for %q", rel)
+		}
+
+		return nil
+	})
+	require.NoError(t, err, "could not compare trees")
+}
+
+// requireCopyProgress checks that the last progress report accounts for all the entries and bytes in the tree.
+func requireCopyProgress(t *testing.T, root string, progress []wsl.CopyProgress) {
+	t.Helper()
+
+	var wantFiles int
+	var wantBytes int64
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		require.NoError(t, err, "could not walk tree")
+		info, err := entry.Info()
+		require.NoError(t, err, "could not stat %q", path)
+
+		wantFiles++
+		if info.Mode().IsRegular() {
+			wantBytes += info.Size()
+		}
+		return nil
+	})
+	require.NoError(t, err, "could not walk tree")
+
+	require.NotEmpty(t, progress, "Progress should have been reported")
+	last := progress[len(progress)-1]
+	require.Equal(t, wantFiles, last.Files, "Unexpected number of files in the last progress report")
+	require.Equal(t, wantBytes, last.Bytes, "Unexpected number of bytes in the last progress report")
+}
@@ -11,7 +11,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/ubuntu/decorate"
-	"github.com/ubuntu/gowsl/internal/backend"
+	"github.com/ubuntu/gowsl/backend"
 	"github.com/ubuntu/gowsl/internal/flags"
 	"github.com/ubuntu/gowsl/internal/state"
 )
@@ -19,6 +19,7 @@ import (
 // Distro is an abstraction around a WSL distro.
 type Distro struct {
 	backend backend.Backend
+	id      any
 	name    string
 }
 
@@ -32,21 +33,20 @@ const (
 	Running       = state.Running
 	Installing    = state.Installing
 	Uninstalling  = state.Uninstalling
+	Converting    = state.Converting
 	NonRegistered = state.NotRegistered
 )
 
 // NewDistro declares a new distribution, but does not register it nor
 // check if it exists.
 func NewDistro(ctx context.Context, name string) Distro {
-	return Distro{
-		backend: selectBackend(ctx),
-		name:    name,
-	}
+	return clientFromContext(ctx).Distro(name)
 }
 
-// Equal compares two distros for equality independent of their name casing.
+// Equal compares two distros for equality independent of their name casing. Distros
+// are only equal if they use the same back-end.
 func (d Distro) Equal(other Distro) bool {
-	return d.backend == other.backend && strings.EqualFold(d.name, other.name)
+	return d.id == other.id && strings.EqualFold(d.name, other.name)
 }
 
 // Name is a getter for the DistroName as shown in "wsl.exe --list".
@@ -55,10 +55,10 @@ func (d Distro) Name() string {
 }
 
 // GUID returns the Global Unique IDentifier for the distro.
-func (d *Distro) GUID() (id uuid.UUID, err error) {
+func (d *Distro) GUID(ctx context.Context) (id uuid.UUID, err error) {
 	defer decorate.OnError(&err, "could not obtain GUID of %s", d.name)
 
-	distros, err := registeredDistros(d.backend)
+	distros, err := registeredDistros(ctx, d.backend)
 	if err != nil {
 		return id, err
 	}
@@ -70,10 +70,10 @@ func (d *Distro) GUID() (id uuid.UUID, err error) {
 }
 
 // State returns the current state of the distro.
-func (d *Distro) State() (s State, err error) {
+func (d *Distro) State(ctx context.Context) (s State, err error) {
 	defer decorate.OnError(&err, "could not get distro %q's state", d.Name())
 
-	registered, err := d.isRegistered()
+	registered, err := d.isRegistered(ctx)
 	if err != nil {
 		return s, err
 	}
@@ -81,15 +81,15 @@ func (d *Distro) State() (s State, err error) {
 		return state.NotRegistered, nil
 	}
 
-	return d.backend.State(d.Name())
+	return d.backend.State(ctx, d.Name())
 }
 
 // Terminate powers off the distro.
 // Equivalent to:
 //
 //	wsl --terminate <distro>
-func (d *Distro) Terminate() error {
-	return d.backend.Terminate(d.Name())
+func (d *Distro) Terminate(ctx context.Context) error {
+	return d.backend.Terminate(ctx, d.Name())
 }
 
 // Shutdown powers off all of WSL, including all other distros.
@@ -97,24 +97,37 @@ func (d *Distro) Terminate() error {
 //
 //	wsl --shutdown
 func Shutdown(ctx context.Context) error {
-	return selectBackend(ctx).Shutdown()
+	return clientFromContext(ctx).Shutdown(ctx)
+}
+
+// Shutdown powers off all of WSL, including all distros.
+// Equivalent to:
+//
+//	wsl --shutdown
+func (c *Client) Shutdown(ctx context.Context) error {
+	return c.backend.Shutdown(ctx)
 }
 
 // SetAsDefault sets a particular distribution as the default one.
 // Equivalent to:
 //
 //	wsl --set-default <distro>
-func (d *Distro) SetAsDefault() error {
-	return d.backend.SetAsDefault(d.Name())
+func (d *Distro) SetAsDefault(ctx context.Context) error {
+	return d.backend.SetAsDefault(ctx, d.Name())
 }
 
 // DefaultDistro gets the current default distribution.
 func DefaultDistro(ctx context.Context) (d Distro, ok bool, err error) {
+	return clientFromContext(ctx).DefaultDistro(ctx)
+}
+
+// DefaultDistro gets the current default distribution.
+func (c *Client) DefaultDistro(ctx context.Context) (d Distro, ok bool, err error) {
 	defer decorate.OnError(&err, "could not obtain the default distro")
-	backend := selectBackend(ctx)
+	backend := c.backend
 
 	// First, we find out the GUID of the default distro
-	r, err := backend.OpenLxssRegistry(".")
+	r, err := backend.OpenLxssRegistry(ctx, ".")
 	if err != nil {
 		return d, false, err
 	}
@@ -138,7 +151,7 @@ func DefaultDistro(ctx context.Context) (d Distro, ok bool, err error) {
 	}
 
 	// Last, we find out the name of the distro
-	r, err = backend.OpenLxssRegistry(guid)
+	r, err = backend.OpenLxssRegistry(ctx, guid)
 	if err != nil {
 		return d, false, err
 	}
@@ -149,7 +162,7 @@ func DefaultDistro(ctx context.Context) (d Distro, ok bool, err error) {
 		return d, false, err
 	}
 
-	return NewDistro(ctx, name), true, err
+	return c.Distro(name), true, err
 }
 
 // Configuration is the configuration of the distro.
@@ -161,63 +174,63 @@ type Configuration struct {
 }
 
 // DefaultUID sets the user to the one specified.
-func (d *Distro) DefaultUID(uid uint32) (err error) {
+func (d *Distro) DefaultUID(ctx context.Context, uid uint32) (err error) {
 	defer decorate.OnError(&err, "could not modify flag DEFAULT_UID for %s", d.name)
 
-	conf, err := d.GetConfiguration()
+	conf, err := d.GetConfiguration(ctx)
 	if err != nil {
 		return err
 	}
 	conf.DefaultUID = uid
-	return d.configure(conf)
+	return d.configure(ctx, conf)
 }
 
 // InteropEnabled sets the ENABLE_INTEROP flag to the provided value.
 // Enabling allows you to launch Windows executables from WSL.
-func (d *Distro) InteropEnabled(value bool) (err error) {
+func (d *Distro) InteropEnabled(ctx context.Context, value bool) (err error) {
 	defer decorate.OnError(&err, "could not modify flag ENABLE_INTEROP for %s", d.name)
 
-	conf, err := d.GetConfiguration()
+	conf, err := d.GetConfiguration(ctx)
 	if err != nil {
 		return err
 	}
 	conf.InteropEnabled = value
-	return d.configure(conf)
+	return d.configure(ctx, conf)
 }
 
 // PathAppended sets the APPEND_NT_PATH flag to the provided value.
 // Enabling it allows WSL to append /mnt/c/... (or wherever your mount
 // point is) in front of Windows executables.
-func (d *Distro) PathAppended(value bool) (err error) {
+func (d *Distro) PathAppended(ctx context.Context, value bool) (err error) {
 	defer decorate.OnError(&err, "could not modify flag APPEND_NT_PATH for %s", d.name)
 
-	conf, err := d.GetConfiguration()
+	conf, err := d.GetConfiguration(ctx)
 	if err != nil {
 		return err
 	}
 	conf.PathAppended = value
-	return d.configure(conf)
+	return d.configure(ctx, conf)
 }
 
 // DriveMountingEnabled sets the ENABLE_DRIVE_MOUNTING flag to the provided value.
 // Enabling it mounts the windows filesystem into WSL's.
-func (d *Distro) DriveMountingEnabled(value bool) (err error) {
+func (d *Distro) DriveMountingEnabled(ctx context.Context, value bool) (err error) {
 	defer decorate.OnError(&err, "could not modify flag ENABLE_DRIVE_MOUNTING for %s", d.name)
 
-	conf, err := d.GetConfiguration()
+	conf, err := d.GetConfiguration(ctx)
 	if err != nil {
 		return err
 	}
 	conf.DriveMountingEnabled = value
-	return d.configure(conf)
+	return d.configure(ctx, conf)
 }
 
 // GetConfiguration is a wrapper around Win32's WslGetDistributionConfiguration.
 // It returns a configuration object with information about the distro.
-func (d Distro) GetConfiguration() (c Configuration, err error) {
+func (d Distro) GetConfiguration(ctx context.Context) (c Configuration, err error) {
 	defer decorate.OnError(&err, "could not access configuration for %s", d.name)
 
-	if err := d.mustBeRegistered(); err != nil {
+	if err := d.mustBeRegistered(ctx); err != nil {
 		return c, err
 	}
 
@@ -225,6 +238,7 @@ func (d Distro) GetConfiguration() (c Configuration, err error) {
 	var f flags.WslFlags
 
 	err = d.backend.WslGetDistributionConfiguration(
+		ctx,
 		d.Name(),
 		&conf.Version,
 		&conf.DefaultUID,
@@ -243,7 +257,7 @@ func (d Distro) GetConfiguration() (c Configuration, err error) {
 // String deserializes a distro its GUID and its configuration as a yaml string.
 // If there is an error, it is printed as part of the yaml.
 func (d Distro) String() string {
-	guid, err := d.GUID()
+	guid, err := d.GUID(context.Background())
 	if err != nil {
 		return fmt.Sprintf("WSL distro %q (not registered)", d.Name())
 	}
@@ -256,8 +270,8 @@ func (d Distro) String() string {
 //   - InteropEnabled
 //   - PathAppended
 //   - DriveMountingEnabled
-func (d *Distro) configure(config Configuration) error {
-	if err := d.mustBeRegistered(); err != nil {
+func (d *Distro) configure(ctx context.Context, config Configuration) error {
+	if err := d.mustBeRegistered(ctx); err != nil {
 		return err
 	}
 
@@ -266,5 +280,5 @@ func (d *Distro) configure(config Configuration) error {
 		return err
 	}
 
-	return d.backend.WslConfigureDistribution(d.Name(), config.DefaultUID, flags)
+	return d.backend.WslConfigureDistribution(ctx, d.Name(), config.DefaultUID, flags)
 }
@@ -41,9 +41,9 @@ func TestShutdown(t *testing.T) {
 
 			if tc.mockErr {
 				modifyMock(t, func(m *mock.Backend) {
-					m.ShutdownError = true
+					m.InjectFault("Shutdown", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			}
 
 			wakeDistroUp(t, d1)
@@ -87,9 +87,9 @@ func TestTerminate(t *testing.T) {
 
 			if tc.mockErr {
 				modifyMock(t, func(m *mock.Backend) {
-					m.TerminateError = true
+					m.InjectFault("Terminate", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			}
 
 			controlDistro := newTestDistro(t, ctx, rootFS)
@@ -103,7 +103,7 @@ func TestTerminate(t *testing.T) {
 			}
 			wakeDistroUp(t, controlDistro)
 
-			err := testDistro.Terminate()
+			err := testDistro.Terminate(ctx)
 			if tc.wantErr {
 				require.Error(t, err, "Terminate should have returned an error")
 				if tc.wantErrNotExist {
@@ -156,13 +156,13 @@ func TestDefaultDistro(t *testing.T) {
 
 			if tc.registryInaccessible {
 				modifyMock(t, func(m *mock.Backend) {
-					m.OpenLxssKeyError = true
+					m.InjectFault("OpenLxssRegistry", mock.Fault{})
 				})
 			}
 
 			if tc.overrideDefaultDistroRegistry != "" {
 				modifyMock(t, func(m *mock.Backend) {
-					k, err := m.OpenLxssRegistry(".")
+					k, err := m.OpenLxssRegistry(ctx, ".")
 					require.NoError(t, err, "Setup: could not open the lxss registry")
 					defer k.Close()
 
@@ -211,9 +211,9 @@ func TestDistroSetAsDefault(t *testing.T) {
 			ctx, modifyMock := setupBackend(t, context.Background())
 			if tc.wslexeError {
 				modifyMock(t, func(m *mock.Backend) {
-					m.SetAsDefaultError = true
+					m.InjectFault("SetAsDefault", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			}
 
 			var d wsl.Distro
@@ -223,7 +223,7 @@ func TestDistroSetAsDefault(t *testing.T) {
 				d = newTestDistro(t, ctx, rootFS)
 			}
 
-			err := d.SetAsDefault()
+			err := d.SetAsDefault(ctx)
 			if tc.wantErr {
 				require.Errorf(t, err, "Unexpected success setting non-existent distro %q as default", d.Name())
 				if tc.wantErrNotExist {
@@ -248,7 +248,7 @@ func TestDistroString(t *testing.T) {
 	fakeDistro := wsl.NewDistro(ctx, uniqueDistroName(t))
 	wrongDistro := wsl.NewDistro(ctx, uniqueDistroName(t)+"_\x00_invalid_name")
 
-	realGUID, err := realDistro.GUID()
+	realGUID, err := realDistro.GUID(ctx)
 	require.NoError(t, err, "could not get the test distro's GUID")
 
 	testCases := map[string]struct {
@@ -281,11 +281,11 @@ func TestGUID(t *testing.T) {
 	fakeDistro := wsl.NewDistro(ctx, uniqueDistroName(t))
 	wrongDistro := wsl.NewDistro(ctx, uniqueDistroName(t)+"\x00invalidcharacter")
 
-	err := realDistro.Register(rootFS)
+	err := realDistro.Register(ctx, rootFS)
 	require.NoError(t, err, "could not register empty distro")
 
 	//nolint:errcheck // We don't care about cleanup errors
-	t.Cleanup(func() { realDistro.Unregister() })
+	t.Cleanup(func() { realDistro.Unregister(ctx) })
 
 	// We cannot really assert on the GUID without re-implementing the distro.GUID() method,
 	// leading to circular logic that would test that our two implementations match rather
@@ -314,9 +314,9 @@ func TestGUID(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			if tc.registryInaccessible {
 				modifyMock(t, func(m *mock.Backend) {
-					m.OpenLxssKeyError = true
+					m.InjectFault("OpenLxssRegistry", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			} else {
 				// The backend is shared across subtests so tests with broken back-ends must run separately
 				t.Parallel()
@@ -326,7 +326,7 @@ func TestGUID(t *testing.T) {
 			upper := wsl.NewDistro(ctx, strings.ToUpper(tc.distro.Name()))
 			lower := wsl.NewDistro(ctx, strings.ToLower(tc.distro.Name()))
 			for _, d := range []*wsl.Distro{tc.distro, &upper, &lower} {
-				guid, err := d.GUID()
+				guid, err := d.GUID(ctx)
 				if tc.wantErr {
 					require.Error(t, err, "Unexpected success obtaining GUID of non-eligible distro")
 					if tc.wantNotExistErr {
@@ -415,9 +415,9 @@ func TestConfigurationSetters(t *testing.T) {
 			ctx, modifyMock := setupBackend(t, context.Background())
 			if tc.syscallError {
 				modifyMock(t, func(m *mock.Backend) {
-					m.WslConfigureDistributionError = true
+					m.InjectFault("WslConfigureDistribution", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			}
 
 			// details has info about each of the settings
@@ -454,16 +454,16 @@ func TestConfigurationSetters(t *testing.T) {
 			switch tc.setting {
 			case DefaultUID:
 				setWant(details, DefaultUID, uint32(1000))
-				err = d.DefaultUID(1000)
+				err = d.DefaultUID(ctx, 1000)
 			case InteropEnabled:
 				setWant(details, InteropEnabled, false)
-				err = d.InteropEnabled(false)
+				err = d.InteropEnabled(ctx, false)
 			case PathAppend:
 				setWant(details, PathAppend, false)
-				err = d.PathAppended(false)
+				err = d.PathAppended(ctx, false)
 			case DriveMounting:
 				setWant(details, DriveMounting, false)
-				err = d.DriveMountingEnabled(false)
+				err = d.DriveMountingEnabled(ctx, false)
 			}
 			if tc.wantErr {
 				require.Errorf(t, err, "unexpected success when setting config %s", details[tc.setting].name)
@@ -474,7 +474,7 @@ func TestConfigurationSetters(t *testing.T) {
 			}
 			require.NoErrorf(t, err, "unexpected success when setting config %s", details[tc.setting].name)
 
-			got, err := d.GetConfiguration()
+			got, err := d.GetConfiguration(ctx)
 			require.NoError(t, err, "unexpected failure getting configuration")
 
 			errorMsg[tc.setting] = fmt.Sprintf("config %s did not change to the expected value", details[tc.setting].name)
@@ -486,13 +486,13 @@ func TestConfigurationSetters(t *testing.T) {
 			// Test restore default
 			switch tc.setting {
 			case DefaultUID:
-				err = d.DefaultUID(0)
+				err = d.DefaultUID(ctx, 0)
 			case InteropEnabled:
-				err = d.InteropEnabled(true)
+				err = d.InteropEnabled(ctx, true)
 			case PathAppend:
-				err = d.PathAppended(true)
+				err = d.PathAppended(ctx, true)
 			case DriveMounting:
-				err = d.DriveMountingEnabled(true)
+				err = d.DriveMountingEnabled(ctx, true)
 			}
 			require.NoErrorf(t, err, "unexpected failure when setting %s back to the default", details[tc.setting].name)
 
@@ -500,7 +500,7 @@ func TestConfigurationSetters(t *testing.T) {
 			setWant(details, InteropEnabled, details[InteropEnabled].byDefault)
 			setWant(details, PathAppend, details[PathAppend].byDefault)
 			setWant(details, DriveMounting, details[DriveMounting].byDefault)
-			got, err = d.GetConfiguration()
+			got, err = d.GetConfiguration(ctx)
 			require.NoErrorf(t, err, "unexpected error calling GetConfiguration after reseting default value for %s", details[tc.setting].name)
 
 			errorMsg[tc.setting] = fmt.Sprintf("config %s was not set back to the default", details[tc.setting].name)
@@ -535,9 +535,9 @@ func TestGetConfiguration(t *testing.T) {
 			ctx, modifyMock := setupBackend(t, context.Background())
 			if tc.syscallError {
 				modifyMock(t, func(m *mock.Backend) {
-					m.WslGetDistributionConfigurationError = true
+					m.InjectFault("WslGetDistributionConfiguration", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			}
 
 			var d wsl.Distro
@@ -551,7 +551,7 @@ func TestGetConfiguration(t *testing.T) {
 			upper := wsl.NewDistro(ctx, strings.ToUpper(d.Name()))
 			lower := wsl.NewDistro(ctx, strings.ToLower(d.Name()))
 			for _, distro := range []*wsl.Distro{&d, &upper, &lower} {
-				c, err := distro.GetConfiguration()
+				c, err := distro.GetConfiguration(ctx)
 
 				if tc.wantErr {
 					require.Error(t, err, "unexpected success in GetConfiguration")
@@ -621,19 +621,19 @@ func TestDistroState(t *testing.T) {
 				d := asyncNewTestDistro(t, ctx, rootFS)
 				tc.distro = &d
 				require.Eventually(t, func() bool {
-					r, err := d.IsRegistered()
+					r, err := d.IsRegistered(ctx)
 					return err == nil && r
 				}, 10*time.Second, 100*time.Millisecond, "Setup: distro never started installing")
 			case command:
 				_, err := tc.distro.Command(ctx, "exit 0").Output()
 				require.NoError(t, err, "Setup: distro.Command should not return an error")
 			case terminate:
-				_ = tc.distro.Terminate()
+				_ = tc.distro.Terminate(ctx)
 			case injectError:
 				modifyMock(t, func(m *mock.Backend) {
-					m.StateError = true
+					m.InjectFault("State", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			default:
 				require.Failf(t, "Setup: unknown action enum", "Value: %d", tc.action)
 			}
@@ -642,7 +642,7 @@ func TestDistroState(t *testing.T) {
 			upper := wsl.NewDistro(ctx, strings.ToUpper(tc.distro.Name()))
 			lower := wsl.NewDistro(ctx, strings.ToLower(tc.distro.Name()))
 			for _, distro := range []*wsl.Distro{tc.distro, &upper, &lower} {
-				got, err := distro.State()
+				got, err := distro.State(ctx)
 				if tc.wantErr {
 					require.Error(t, err, "distro.State should return an error")
 					continue
@@ -733,7 +733,7 @@ func wakeDistroUp(t *testing.T, d wsl.Distro) {
 func requireStatef(t *testing.T, want wsl.State, d wsl.Distro, msg string, args ...any) {
 	t.Helper()
 
-	got, err := d.State()
+	got, err := d.State(context.Background())
 	require.NoErrorf(t, err, "Setup: could not run ascertain test distro state")
 	require.Equalf(t, want, got, msg, args)
 }
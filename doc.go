@@ -6,4 +6,12 @@
 // setting up WSL distros for every test-case can be quite time-consuming. This mock back-end
 // is disabled by default, and can be enabled by using the context returned by the WithMock
 // function.
+//
+// Other back-ends, such as wrappers that record or proxy the calls to WSL, can be plugged
+// in with the WithBackend function. See package backend, and its subpackages such as cassette,
+// local and remote. Use WithLogger to log every call to the back-end, when troubleshooting
+// failures that the errors alone do not explain.
+//
+// These functions read their configuration from the context. To configure it explicitly
+// instead, create a Client with NewClient, and obtain distros from it.
 package gowsl
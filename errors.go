@@ -0,0 +1,220 @@
+package gowsl
+
+// This file contains the error types shared by all the ways of launching commands into WSL.
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+// ErrNotExist is the error returned when a distro does not exist.
+var ErrNotExist = backend.ErrNotExist
+
+// ErrAbandoned is the error returned, along with the context's error, when an operation
+// that cannot be cancelled was still running when its context was done.
+var ErrAbandoned = backend.ErrAbandoned
+
+// ExitOrigin indicates on which side of WSL a command failed.
+type ExitOrigin int
+
+const (
+	// OriginLinux means that the Linux command was launched, and it returned a non-zero exit code.
+	OriginLinux ExitOrigin = iota
+	// OriginWindows means that WSL failed Windows-side, before or while launching the Linux command.
+	OriginWindows
+)
+
+func (o ExitOrigin) String() string {
+	switch o {
+	case OriginLinux:
+		return "Linux"
+	case OriginWindows:
+		return "Windows"
+	}
+	return fmt.Sprintf("Unknown origin %d", o)
+}
+
+// ExitError is the error returned by (*Distro).Shell and (*Cmd).Wait (and thus Run, Output,
+// and CombinedOutput) when the launched command does not succeed.
+//
+// We know that exit codes above 255 come from Windows, but exit codes under 256 can come
+// from both sides. These are attributed to Linux, as it is the most likely culprit.
+type ExitError struct {
+	// Origin is the side of WSL that the failure is attributed to.
+	Origin ExitOrigin
+
+	// HResult is the decoded exit code. It is only meaningful when Origin is OriginWindows.
+	HResult HResult
+
+	// Stderr holds the first and last few kilobytes of the standard error of the command,
+	// if it was not redirected by the caller.
+	Stderr []byte
+
+	exitCode uint32
+	signal   string // Description of the signal that killed the process, if any
+	err      error  // Underlying error, if any (e.g. *exec.ExitError)
+}
+
+// newExitError creates an ExitError, attributing it to Windows or Linux depending on the exit code.
+func newExitError(exitCode uint32, err error) *ExitError {
+	e := &ExitError{
+		Origin:   OriginLinux,
+		exitCode: exitCode,
+		err:      err,
+	}
+
+	if exitCode > 0xff {
+		e.Origin = OriginWindows
+		e.HResult = HResult(exitCode)
+	}
+
+	return e
+}
+
+// newExitErrorFromState creates an ExitError for a process that has exited, or returns nil if it
+// succeeded. Stub processes truncate their exit code to 8 bits on Linux, so their full code is
+// passed along if it is known. Processes killed by a signal are attributed to Linux, with the
+// exit code that shells report for them.
+func newExitErrorFromState(state *os.ProcessState, stubCode uint32, stubCodeKnown bool) *ExitError {
+	err := &exec.ExitError{ProcessState: state}
+
+	if stubCodeKnown {
+		if stubCode == 0 {
+			return nil
+		}
+		return newExitError(stubCode, err)
+	}
+
+	if state.Success() {
+		return nil
+	}
+
+	if state.Exited() {
+		return newExitError(uint32(state.ExitCode()), err) //nolint:gosec // Windows exit codes are DWORDs, so this conversion is lossless.
+	}
+
+	code := uint32(128)
+	if ws, ok := state.Sys().(interface{ Signal() syscall.Signal }); ok && ws.Signal() > 0 {
+		code += uint32(ws.Signal())
+	}
+
+	e := newExitError(code, err)
+	e.signal = state.String()
+	return e
+}
+
+// Error makes it so ExitError implements the error interface. It displays
+// the exit code and some auxiliary info.
+func (err *ExitError) Error() string {
+	if err.signal != "" {
+		return err.signal
+	}
+	if err.Origin == OriginWindows {
+		// Windows errors are commonly displayed in HEX, so we stick to the standard
+		return fmt.Sprintf("failed Windows-side: exit code %s", err.HResult)
+	}
+	// Linux exit codes are always displayed in decimal
+	return fmt.Sprintf("exit status %d", err.exitCode)
+}
+
+// ExitCode is a getter for the exit code of the command.
+// Experimentally we've seen that linux produces exit codes under 255, and
+// Windows produces them above or equal to 256. Processes killed by a signal
+// report 128 plus the signal number, as shells do.
+func (err *ExitError) ExitCode() uint32 {
+	return err.exitCode
+}
+
+// Unwrap returns the underlying error, if any. For commands launched via
+// (*Cmd).Start, this is an *exec.ExitError.
+func (err *ExitError) Unwrap() error {
+	return err.err
+}
+
+// ShellError returns error information when shell commands do not succeed.
+//
+// Deprecated: Use ExitError instead, which is also returned by (*Cmd).Wait.
+type ShellError = ExitError
+
+// HResult is a Windows HRESULT, which is what WSL returns as exit code when
+// something fails Windows-side.
+type HResult uint32
+
+// Well-known HRESULTs that WSL commonly returns.
+//
+//nolint:revive // Names mimic'ing Win32.
+const (
+	E_ILLEGAL_STATE_CHANGE            HResult = 0x8000000D
+	E_NOTIMPL                         HResult = 0x80004001
+	E_ABORT                           HResult = 0x80004004
+	E_FAIL                            HResult = 0x80004005
+	E_UNEXPECTED                      HResult = 0x8000FFFF
+	ERROR_FILE_NOT_FOUND              HResult = 0x80070002
+	E_ACCESSDENIED                    HResult = 0x80070005
+	E_OUTOFMEMORY                     HResult = 0x8007000E
+	E_INVALIDARG                      HResult = 0x80070057
+	ERROR_ALREADY_EXISTS              HResult = 0x800700B7
+	ERROR_LINUX_SUBSYSTEM_NOT_PRESENT HResult = 0x8007019E
+)
+
+// Layout of an HRESULT.
+const (
+	hresultSeverityBit  HResult = 0x80000000
+	hresultFacilityMask HResult = 0x1FFF0000
+	hresultCodeMask     HResult = 0x0000FFFF
+	facilityWin32       uint16  = 7
+)
+
+var hresultNames = map[HResult]string{
+	E_ILLEGAL_STATE_CHANGE:            "E_ILLEGAL_STATE_CHANGE",
+	E_NOTIMPL:                         "E_NOTIMPL",
+	E_ABORT:                           "E_ABORT",
+	E_FAIL:                            "E_FAIL",
+	E_UNEXPECTED:                      "E_UNEXPECTED",
+	ERROR_FILE_NOT_FOUND:              "ERROR_FILE_NOT_FOUND",
+	E_ACCESSDENIED:                    "E_ACCESSDENIED",
+	E_OUTOFMEMORY:                     "E_OUTOFMEMORY",
+	E_INVALIDARG:                      "E_INVALIDARG",
+	ERROR_ALREADY_EXISTS:              "ERROR_ALREADY_EXISTS",
+	ERROR_LINUX_SUBSYSTEM_NOT_PRESENT: "ERROR_LINUX_SUBSYSTEM_NOT_PRESENT",
+}
+
+// Failed returns true if the severity bit of the HRESULT is set.
+func (h HResult) Failed() bool {
+	return h&hresultSeverityBit != 0
+}
+
+// Facility returns the facility code of the HRESULT, i.e. the
+// subsystem responsible for the error.
+func (h HResult) Facility() uint16 {
+	return uint16((h & hresultFacilityMask) >> 16)
+}
+
+// Code returns the facility-specific error code of the HRESULT. For errors
+// with the Win32 facility, this is the Win32 error code.
+func (h HResult) Code() uint16 {
+	return uint16(h & hresultCodeMask)
+}
+
+// Name returns the symbolic name of the HRESULT, or an empty string if it is not known.
+func (h HResult) Name() string {
+	if name, ok := hresultNames[h]; ok {
+		return name
+	}
+	if h.Failed() && h.Facility() == facilityWin32 {
+		return fmt.Sprintf("HRESULT_FROM_WIN32(%d)", h.Code())
+	}
+	return ""
+}
+
+func (h HResult) String() string {
+	name := h.Name()
+	if name == "" {
+		return fmt.Sprintf("0x%x", uint32(h))
+	}
+	return fmt.Sprintf("0x%x (%s)", uint32(h), name)
+}
@@ -0,0 +1,46 @@
+package gowsl_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+)
+
+func TestHResult(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		hresult wsl.HResult
+
+		wantFailed   bool
+		wantFacility uint16
+		wantCode     uint16
+		wantString   string
+	}{
+		"Success with a well-known HRESULT":         {hresult: wsl.E_ILLEGAL_STATE_CHANGE, wantFailed: true, wantFacility: 0, wantCode: 0xd, wantString: "0x8000000d (E_ILLEGAL_STATE_CHANGE)"},
+		"Success with a well-known Win32 HRESULT":   {hresult: wsl.E_ACCESSDENIED, wantFailed: true, wantFacility: 7, wantCode: 5, wantString: "0x80070005 (E_ACCESSDENIED)"},
+		"Success with an unknown Win32 HRESULT":     {hresult: 0x80070490, wantFailed: true, wantFacility: 7, wantCode: 0x490, wantString: "0x80070490 (HRESULT_FROM_WIN32(1168))"},
+		"Success with an unknown non-Win32 HRESULT": {hresult: 0x80040326, wantFailed: true, wantFacility: 4, wantCode: 0x326, wantString: "0x80040326"},
+		"Success with a non-failure HRESULT":        {hresult: 0x00000001, wantFacility: 0, wantCode: 1, wantString: "0x1"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantFailed, tc.hresult.Failed(), "Unexpected value for Failed")
+			require.Equal(t, tc.wantFacility, tc.hresult.Facility(), "Unexpected value for Facility")
+			require.Equal(t, tc.wantCode, tc.hresult.Code(), "Unexpected value for Code")
+			require.Equal(t, tc.wantString, tc.hresult.String(), "Unexpected value for String")
+		})
+	}
+}
+
+func TestExitOriginString(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "Linux", wsl.OriginLinux.String(), "Unexpected string for OriginLinux")
+	require.Equal(t, "Windows", wsl.OriginWindows.String(), "Unexpected string for OriginWindows")
+	require.Equal(t, "Unknown origin 42", wsl.ExitOrigin(42).String(), "Unexpected string for an invalid origin")
+}
@@ -14,6 +14,7 @@ import (
 	"sync"
 
 	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
 )
 
 // Cmd is a wrapper around the Windows process spawned by WslLaunch.
@@ -43,6 +44,10 @@ type Cmd struct {
 	stdoutW *os.File // File that acts as a writer for WSL to write stdout into
 	stderrW *os.File // File that acts as a writer for WSL to write stderr into
 
+	// stderrTail captures the beginning and end of stderr when Stderr is not set, so
+	// that it can be attached to the returned ExitError.
+	stderrTail *prefixSuffixSaver
+
 	// Book-keeping
 	Process      *os.Process      // The windows handle to the WSL process
 	finished     bool             // Flag to fail nicely when Wait is invoked twice
@@ -80,7 +85,7 @@ func (d *Distro) Command(ctx context.Context, cmd string) *Cmd {
 func (c *Cmd) Start() (err error) {
 	defer decorate.OnError(&err, "could not start Cmd on distro %s with command %q", c.distro.name, c.command)
 
-	if err := c.distro.mustBeRegistered(); err != nil {
+	if err := c.distro.mustBeRegistered(c.ctx); err != nil {
 		return err
 	}
 
@@ -111,6 +116,7 @@ func (c *Cmd) Start() (err error) {
 	}
 
 	c.Process, err = c.distro.backend.WslLaunch(
+		c.ctx,
 		c.distro.Name(),
 		c.command,
 		c.UseCWD,
@@ -164,19 +170,16 @@ func (c *Cmd) Output() (out []byte, err error) {
 	var stdout bytes.Buffer
 	c.Stdout = &stdout
 
-	captureErr := c.Stderr == nil
-	if captureErr {
-		c.Stderr = &prefixSuffixSaver{N: 32 << 10}
-	}
-
 	err = c.Run()
-	if err != nil && captureErr {
-		target := &exec.ExitError{}
-		if errors.As(err, &target) {
-			//nolint:forcetypeassert // copied from stdlib. We know this to be true because it is set further up in this same function
-			target.Stderr = c.Stderr.(*prefixSuffixSaver).Bytes()
-		}
+
+	// For compatibility with callers expecting the standard library's error, we
+	// also populate the stderr of the *exec.ExitError wrapped by our ExitError.
+	var target *ExitError
+	var execTarget *exec.ExitError
+	if errors.As(err, &target) && errors.As(err, &execTarget) {
+		execTarget.Stderr = target.Stderr
 	}
+
 	return stdout.Bytes(), err
 }
 
@@ -322,8 +325,17 @@ func (c *Cmd) stderr() error {
 		c.stderrW = c.stdoutW
 		return nil
 	}
+
+	// We deviate from the standard library: rather than discarding an unset stderr,
+	// we keep its beginning and end to attach them to the ExitError.
+	stderr := c.Stderr
+	if stderr == nil {
+		c.stderrTail = &prefixSuffixSaver{N: 32 << 10}
+		stderr = c.stderrTail
+	}
+
 	// Different stdout and stderr
-	w, e := c.writerDescriptor(c.Stderr)
+	w, e := c.writerDescriptor(stderr)
 	if e == nil {
 		c.stderrW = w
 	}
@@ -451,7 +463,7 @@ func (c *Cmd) writerDescriptor(w io.Writer) (f *os.File, err error) {
 // status.
 //
 // If the command fails to run or doesn't complete successfully, the
-// error is of type ExitError. Other error types may be
+// error is of type *ExitError. Other error types may be
 // returned for I/O problems.
 //
 // If any of c.Stdin, c.Stdout or c.Stderr are not an *os.File, Wait also waits
@@ -470,12 +482,17 @@ func (c *Cmd) Wait() (err error) {
 	}
 	c.finished = true
 
-	state, err := c.Process.Wait()
+	state, stubCode, stubCodeKnown, err := stubprocess.Wait(c.Process)
 	if c.waitDone != nil {
 		close(c.waitDone)
 	}
 	c.ProcessState = state
 
+	var exitErr *ExitError
+	if err == nil {
+		exitErr = newExitErrorFromState(state, stubCode, stubCodeKnown)
+	}
+
 	var copyError error
 	for range c.goroutine {
 		select {
@@ -497,8 +514,11 @@ func (c *Cmd) Wait() (err error) {
 
 	if err != nil {
 		return err
-	} else if !state.Success() {
-		return &exec.ExitError{ProcessState: state}
+	} else if exitErr != nil {
+		if c.stderrTail != nil {
+			exitErr.Stderr = c.stderrTail.Bytes()
+		}
+		return exitErr
 	}
 
 	return copyError
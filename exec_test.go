@@ -47,13 +47,15 @@ func TestCommandRun(t *testing.T) {
 
 		wantError       bool
 		wantExitCode    int
+		wantStderr      string
 		wantErrNotExist bool
 	}{
 		// Background context test cases
 		"Success":                            {cmd: "exit 0"},
 		"Error with a non-registered distro": {cmd: "exit 0", fakeDistro: true, wantError: true, wantErrNotExist: true},
-		"Error when the command's exit code is non-zero": {cmd: "exit 42", wantError: true, wantExitCode: 42},
-		"Error when the command has invalid characters":  {cmd: "echo \x00", fakeDistro: true, wantError: true},
+		"Error when the command's exit code is non-zero":                   {cmd: "exit 42", wantError: true, wantExitCode: 42},
+		"Error when the command's exit code is non-zero, capturing stderr": {cmd: "echo 'Error!' >&2 && exit 42", wantError: true, wantExitCode: 42, wantStderr: "Error!\n"},
+		"Error when the command has invalid characters":                    {cmd: "echo \x00", fakeDistro: true, wantError: true},
 
 		// Background context: mock-induced errors
 		"Error when the syscall fails":            {cmd: "exit 0", syscallErr: true, wantError: true},
@@ -115,10 +117,14 @@ func TestCommandRun(t *testing.T) {
 
 			if tc.syscallErr || tc.registryInaccessible {
 				modifyMock(t, func(m *mock.Backend) {
-					m.WslLaunchError = tc.registryInaccessible
-					m.OpenLxssKeyError = tc.syscallErr
+					if tc.registryInaccessible {
+						m.InjectFault("OpenLxssRegistry", mock.Fault{})
+					}
+					if tc.syscallErr {
+						m.InjectFault("WslLaunch", mock.Fault{})
+					}
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			}
 
 			err := cmd.Run()
@@ -137,6 +143,13 @@ func TestCommandRun(t *testing.T) {
 			if tc.wantExitCode != 0 {
 				require.ErrorAsf(t, err, &target, "Run() should have returned an ExitError")
 				require.Equal(t, tc.wantExitCode, target.ExitCode(), "returned error ExitError has unexpected Code status")
+
+				wslTarget := &wsl.ExitError{}
+				require.ErrorAsf(t, err, &wslTarget, "Run() should have returned a wsl.ExitError")
+				require.Equal(t, wsl.OriginLinux, wslTarget.Origin, "returned error wsl.ExitError has unexpected Origin")
+
+				got := strings.ReplaceAll(string(wslTarget.Stderr), "\r\n", "\n")
+				require.Equal(t, tc.wantStderr, got, "returned error wsl.ExitError has unexpected Stderr")
 				return
 			}
 
@@ -316,10 +329,14 @@ func TestCommandStartWait(t *testing.T) {
 
 			if tc.syscallErr || tc.registryInaccessible {
 				modifyMock(t, func(m *mock.Backend) {
-					m.WslLaunchError = tc.registryInaccessible
-					m.OpenLxssKeyError = tc.syscallErr
+					if tc.registryInaccessible {
+						m.InjectFault("OpenLxssRegistry", mock.Fault{})
+					}
+					if tc.syscallErr {
+						m.InjectFault("WslLaunch", mock.Fault{})
+					}
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			}
 
 			err = cmd.Start()
@@ -378,6 +395,43 @@ func TestCommandStartWait(t *testing.T) {
 	}
 }
 
+func TestCommandKilled(t *testing.T) {
+	ctx, _ := setupBackend(t, context.Background())
+
+	d := newTestDistro(t, ctx, rootFS)
+
+	cmd := d.Command(context.Background(), "sleep infinity")
+	require.NoError(t, cmd.Start(), "Setup: could not start command")
+	require.NoError(t, cmd.Process.Kill(), "Setup: could not kill the process")
+
+	err := cmd.Wait()
+
+	var exitErr *wsl.ExitError
+	require.ErrorAs(t, err, &exitErr, "Wait should have returned an ExitError")
+	require.Equal(t, wsl.OriginLinux, exitErr.Origin, "Killed processes should not be attributed to Windows")
+	require.Zero(t, exitErr.HResult, "Killed processes should not have an HResult")
+	require.LessOrEqual(t, exitErr.ExitCode(), uint32(0xff), "Killed processes should have an exit code that fits in a Linux exit status")
+}
+
+func TestCommandWindowsExitCode(t *testing.T) {
+	t.Parallel()
+
+	// Clients do not need the gowslmock build tag.
+	m := mock.New()
+	require.NoError(t, m.WslRegisterDistribution(context.Background(), "Ubuntu", "rootfs.tar.gz"), "Setup: could not register distro")
+	m.Handle(mock.Exact("wsl-error"), func(context.Context, io.Reader, io.Writer, io.Writer) int {
+		return int(wsl.ERROR_LINUX_SUBSYSTEM_NOT_PRESENT)
+	})
+
+	d := wsl.NewClient(wsl.WithClientBackend(m)).Distro("Ubuntu")
+	err := d.Command(context.Background(), "wsl-error").Run()
+
+	var exitErr *wsl.ExitError
+	require.ErrorAs(t, err, &exitErr, "Run should have returned an ExitError")
+	require.Equal(t, wsl.OriginWindows, exitErr.Origin, "Exit codes above 255 should be attributed to Windows")
+	require.Equal(t, wsl.ERROR_LINUX_SUBSYSTEM_NOT_PRESENT, exitErr.HResult, "Unexpected HRESULT")
+}
+
 func TestCommandOutPipes(t *testing.T) {
 	ctx, _ := setupBackend(t, context.Background())
 
@@ -531,6 +585,14 @@ func TestCommandOutput(t *testing.T) {
 
 			got := strings.ReplaceAll(string(target.Stderr), "\r\n", "\n")
 			require.Equal(t, tc.wantStderr, got, "Unexpected contents in stderr")
+
+			wslTarget := &wsl.ExitError{}
+			require.ErrorAsf(t, err, &wslTarget, "Unexpected error type. Expected a wsl.ExitError.")
+			require.Equal(t, wsl.OriginLinux, wslTarget.Origin, "Unexpected origin for wsl.ExitError")
+			require.Equal(t, uint32(tc.wantExitCode), wslTarget.ExitCode(), "Unexpected value for wsl.ExitError.ExitCode.")
+
+			got = strings.ReplaceAll(string(wslTarget.Stderr), "\r\n", "\n")
+			require.Equal(t, tc.wantStderr, got, "Unexpected contents in wsl.ExitError.Stderr")
 		})
 	}
 }
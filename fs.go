@@ -0,0 +1,511 @@
+package gowsl
+
+// This file contains an io/fs view of the filesystem of a distro.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+// FS is a view of the filesystem of a distro. It implements the io/fs interfaces, so that
+// it can be used with fs.WalkDir, fs.ReadFile, template.ParseFS, etc., plus some helpers to
+// modify the filesystem.
+//
+// As with any fs.FS, names are slash-separated paths without a leading slash. They are
+// interpreted relative to the root of the distro, so "etc/os-release" refers to /etc/os-release.
+//
+// An FS must be closed after use to release its resources.
+type FS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+
+	// WriteFile writes data into the named file, creating it with permissions perm
+	// if it does not exist, and truncating it otherwise.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+
+	// MkdirAll creates the named directory with permissions perm, along with any
+	// necessary parents. It does nothing if the directory already exists.
+	MkdirAll(name string, perm fs.FileMode) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+
+	// Close releases the resources associated with the FS.
+	Close() error
+}
+
+// FS returns a view of the filesystem of the distro.
+//
+// If the back-end shares the filesystem with the host (see backend.RootSharer), as the real one
+// does via the \\wsl.localhost\<name> share on Windows, it is accessed there. Otherwise (e.g.
+// when using the mock), it is accessed by running commands in a Session. The latter is slower,
+// and it reads files whole into memory on first read.
+//
+// The provided context is used to stop any running command when it becomes done.
+// The distro is started if needed.
+func (d *Distro) FS(ctx context.Context) FS {
+	if root, ok := backend.ShareRoot(d.backend, d.name); ok {
+		return &shareFS{
+			root: root,
+		}
+	}
+
+	return &sessionFS{
+		ctx:    ctx,
+		distro: d,
+	}
+}
+
+// shareFS is an FS that accesses the distro's files where the back-end shares them, such as a
+// Windows network share.
+type shareFS struct {
+	root string
+}
+
+func (f *shareFS) Open(name string) (fs.File, error) {
+	return os.DirFS(f.root).Open(name)
+}
+
+func (f *shareFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(os.DirFS(f.root), name)
+}
+
+func (f *shareFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(os.DirFS(f.root), name)
+}
+
+func (f *shareFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(os.DirFS(f.root), name)
+}
+
+func (f *shareFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return f.do("writefile", name, func(p string) error { return os.WriteFile(p, data, perm) })
+}
+
+func (f *shareFS) MkdirAll(name string, perm fs.FileMode) error {
+	return f.do("mkdirall", name, func(p string) error { return os.MkdirAll(p, perm) })
+}
+
+func (f *shareFS) Remove(name string) error {
+	return f.do("remove", name, os.Remove)
+}
+
+func (f *shareFS) Close() error {
+	return nil
+}
+
+// do validates the name and calls op on its path in the share. Any *fs.PathError
+// returned refers to the name rather than the path, so as to match the fs.FS methods.
+func (f *shareFS) do(op, name string, action func(path string) error) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	err := action(filepath.Join(f.root, filepath.FromSlash(name)))
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+// Exit codes used by the scripts run by sessionFS to report well-known errors.
+const (
+	fsExitNotExist = 100
+	fsExitIsDir    = 101
+	fsExitNotDir   = 102
+)
+
+// sessionFS is an FS that accesses the distro's files by running commands in it.
+type sessionFS struct {
+	ctx    context.Context
+	distro *Distro
+
+	mu      sync.Mutex
+	session *Session
+	closed  bool
+}
+
+func (f *sessionFS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionFile{fsys: f, name: name, info: info}, nil
+}
+
+func (f *sessionFS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *sessionFS) stat(op, name string) (fs.FileInfo, error) {
+	out, err := f.run(op, name, `[ -e "$p" ] || exit 100; stat -L -c '%f %s %Y' -- "$p"`)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := parseStat(path.Base(name), strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return info, nil
+}
+
+func (f *sessionFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	// Each entry is printed as its metadata, followed by a newline, followed by its NUL-terminated
+	// name. The glob patterns match all entries (including hidden ones) except for . and ..
+	const script = `[ -e "$p" ] || exit 100; [ -d "$p" ] || exit 102; cd -- "$p" || exit
+for f in * .[!.]* ..?*; do
+	[ -e "$f" ] || [ -L "$f" ] || continue
+	stat -c '%f %s %Y' -- "$f" || exit
+	printf '%s\0' "$f"
+done`
+
+	out, err := f.run("readdir", name, script)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fs.DirEntry
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		meta, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && meta == "" {
+			break
+		} else if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("could not parse output: %v", err)}
+		}
+
+		entryName, err := r.ReadString(0)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("could not parse output: %v", err)}
+		}
+
+		info, err := parseStat(strings.TrimSuffix(entryName, "\x00"), strings.TrimSpace(meta))
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (f *sessionFS) ReadFile(name string) ([]byte, error) {
+	return f.run("read", name, `[ -e "$p" ] || exit 100; [ -d "$p" ] && exit 101; exec cat -- "$p"`)
+}
+
+func (f *sessionFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	script := fmt.Sprintf(`[ -d "$(dirname -- "$p")" ] || exit 100
+[ -d "$p" ] && exit 101
+[ -e "$p" ] || { : > "$p" && chmod %o -- "$p"; }`, perm.Perm())
+
+	if _, err := f.run("writefile", name, script); err != nil {
+		return err
+	}
+
+	// Sessions cannot forward stdin, so a separate command is used to stream the data.
+	cmd := f.distro.Command(f.ctx, "cat > "+shellQuote(distroPath(name)))
+	cmd.Stdin = bytes.NewReader(data)
+	return fsError("writefile", name, cmd.Run())
+}
+
+func (f *sessionFS) MkdirAll(name string, perm fs.FileMode) error {
+	script := fmt.Sprintf(`[ -e "$p" ] && { [ -d "$p" ] || exit 102; }; exec mkdir -p -m %o -- "$p"`, perm.Perm())
+	_, err := f.run("mkdirall", name, script)
+	return err
+}
+
+func (f *sessionFS) Remove(name string) error {
+	const script = `[ -e "$p" ] || [ -L "$p" ] || exit 100
+if [ -d "$p" ] && [ ! -L "$p" ]; then exec rmdir -- "$p"; fi
+exec rm -f -- "$p"`
+
+	_, err := f.run("remove", name, script)
+	return err
+}
+
+func (f *sessionFS) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	if f.session == nil {
+		return nil
+	}
+	return f.session.Close()
+}
+
+// run validates the name and runs the script in the session, with the variable p set to
+// the path of the named file. The session is started on first use.
+func (f *sessionFS) run(op, name, script string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	s, err := f.getSession()
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	out, err := s.Command(f.ctx, fmt.Sprintf("p=%s\n%s", shellQuote(distroPath(name)), script)).Output()
+	if err != nil {
+		return nil, fsError(op, name, err)
+	}
+	return out, nil
+}
+
+// getSession returns the session, starting it if needed.
+func (f *sessionFS) getSession() (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, fs.ErrClosed
+	}
+
+	if f.session != nil {
+		return f.session, nil
+	}
+
+	s, err := f.distro.Session(f.ctx)
+	if err != nil {
+		return nil, err
+	}
+	f.session = s
+
+	return s, nil
+}
+
+// distroPath converts a valid fs.FS name into an absolute path in the distro.
+func distroPath(name string) string {
+	if name == "." {
+		return "/"
+	}
+	return "/" + name
+}
+
+// fsError converts the errors returned by the scripts run by sessionFS into *fs.PathError.
+func fsError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Origin != OriginLinux {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	switch exitErr.ExitCode() {
+	case fsExitNotExist:
+		err = fs.ErrNotExist
+	case fsExitIsDir:
+		err = errors.New("is a directory")
+	case fsExitNotDir:
+		err = errors.New("not a directory")
+	default:
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// parseStat parses the output of stat with format '%f %s %Y'.
+func parseStat(name, s string) (fs.FileInfo, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("could not parse stat output %q", s)
+	}
+
+	rawMode, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse mode %q: %v", fields[0], err)
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse size %q: %v", fields[1], err)
+	}
+
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse modification time %q: %v", fields[2], err)
+	}
+
+	return &fileInfo{
+		name:    name,
+		size:    size,
+		mode:    unixToFileMode(uint32(rawMode)),
+		modTime: time.Unix(mtime, 0),
+	}, nil
+}
+
+// unixToFileMode converts a Linux st_mode into an fs.FileMode.
+func unixToFileMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0777)
+
+	switch m & 0170000 {
+	case 0040000:
+		mode |= fs.ModeDir
+	case 0120000:
+		mode |= fs.ModeSymlink
+	case 0010000:
+		mode |= fs.ModeNamedPipe
+	case 0140000:
+		mode |= fs.ModeSocket
+	case 0020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0060000:
+		mode |= fs.ModeDevice
+	}
+
+	if m&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+
+	return mode
+}
+
+// fileInfo implements fs.FileInfo for files in a sessionFS.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *fileInfo) Sys() any           { return nil }
+
+// sessionFile implements fs.File for files in a sessionFS. The contents of regular
+// files are read on first use, and the entries of directories are listed on first use.
+type sessionFile struct {
+	fsys *sessionFS
+	name string
+	info fs.FileInfo
+
+	contents *bytes.Reader
+	entries  []fs.DirEntry
+	listed   bool
+	closed   bool
+}
+
+func (f *sessionFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.info, nil
+}
+
+func (f *sessionFile) Read(b []byte) (int, error) {
+	if err := f.load("read"); err != nil {
+		return 0, err
+	}
+	return f.contents.Read(b)
+}
+
+func (f *sessionFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load("seek"); err != nil {
+		return 0, err
+	}
+	return f.contents.Seek(offset, whence)
+}
+
+func (f *sessionFile) ReadAt(b []byte, offset int64) (int, error) {
+	if err := f.load("read"); err != nil {
+		return 0, err
+	}
+	return f.contents.ReadAt(b, offset)
+}
+
+// load reads the contents of the file if they have not been read yet.
+func (f *sessionFile) load(op string) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.contents != nil {
+		return nil
+	}
+
+	out, err := f.fsys.ReadFile(f.name)
+	if err != nil {
+		return err
+	}
+	f.contents = bytes.NewReader(out)
+	return nil
+}
+
+func (f *sessionFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+
+	if !f.listed {
+		entries, err := f.fsys.ReadDir(f.name)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = entries
+		f.listed = true
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+func (f *sessionFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
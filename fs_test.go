@@ -0,0 +1,214 @@
+package gowsl_test
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestFS(t *testing.T) {
+	skipSessionOnWindowsMock(t)
+	ctx := setupSandboxedBackend(t, context.Background())
+
+	d := newTestDistro(t, ctx, rootFS)
+
+	fsys := d.FS(ctx)
+	defer fsys.Close()
+
+	root := fsTestRoot(t)
+
+	require.NoError(t, fsys.MkdirAll(path.Join(root, "subdir", "empty"), 0750), "MkdirAll should have succeeded")
+	require.NoError(t, fsys.MkdirAll(path.Join(root, "subdir"), 0750), "MkdirAll should succeed when the directory exists")
+	require.NoError(t, fsys.WriteFile(path.Join(root, "file.txt"), []byte("Hello, world!\n"), 0600), "WriteFile should have succeeded")
+	require.NoError(t, fsys.WriteFile(path.Join(root, "subdir", ".hidden"), []byte("It's a secret"), 0640), "WriteFile should have succeeded")
+	require.NoError(t, fsys.WriteFile(path.Join(root, "subdir", "name with spaces"), nil, 0644), "WriteFile should have succeeded")
+
+	sub, err := fs.Sub(fsys, root)
+	require.NoError(t, err, "Sub should have succeeded")
+	err = fstest.TestFS(sub, "file.txt", "subdir/.hidden", "subdir/name with spaces", "subdir/empty")
+	require.NoError(t, err, "The FS should behave as an fs.FS")
+
+	out, err := fs.ReadFile(fsys, path.Join(root, "subdir", ".hidden"))
+	require.NoError(t, err, "ReadFile should have succeeded")
+	require.Equal(t, "It's a secret", string(out), "ReadFile returned unexpected contents")
+
+	info, err := fs.Stat(fsys, path.Join(root, "file.txt"))
+	require.NoError(t, err, "Stat should have succeeded")
+	require.Equal(t, "file.txt", info.Name(), "Stat returned an unexpected name")
+	require.Equal(t, int64(len("Hello, world!\n")), info.Size(), "Stat returned an unexpected size")
+	require.Equal(t, fs.FileMode(0600), info.Mode(), "Stat returned an unexpected mode")
+
+	// Overwriting a file truncates it and keeps its permissions.
+	require.NoError(t, fsys.WriteFile(path.Join(root, "file.txt"), []byte("Bye!"), 0644), "WriteFile should have succeeded")
+	out, err = fs.ReadFile(fsys, path.Join(root, "file.txt"))
+	require.NoError(t, err, "ReadFile should have succeeded")
+	require.Equal(t, "Bye!", string(out), "ReadFile returned unexpected contents after overwriting")
+	info, err = fs.Stat(fsys, path.Join(root, "file.txt"))
+	require.NoError(t, err, "Stat should have succeeded")
+	require.Equal(t, fs.FileMode(0600), info.Mode(), "Overwriting a file should not change its mode")
+
+	var names []string
+	err = fs.WalkDir(fsys, root, func(p string, _ fs.DirEntry, err error) error {
+		names = append(names, strings.TrimPrefix(p, root))
+		return err
+	})
+	require.NoError(t, err, "WalkDir should have succeeded")
+	require.Equal(t, []string{"", "/file.txt", "/subdir", "/subdir/.hidden", "/subdir/empty", "/subdir/name with spaces"}, names, "WalkDir visited unexpected entries")
+
+	require.NoError(t, fsys.Remove(path.Join(root, "subdir", "empty")), "Remove should succeed with empty directories")
+	require.NoError(t, fsys.Remove(path.Join(root, "file.txt")), "Remove should succeed with files")
+	_, err = fs.Stat(fsys, path.Join(root, "file.txt"))
+	require.ErrorIs(t, err, fs.ErrNotExist, "Stat should fail after removing the file")
+}
+
+// sharer is a back-end that shares the filesystem of its distros in a directory of the host.
+type sharer struct {
+	backend.Backend
+	dir string
+}
+
+func (s sharer) ShareRoot(distroName string) (string, bool) {
+	return filepath.Join(s.dir, distroName), true
+}
+
+func TestFSShareRoot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Ubuntu", "etc"), 0750), "Setup: could not create shared root")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Ubuntu", "etc", "hello"), []byte("Hello!\n"), 0600), "Setup: could not write file")
+
+	// The distro is not registered, so the files can only be reached through the share.
+	ctx := wsl.WithBackend(context.Background(), sharer{Backend: mock.New(), dir: dir})
+	ctx = wsl.WithLogger(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	d := wsl.NewDistro(ctx, "Ubuntu")
+
+	fsys := d.FS(ctx)
+	defer fsys.Close()
+
+	out, err := fs.ReadFile(fsys, "etc/hello")
+	require.NoError(t, err, "ReadFile should have succeeded")
+	require.Equal(t, "Hello!\n", string(out), "ReadFile returned unexpected contents")
+
+	require.NoError(t, fsys.WriteFile("etc/new", []byte("New!\n"), 0600), "WriteFile should have succeeded")
+	out, err = os.ReadFile(filepath.Join(dir, "Ubuntu", "etc", "new"))
+	require.NoError(t, err, "The file should have been written into the share")
+	require.Equal(t, "New!\n", string(out), "WriteFile wrote unexpected contents")
+}
+
+func TestFSErrors(t *testing.T) {
+	skipSessionOnWindowsMock(t)
+	ctx := setupSandboxedBackend(t, context.Background())
+
+	realDistro := newTestDistro(t, ctx, rootFS)
+	fakeDistro := wsl.NewDistro(ctx, uniqueDistroName(t))
+
+	fsys := realDistro.FS(ctx)
+	defer fsys.Close()
+
+	root := fsTestRoot(t)
+	require.NoError(t, fsys.MkdirAll(path.Join(root, "dir"), 0700), "Setup: MkdirAll should have succeeded")
+	require.NoError(t, fsys.WriteFile(path.Join(root, "dir", "file"), []byte("Hello!"), 0600), "Setup: WriteFile should have succeeded")
+
+	testCases := map[string]struct {
+		distro *wsl.Distro
+		op     func(wsl.FS) error
+
+		wantErr error
+	}{
+		"Error when reading a missing file":          {op: fsOpReadFile(root, "missing"), wantErr: fs.ErrNotExist},
+		"Error when stating a missing file":          {op: fsOpStat(root, "missing"), wantErr: fs.ErrNotExist},
+		"Error when listing a missing directory":     {op: fsOpReadDir(root, "missing"), wantErr: fs.ErrNotExist},
+		"Error when removing a missing file":         {op: fsOpRemove(root, "missing"), wantErr: fs.ErrNotExist},
+		"Error when writing in a missing directory":  {op: fsOpWriteFile(root, "missing/file"), wantErr: fs.ErrNotExist},
+		"Error when the path is invalid":             {op: func(f wsl.FS) error { _, err := f.ReadFile("/etc/hostname"); return err }, wantErr: fs.ErrInvalid},
+		"Error when the distro is not registered":    {distro: &fakeDistro, op: fsOpStat(root, "dir"), wantErr: wsl.ErrNotExist},
+		"Error when reading a directory":             {op: fsOpReadFile(root, "dir")},
+		"Error when writing into a directory":        {op: fsOpWriteFile(root, "dir")},
+		"Error when listing a file":                  {op: fsOpReadDir(root, "dir/file")},
+		"Error when creating a directory over files": {op: func(f wsl.FS) error { return f.MkdirAll(path.Join(root, "dir", "file"), 0700) }},
+		"Error when removing a non-empty directory":  {op: fsOpRemove(root, "dir")},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f := fsys
+			if tc.distro != nil {
+				f = tc.distro.FS(ctx)
+				defer f.Close()
+			}
+
+			err := tc.op(f)
+			require.Error(t, err, "Operation should have failed")
+
+			var pathErr *fs.PathError
+			require.ErrorAs(t, err, &pathErr, "Error should be a *fs.PathError")
+
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr, "Unexpected error type")
+			}
+		})
+	}
+
+	require.NoError(t, fsys.Close(), "Close should have succeeded")
+	require.NoError(t, fsys.Close(), "Closing twice should have succeeded")
+	_, err := fsys.ReadFile(path.Join(root, "dir", "file"))
+	require.ErrorIs(t, err, fs.ErrClosed, "ReadFile should fail after closing the FS")
+}
+
+// fsTestRoot returns an empty directory to test the FS in, as an fs.FS name.
+func fsTestRoot(t *testing.T) string {
+	t.Helper()
+
+	// With the mock, commands run on the host so we need a path we can clean up.
+	if wsl.MockAvailable() {
+		return strings.TrimPrefix(t.TempDir(), "/")
+	}
+	return path.Join("tmp", uniqueDistroName(t))
+}
+
+// fsOpReadFile and the other fsOp helpers return operations on the FS, to be used in table tests.
+func fsOpReadFile(root, name string) func(wsl.FS) error {
+	return func(f wsl.FS) error {
+		_, err := f.ReadFile(path.Join(root, name))
+		return err
+	}
+}
+
+func fsOpStat(root, name string) func(wsl.FS) error {
+	return func(f wsl.FS) error {
+		_, err := f.Stat(path.Join(root, name))
+		return err
+	}
+}
+
+func fsOpReadDir(root, name string) func(wsl.FS) error {
+	return func(f wsl.FS) error {
+		_, err := f.ReadDir(path.Join(root, name))
+		return err
+	}
+}
+
+func fsOpRemove(root, name string) func(wsl.FS) error {
+	return func(f wsl.FS) error {
+		return f.Remove(path.Join(root, name))
+	}
+}
+
+func fsOpWriteFile(root, name string) func(wsl.FS) error {
+	return func(f wsl.FS) error {
+		return f.WriteFile(path.Join(root, name), []byte("Hello!"), 0600)
+	}
+}
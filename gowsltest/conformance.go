@@ -0,0 +1,385 @@
+// Package gowsltest contains helpers to test GoWSL back-ends, and code that uses GoWSL.
+package gowsltest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/flags"
+	"github.com/ubuntu/gowsl/internal/state"
+)
+
+// DistroPrefix is the prefix of the names of the distros created by the conformance suite.
+const DistroPrefix = "gowsltest-"
+
+// notRegistered is the name of a distro that the conformance suite never registers.
+const notRegistered = DistroPrefix + "not-registered"
+
+// RunConformance runs the conformance suite against a back-end. Every back-end must pass it,
+// so that code tested against one of them behaves the same against the others.
+//
+// The suite registers distros from the rootfs tarball, with names starting with DistroPrefix,
+// and unregisters them when it is done. Other distros are left registered, but they may be
+// terminated. Tests must not run in parallel with the suite, as it checks the default distro.
+func RunConformance(t *testing.T, b backend.Backend, rootfs string) {
+	t.Helper()
+
+	t.Run("Registration", func(t *testing.T) { testRegistration(t, b, rootfs) })
+	t.Run("Configuration", func(t *testing.T) { testConfiguration(t, b, rootfs) })
+	t.Run("State", func(t *testing.T) { testState(t, b, rootfs) })
+	t.Run("Launch", func(t *testing.T) { testLaunch(t, b, rootfs) })
+	t.Run("DefaultDistro", func(t *testing.T) { testDefaultDistro(t, b, rootfs) })
+	t.Run("ErrNotExist", func(t *testing.T) { testErrNotExist(t, b) })
+	t.Run("Cancellation", func(t *testing.T) { testCancellation(t, b, rootfs) })
+}
+
+func testRegistration(t *testing.T, b backend.Backend, rootfs string) {
+	name := registerDistro(t, b, rootfs)
+	requireState(t, b, name, state.Stopped, "Distros should be stopped after registering them")
+	require.Contains(t, registeredDistros(t, b), strings.ToLower(name), "Distros should be in the registry after registering them")
+
+	err := b.WslRegisterDistribution(context.Background(), name, rootfs)
+	require.Error(t, err, "Registering a distro twice should fail")
+
+	err = b.WslRegisterDistribution(context.Background(), DistroPrefix+"not valid", rootfs)
+	require.Error(t, err, "Registering a distro with an invalid name should fail")
+
+	err = b.WslUnregisterDistribution(context.Background(), name)
+	require.NoError(t, err, "WslUnregisterDistribution should have succeeded")
+	requireState(t, b, name, state.NotRegistered, "Distros should not be registered after unregistering them")
+	require.NotContains(t, registeredDistros(t, b), strings.ToLower(name), "Distros should not be in the registry after unregistering them")
+
+	err = b.WslUnregisterDistribution(context.Background(), name)
+	require.ErrorIs(t, err, backend.ErrNotExist, "Unregistering a distro twice should fail")
+}
+
+func testConfiguration(t *testing.T, b backend.Backend, rootfs string) {
+	name := registerDistro(t, b, rootfs)
+
+	version, uid, wslFlags, env := getConfiguration(t, b, name)
+	require.Contains(t, []uint8{1, 2}, version, "Unexpected WSL version")
+	require.Contains(t, env, "PATH", "The default environment should have a PATH")
+
+	// Toggling interop keeps the other flags, such as the undocumented WSL version one.
+	wantUID, wantFlags := uid+1000, wslFlags^0x1
+
+	err := b.WslConfigureDistribution(context.Background(), name, wantUID, wantFlags)
+	require.NoError(t, err, "WslConfigureDistribution should have succeeded")
+
+	gotVersion, gotUID, gotFlags, gotEnv := getConfiguration(t, b, name)
+	require.Equal(t, version, gotVersion, "Configuring a distro should not change its version")
+	require.Equal(t, wantUID, gotUID, "The default UID should have been configured")
+	require.Equal(t, wantFlags, gotFlags, "The flags should have been configured")
+	require.Equal(t, env, gotEnv, "Configuring a distro should not change its environment")
+}
+
+func testState(t *testing.T, b backend.Backend, rootfs string) {
+	name := registerDistro(t, b, rootfs)
+	requireState(t, b, name, state.Stopped, "Distros should be stopped after registering them")
+
+	launchInteractive(t, b, name, "exit 0")
+	requireState(t, b, name, state.Running, "Distros should be running after launching a command")
+
+	err := b.Terminate(context.Background(), name)
+	require.NoError(t, err, "Terminate should have succeeded")
+	requireState(t, b, name, state.Stopped, "Distros should be stopped after terminating them")
+
+	launchInteractive(t, b, name, "exit 0")
+	requireState(t, b, name, state.Running, "Distros should be running after launching a command")
+
+	err = b.Shutdown(context.Background())
+	require.NoError(t, err, "Shutdown should have succeeded")
+	requireState(t, b, name, state.Stopped, "Distros should be stopped after shutting WSL down")
+
+	requireState(t, b, notRegistered, state.NotRegistered, "Distros that are not registered should be reported as such")
+}
+
+func testLaunch(t *testing.T, b backend.Backend, rootfs string) {
+	name := registerDistro(t, b, rootfs)
+
+	require.Equal(t, uint32(0), launchInteractive(t, b, name, "exit 0"), "Unexpected exit code")
+	require.Equal(t, uint32(42), launchInteractive(t, b, name, "exit 42"), "Unexpected exit code")
+
+	stdout, stderr, exitCode := launch(t, b, name, "echo 'Hello!'")
+	require.Equal(t, 0, exitCode, "Unexpected exit code")
+	require.Equal(t, "Hello!", strings.TrimSpace(stdout), "Unexpected standard output")
+	require.Empty(t, stderr, "Unexpected standard error")
+
+	stdout, stderr, exitCode = launch(t, b, name, "echo 'Error!' >&2 && exit 42")
+	require.Equal(t, 42, exitCode, "Unexpected exit code")
+	require.Empty(t, stdout, "Unexpected standard output")
+	require.Equal(t, "Error!", strings.TrimSpace(stderr), "Unexpected standard error")
+}
+
+func testDefaultDistro(t *testing.T, b backend.Backend, rootfs string) {
+	previous := defaultDistro(t, b)
+	if previous != "" {
+		t.Cleanup(func() {
+			if err := b.SetAsDefault(context.Background(), previous); err != nil {
+				t.Logf("Cleanup: could not restore default distro %q: %v", previous, err)
+			}
+		})
+	}
+
+	first := registerDistro(t, b, rootfs, "first")
+	want := previous
+	if want == "" {
+		want = first
+	}
+	require.True(t, strings.EqualFold(want, defaultDistro(t, b)), "Registering a distro should only make it the default if there were none")
+
+	err := b.SetAsDefault(context.Background(), first)
+	require.NoError(t, err, "SetAsDefault should have succeeded")
+	require.True(t, strings.EqualFold(first, defaultDistro(t, b)), "SetAsDefault should have made the distro the default")
+
+	second := registerDistro(t, b, rootfs, "second")
+	require.True(t, strings.EqualFold(first, defaultDistro(t, b)), "Registering a distro should not change the default")
+
+	err = b.WslUnregisterDistribution(context.Background(), first)
+	require.NoError(t, err, "WslUnregisterDistribution should have succeeded")
+
+	got := defaultDistro(t, b)
+	require.Contains(t, registeredDistros(t, b), strings.ToLower(got), "Unregistering the default distro should make another registered distro the default")
+	if previous == "" {
+		require.True(t, strings.EqualFold(second, got), "Unregistering the default distro should make the only other distro the default")
+	}
+}
+
+func testErrNotExist(t *testing.T, b backend.Backend) {
+	var (
+		version  uint8
+		uid      uint32
+		wslFlags flags.WslFlags
+		env      map[string]string
+	)
+
+	testCases := map[string]func() error{
+		"Terminate":    func() error { return b.Terminate(context.Background(), notRegistered) },
+		"SetAsDefault": func() error { return b.SetAsDefault(context.Background(), notRegistered) },
+		"WslConfigureDistribution": func() error {
+			return b.WslConfigureDistribution(context.Background(), notRegistered, 0, 0x7)
+		},
+		"WslGetDistributionConfiguration": func() error {
+			return b.WslGetDistributionConfiguration(context.Background(), notRegistered, &version, &uid, &wslFlags, &env)
+		},
+		"WslLaunchInteractive": func() error {
+			_, err := b.WslLaunchInteractive(context.Background(), notRegistered, "exit 0", false)
+			return err
+		},
+		"WslLaunch": func() error {
+			stdin, stdout, stderr := pipes(t)
+			_, err := b.WslLaunch(context.Background(), notRegistered, "exit 0", false, stdin, stdout, stderr)
+			return err
+		},
+		"WslUnregisterDistribution": func() error { return b.WslUnregisterDistribution(context.Background(), notRegistered) },
+	}
+
+	for method, call := range testCases {
+		t.Run(method, func(t *testing.T) {
+			require.ErrorIs(t, call(), backend.ErrNotExist, "%s should fail with ErrNotExist when the distro is not registered", method)
+		})
+	}
+}
+
+func testCancellation(t *testing.T, b backend.Backend, rootfs string) {
+	name := registerDistro(t, b, rootfs)
+	unregistered := invalidChars.ReplaceAllString(DistroPrefix+t.Name()+"-not-registered", "-")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := map[string]func() error{
+		"OpenLxssRegistry": func() error {
+			_, err := b.OpenLxssRegistry(ctx, ".")
+			return err
+		},
+		"State":                     func() error { _, err := b.State(ctx, name); return err },
+		"Terminate":                 func() error { return b.Terminate(ctx, name) },
+		"SetAsDefault":              func() error { return b.SetAsDefault(ctx, name) },
+		"WslRegisterDistribution":   func() error { return b.WslRegisterDistribution(ctx, unregistered, rootfs) },
+		"WslUnregisterDistribution": func() error { return b.WslUnregisterDistribution(ctx, name) },
+	}
+
+	for method, call := range testCases {
+		t.Run(method, func(t *testing.T) {
+			require.ErrorIs(t, call(), context.Canceled, "%s should fail right away when its context is done", method)
+		})
+	}
+
+	distros := registeredDistros(t, b)
+	require.Contains(t, distros, strings.ToLower(name), "Distros should still be registered after a cancelled unregistration")
+	require.NotContains(t, distros, strings.ToLower(unregistered), "Distros should not be registered after a cancelled registration")
+}
+
+// invalidChars matches the characters that are not allowed in distro names.
+var invalidChars = regexp.MustCompile(`[^A-Za-z0-9\-_.]+`)
+
+// registerDistro registers a distro named after the test and the suffixes, which
+// is unregistered during cleanup.
+func registerDistro(t *testing.T, b backend.Backend, rootfs string, suffixes ...string) string {
+	t.Helper()
+
+	name := DistroPrefix + strings.Join(append([]string{t.Name()}, suffixes...), "-")
+	name = invalidChars.ReplaceAllString(name, "-")
+
+	err := b.WslRegisterDistribution(context.Background(), name, rootfs)
+	require.NoError(t, err, "Setup: could not register %q", name)
+
+	t.Cleanup(func() {
+		err := b.WslUnregisterDistribution(context.Background(), name)
+		if err != nil && !errors.Is(err, backend.ErrNotExist) {
+			t.Logf("Cleanup: could not unregister %q: %v", name, err)
+		}
+	})
+
+	return name
+}
+
+// registeredDistros returns the lowercase names of the distros in the registry.
+func registeredDistros(t *testing.T, b backend.Backend) []string {
+	t.Helper()
+
+	r, err := b.OpenLxssRegistry(context.Background(), ".")
+	require.NoError(t, err, "Could not open the registry")
+	defer r.Close()
+
+	subkeys, err := r.SubkeyNames()
+	require.NoError(t, err, "Could not list the registry subkeys")
+
+	var names []string
+	for _, guid := range subkeys {
+		k, err := b.OpenLxssRegistry(context.Background(), guid)
+		require.NoError(t, err, "Could not open registry key %q", guid)
+
+		name, err := k.Field("DistributionName")
+		k.Close()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Not a distro
+		}
+		require.NoError(t, err, "Could not read the name of the distro in registry key %q", guid)
+
+		names = append(names, strings.ToLower(name))
+	}
+
+	return names
+}
+
+// defaultDistro returns the name of the default distro, or an empty string if there is none.
+func defaultDistro(t *testing.T, b backend.Backend) string {
+	t.Helper()
+
+	r, err := b.OpenLxssRegistry(context.Background(), ".")
+	require.NoError(t, err, "Could not open the registry")
+	guid, err := r.Field("DefaultDistribution")
+	r.Close()
+
+	if errors.Is(err, fs.ErrNotExist) || guid == "" {
+		return ""
+	}
+	require.NoError(t, err, "Could not read the default distro from the registry")
+
+	k, err := b.OpenLxssRegistry(context.Background(), guid)
+	require.NoError(t, err, "Could not open the registry key of the default distro")
+	defer k.Close()
+
+	name, err := k.Field("DistributionName")
+	require.NoError(t, err, "Could not read the name of the default distro")
+
+	return name
+}
+
+// requireState checks that the distro is in the expected state.
+func requireState(t *testing.T, b backend.Backend, name string, want state.State, msg string) {
+	t.Helper()
+
+	got, err := b.State(context.Background(), name)
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, want, got, msg)
+}
+
+// getConfiguration returns the configuration of the distro.
+func getConfiguration(t *testing.T, b backend.Backend, name string) (version uint8, uid uint32, wslFlags flags.WslFlags, env map[string]string) {
+	t.Helper()
+
+	err := b.WslGetDistributionConfiguration(context.Background(), name, &version, &uid, &wslFlags, &env)
+	require.NoError(t, err, "WslGetDistributionConfiguration should have succeeded")
+
+	return version, uid, wslFlags, env
+}
+
+// launchInteractive runs a command via WslLaunchInteractive and returns its exit code.
+func launchInteractive(t *testing.T, b backend.Backend, name, command string) uint32 {
+	t.Helper()
+
+	exitCode, err := b.WslLaunchInteractive(context.Background(), name, command, false)
+	require.NoError(t, err, "WslLaunchInteractive should have succeeded")
+
+	return exitCode
+}
+
+// launch runs a command via WslLaunch and returns its output and exit code.
+func launch(t *testing.T, b backend.Backend, name, command string) (stdout, stderr string, exitCode int) {
+	t.Helper()
+
+	stdinR, stdinW, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create stdin pipe")
+	defer stdinR.Close()
+	stdinW.Close()
+
+	stdoutR, stdoutW, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create stdout pipe")
+	defer stdoutR.Close()
+
+	stderrR, stderrW, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create stderr pipe")
+	defer stderrR.Close()
+
+	p, err := b.WslLaunch(context.Background(), name, command, false, stdinR, stdoutW, stderrW)
+	stdoutW.Close()
+	stderrW.Close()
+	require.NoError(t, err, "WslLaunch should have succeeded")
+
+	errOut := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(stderrR)
+		errOut <- out
+	}()
+
+	out, err := io.ReadAll(stdoutR)
+	require.NoError(t, err, "Could not read standard output")
+
+	ps, err := p.Wait()
+	require.NoError(t, err, "Could not wait for the process")
+
+	return string(out), string(<-errOut), ps.ExitCode()
+}
+
+// pipes returns pipes suitable to be passed to WslLaunch. They are closed during cleanup.
+func pipes(t *testing.T) (stdin, stdout, stderr *os.File) {
+	t.Helper()
+
+	var files [3]*os.File
+	for i := range files {
+		r, w, err := os.Pipe()
+		require.NoError(t, err, "Setup: could not create pipe")
+		t.Cleanup(func() {
+			r.Close()
+			w.Close()
+		})
+
+		files[i] = w
+		if i == 0 {
+			files[i] = r
+		}
+	}
+
+	return files[0], files[1], files[2]
+}
@@ -0,0 +1,20 @@
+package gowsltest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/gowsltest"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestConformanceMock(t *testing.T) {
+	t.Parallel()
+
+	rootfs := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(rootfs, nil, 0600), "Setup: could not write rootfs")
+
+	gowsltest.RunConformance(t, mock.New(), rootfs)
+}
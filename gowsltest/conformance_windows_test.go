@@ -0,0 +1,25 @@
+//go:build !gowslmock
+
+package gowsltest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/gowsltest"
+	"github.com/ubuntu/gowsl/internal/backend/windows"
+)
+
+func TestConformanceWindows(t *testing.T) {
+	// The same image as in GoWSL's own tests.
+	rootfs, err := filepath.Abs(filepath.Join("..", "images", "daily-image.wsl"))
+	require.NoError(t, err, "Setup: could not get the path to the rootfs")
+
+	if _, err := os.Stat(rootfs); err != nil {
+		t.Skipf("Skipping: no image to register distros from: %v", err)
+	}
+
+	gowsltest.RunConformance(t, windows.Backend{}, rootfs)
+}
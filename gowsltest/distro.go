@@ -0,0 +1,196 @@
+package gowsltest
+
+// This file contains the disposable distros for tests of code that uses GoWSL.
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+)
+
+// Options configures the distros created by NewTestDistro.
+type Options struct {
+	// Rootfs is the tarball to register the distro from. It is required.
+	Rootfs string
+
+	// Client is the client to register the distro with, such as one using the mock back-end.
+	// By default, the distro is registered in the real WSL.
+	Client *wsl.Client
+
+	// Timeout limits how long registering the distro and waiting for it to be usable can take.
+	// It defaults to five minutes.
+	Timeout time.Duration
+}
+
+var (
+	// runPrefix is the prefix of the distros created by NewTestDistro in this process, so that
+	// leaks are not mistaken with the distros of other test binaries running at the same time.
+	runPrefix = fmt.Sprintf("%s%06x-", DistroPrefix, rand.IntN(1<<24)) //nolint:gosec // No need to be cryptographically secure for this
+
+	// distroCount makes the names of the distros created by a test unique.
+	distroCount atomic.Uint64
+
+	// clients are the clients that NewTestDistro registered distros with, to look for leaks in.
+	clients = struct {
+		sync.Mutex
+		set map[*wsl.Client]struct{}
+	}{set: make(map[*wsl.Client]struct{})}
+
+	defaultClient = sync.OnceValue(func() *wsl.Client { return wsl.NewClient() })
+)
+
+// NewTestDistro registers a distro from the rootfs tarball, with a name that is unique to the
+// test, and waits until commands can run in it. The distro is unregistered when the test and
+// its subtests complete. It fails the test if the distro cannot be registered.
+//
+// Use Main to check that no distro is left behind once the tests complete.
+func NewTestDistro(t testing.TB, opts Options) wsl.Distro {
+	t.Helper()
+
+	require.NotEmpty(t, opts.Rootfs, "Setup: NewTestDistro needs a rootfs to register the distro from")
+
+	c := opts.Client
+	if c == nil {
+		c = defaultClient()
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+
+	clients.Lock()
+	clients.set[c] = struct{}{}
+	clients.Unlock()
+
+	name := invalidChars.ReplaceAllString(fmt.Sprintf("%s%s-%d", runPrefix, t.Name(), distroCount.Add(1)), "-")
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	// The directory is removed after the distro is unregistered, as cleanups run in reverse order.
+	location := t.TempDir()
+
+	// A failed registration may still leave the distro behind.
+	d := c.Distro(name)
+	t.Cleanup(func() {
+		if err := unregister(d); err != nil {
+			t.Errorf("Cleanup: %v", err)
+		}
+	})
+
+	_, err := c.Import(ctx, name, opts.Rootfs, location)
+	require.NoError(t, err, "Setup: could not register distro %q", name)
+
+	waitUsable(t, ctx, d)
+
+	return d
+}
+
+// waitUsable waits until commands can run in the distro, which may be busy setting itself up.
+//
+//nolint:revive // The testing.T goes first, as in every other helper.
+func waitUsable(t testing.TB, ctx context.Context, d wsl.Distro) {
+	t.Helper()
+
+	for {
+		err := d.Command(ctx, "exit 0").Run()
+		if err == nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			require.Fail(t, "Setup: distro did not become usable", "%q: %v", d.Name(), err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// unregister unregisters the distro if it is registered, terminating it first in case it is busy.
+func unregister(d wsl.Distro) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	registered, err := d.IsRegistered(ctx)
+	if err != nil {
+		return fmt.Errorf("could not check if %q is registered: %v", d.Name(), err)
+	}
+	if !registered {
+		return nil
+	}
+
+	if err := d.Unregister(ctx); err == nil {
+		return nil
+	}
+
+	// Unregistering can fail while commands are running.
+	_ = d.Terminate(ctx)
+	if err := d.Unregister(ctx); err != nil {
+		return fmt.Errorf("could not unregister %q: %v", d.Name(), err)
+	}
+
+	return nil
+}
+
+// Leaks returns the distros created by NewTestDistro in this process that are still registered.
+func Leaks(ctx context.Context) ([]wsl.Distro, error) {
+	clients.Lock()
+	defer clients.Unlock()
+
+	var leaks []wsl.Distro
+	for c := range clients.set {
+		distros, err := c.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not look for leaked distros: %v", err)
+		}
+
+		for _, d := range distros {
+			// Names are case-insensitive, and the client may report them in lowercase.
+			if strings.HasPrefix(strings.ToLower(d.Name()), strings.ToLower(runPrefix)) {
+				leaks = append(leaks, d)
+			}
+		}
+	}
+
+	return leaks, nil
+}
+
+// Main runs the tests and checks that they did not leave distros behind. Leaked distros are
+// reported and unregistered, and make the tests fail. Call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(gowsltest.Main(m))
+//	}
+func Main(m *testing.M) int {
+	code := m.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	leaks, err := Leaks(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gowsltest: %v\n", err)
+		return max(code, 1)
+	}
+	if len(leaks) == 0 {
+		return code
+	}
+
+	fmt.Fprintln(os.Stderr, "gowsltest: the following distros were left behind by the tests:")
+	for _, d := range leaks {
+		fmt.Fprintf(os.Stderr, " - %s\n", d.Name())
+		if err := unregister(d); err != nil {
+			fmt.Fprintf(os.Stderr, "gowsltest: %v\n", err)
+		}
+	}
+
+	return max(code, 1)
+}
@@ -0,0 +1,136 @@
+package gowsltest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/gowsltest"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(gowsltest.Main(m))
+}
+
+func TestNewTestDistro(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := wsl.NewClient(wsl.WithClientBackend(mock.New()))
+	rootfs := emptyRootfs(t)
+
+	var distros []wsl.Distro
+	t.Run("Success registering distros", func(t *testing.T) {
+		for range 2 {
+			d := gowsltest.NewTestDistro(t, gowsltest.Options{Rootfs: rootfs, Client: c})
+			require.True(t, strings.HasPrefix(d.Name(), gowsltest.DistroPrefix), "The name of the distro should start with DistroPrefix")
+
+			s, err := d.State(ctx)
+			require.NoError(t, err, "State should have succeeded")
+			require.Contains(t, []wsl.State{wsl.Stopped, wsl.Running}, s, "The distro should be usable")
+
+			distros = append(distros, d)
+		}
+		require.NotEqual(t, distros[0].Name(), distros[1].Name(), "Distros should have unique names")
+	})
+
+	for _, d := range distros {
+		registered, err := d.IsRegistered(ctx)
+		require.NoError(t, err, "IsRegistered should have succeeded")
+		require.False(t, registered, "Distros should be unregistered once the test completes")
+	}
+}
+
+func TestLeaks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := mock.New()
+	c := wsl.NewClient(wsl.WithClientBackend(m))
+
+	ft := &fakeT{TB: t}
+	d := gowsltest.NewTestDistro(ft, gowsltest.Options{Rootfs: emptyRootfs(t), Client: c})
+	requireLeaked(t, ctx, d, true, "Distros should be reported as leaked until they are cleaned up")
+
+	m.InjectFault("WslUnregisterDistribution", mock.Fault{})
+	ft.cleanup()
+	require.True(t, ft.failed, "Failing to unregister the distro should fail the test")
+	requireLeaked(t, ctx, d, true, "Distros that could not be unregistered should be reported as leaked")
+
+	m.ClearFaults()
+	ft.failed = false
+	ft.cleanup()
+	require.False(t, ft.failed, "Unregistering the distro should have succeeded")
+	requireLeaked(t, ctx, d, false, "Unregistered distros should not be reported as leaked")
+}
+
+func TestNewTestDistroError(t *testing.T) {
+	t.Parallel()
+
+	c := wsl.NewClient(wsl.WithClientBackend(mock.New()))
+
+	ft := &fakeT{TB: t}
+	func() {
+		// The test is stopped with runtime.Goexit, which fakeT replaces with a panic.
+		defer func() { _ = recover() }()
+		gowsltest.NewTestDistro(ft, gowsltest.Options{Rootfs: filepath.Join(t.TempDir(), "not-a-file.tar.gz"), Client: c})
+	}()
+	require.True(t, ft.failed, "NewTestDistro should have failed the test")
+
+	ft.failed = false
+	ft.cleanup()
+	require.False(t, ft.failed, "Cleaning up a distro that could not be registered should succeed")
+}
+
+// fakeT is a testing.TB that records failures and cleanups instead of acting on them.
+type fakeT struct {
+	testing.TB
+	failed   bool
+	cleanups []func()
+}
+
+func (t *fakeT) Errorf(string, ...any) { t.failed = true }
+func (t *fakeT) FailNow()              { t.failed = true; panic("FailNow") }
+func (t *fakeT) Cleanup(f func())      { t.cleanups = append(t.cleanups, f) }
+
+// cleanup runs the cleanups in reverse order, as the testing package does.
+func (t *fakeT) cleanup() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+// requireLeaked checks whether Leaks reports the distro.
+//
+//nolint:revive // The testing.T goes first, as in every other helper.
+func requireLeaked(t *testing.T, ctx context.Context, d wsl.Distro, want bool, msg string) {
+	t.Helper()
+
+	leaks, err := gowsltest.Leaks(ctx)
+	require.NoError(t, err, "Leaks should have succeeded")
+
+	var names []string
+	for _, l := range leaks {
+		names = append(names, strings.ToLower(l.Name()))
+	}
+
+	if want {
+		require.Contains(t, names, strings.ToLower(d.Name()), msg)
+	} else {
+		require.NotContains(t, names, strings.ToLower(d.Name()), msg)
+	}
+}
+
+// emptyRootfs writes an empty rootfs tarball, which is enough for the mock back-end.
+func emptyRootfs(t *testing.T) string {
+	t.Helper()
+
+	rootfs := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(rootfs, nil, 0600), "Setup: could not write rootfs")
+	return rootfs
+}
@@ -0,0 +1,38 @@
+package windows
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+// abandonable runs an operation that cannot be cancelled. If the context is done before it
+// finishes, it stops waiting for it, and returns an error wrapping backend.ErrAbandoned and the
+// cause of the context. The operation then goes on in the background, and cleanup, if set, is
+// called once it finishes.
+func abandonable(ctx context.Context, f func() error, cleanup func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	if cleanup != nil {
+		go func() {
+			<-done
+			cleanup()
+		}()
+	}
+
+	return fmt.Errorf("%w: %w", backend.ErrAbandoned, context.Cause(ctx))
+}
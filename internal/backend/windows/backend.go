@@ -6,20 +6,104 @@
 package windows
 
 import (
+	"bytes"
 	"context"
-	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"time"
+
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/backend/governor"
 )
 
 // Backend implements the Backend interface.
-type Backend struct{}
+//
+// Its configuration is behind a pointer so that back-ends can be compared: distros are
+// equal only if they share the same back-end.
+type Backend struct {
+	cfg *config
+}
+
+type config struct {
+	onExec   func(backend.Exec) // Called after running each command, if set
+	governor *governor.Governor // Limits and retries the invocations of wsl.exe, if set
+	wslExe   string             // Path to wsl.exe, if not the one in the PATH
+	timeout  time.Duration      // Timeout of the quick wsl.exe commands, if not the default one
+}
+
+// with returns a copy of the back-end with a modified copy of the configuration.
+func (b Backend) with(f func(*config)) Backend {
+	var c config
+	if b.cfg != nil {
+		c = *b.cfg
+	}
+	f(&c)
+	return Backend{cfg: &c}
+}
+
+// config returns the configuration of the back-end, which is empty by default.
+func (b Backend) config() config {
+	if b.cfg == nil {
+		return config{}
+	}
+	return *b.cfg
+}
+
+// WithGovernor returns a copy of the back-end whose invocations of wsl.exe are limited
+// and retried by the governor.
+func (b Backend) WithGovernor(g *governor.Governor) Backend {
+	return b.with(func(c *config) { c.governor = g })
+}
+
+// WithExecHook returns a copy of the back-end that calls the hook after running each
+// command, such as wsl.exe.
+func (b Backend) WithExecHook(hook func(backend.Exec)) backend.Backend {
+	return b.with(func(c *config) { c.onExec = hook })
+}
+
+// WithWslExe returns a copy of the back-end that runs wsl.exe from the path provided
+// instead of looking it up in the PATH.
+func (b Backend) WithWslExe(path string) Backend {
+	return b.with(func(c *config) { c.wslExe = path })
+}
+
+// WithTimeout returns a copy of the back-end whose wsl.exe commands that are expected
+// to return quickly, such as --terminate or --list, time out after the duration provided.
+// Commands that may take long, such as --install or --import, are not affected.
+func (b Backend) WithTimeout(timeout time.Duration) Backend {
+	return b.with(func(c *config) { c.timeout = timeout })
+}
+
+// run runs the command, and reports it to the hook.
+func (b Backend) run(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderr
+	} else {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, &stderr)
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+
+	if hook := b.config().onExec; hook != nil {
+		hook(backend.Exec{
+			Args:     cmd.Args,
+			Stderr:   stderr.String(),
+			ExitCode: cmd.ProcessState.ExitCode(),
+			Duration: time.Since(start),
+		})
+	}
+
+	return err
+}
 
 // ErrNotExist is returned when a distro does not exist.
-var ErrNotExist = errors.New("distro does not exist")
+var ErrNotExist = backend.ErrNotExist
 
 // RemoveAppxFamily uninstalls the Appx under the provided family name.
-func (Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string) error {
+func (b Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string) error {
 	cmd := exec.CommandContext(ctx,
 		"powershell.exe",
 		"-NonInteractive",
@@ -30,8 +114,11 @@ func (Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string) e
 	)
 	cmd.Env = append(cmd.Env, fmt.Sprintf("PackageFamilyName=%q", packageFamilyName))
 
-	if out, err := cmd.Output(); err != nil {
-		return fmt.Errorf("could not uninstall %q: %v. %s", packageFamilyName, err, out)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := b.run(cmd); err != nil {
+		return fmt.Errorf("could not uninstall %q: %v. %s", packageFamilyName, err, out.String())
 	}
 
 	return nil
@@ -1,11 +1,12 @@
 package windows
 
 import (
+	"context"
 	"errors"
 	"path/filepath"
 
 	"github.com/ubuntu/decorate"
-	"github.com/ubuntu/gowsl/internal/backend"
+	"github.com/ubuntu/gowsl/backend"
 )
 
 // RegistryKey wraps around a Windows registry key.
@@ -21,7 +22,7 @@ const (
 
 // OpenLxssRegistry opens a registry key at the chosen path.
 // This implementation will always fail on Linux.
-func (Backend) OpenLxssRegistry(path string) (r backend.RegistryKey, err error) {
+func (Backend) OpenLxssRegistry(ctx context.Context, path string) (r backend.RegistryKey, err error) {
 	p := filepath.Join(lxssPath, path)
 	r = RegistryKey{
 		path: p,
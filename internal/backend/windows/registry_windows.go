@@ -1,6 +1,7 @@
 package windows
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -8,7 +9,7 @@ import (
 	"syscall"
 
 	"github.com/ubuntu/decorate"
-	"github.com/ubuntu/gowsl/internal/backend"
+	"github.com/ubuntu/gowsl/backend"
 	"golang.org/x/sys/windows/registry"
 )
 
@@ -20,12 +21,16 @@ type RegistryKey struct {
 }
 
 // OpenLxssRegistry opens a registry key at the chosen path.
-func (Backend) OpenLxssRegistry(path string) (r backend.RegistryKey, err error) {
+func (Backend) OpenLxssRegistry(ctx context.Context, path string) (r backend.RegistryKey, err error) {
 	const lxssPath = `Software\Microsoft\Windows\CurrentVersion\Lxss\` // Path to the Lxss registry key. All WSL info is under this path
 
 	p := filepath.Join(lxssPath, path)
 	defer decorate.OnError(&err, "registry: could not open HKEY_CURRENT_USER\\%s", p)
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	k, err := registry.OpenKey(registry.CURRENT_USER, p, registry.READ)
 	if err != nil {
 		return nil, err
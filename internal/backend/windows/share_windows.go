@@ -0,0 +1,6 @@
+package windows
+
+// ShareRoot returns the network share where WSL exposes the filesystem of the distro.
+func (Backend) ShareRoot(distroName string) (string, bool) {
+	return `\\wsl.localhost\` + distroName, true
+}
@@ -3,6 +3,7 @@ package windows
 // This file contains mocks for Win32 API definitions and imports.
 
 import (
+	"context"
 	"errors"
 	"math"
 	"os"
@@ -14,7 +15,7 @@ import (
 // WslConfigureDistribution is a wrapper around the WslConfigureDistribution
 // function in the wslApi.dll Win32 library.
 // This implementation will always fail on Linux.
-func (Backend) WslConfigureDistribution(distributionName string, defaultUID uint32, wslDistributionFlags flags.WslFlags) (err error) {
+func (Backend) WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags flags.WslFlags) (err error) {
 	defer decorate.OnError(&err, "WslConfigureDistribution")
 	return errors.New("not implemented")
 }
@@ -22,7 +23,7 @@ func (Backend) WslConfigureDistribution(distributionName string, defaultUID uint
 // WslGetDistributionConfiguration is a wrapper around the WslGetDistributionConfiguration
 // function in the wslApi.dll Win32 library.
 // This implementation will always fail on Linux.
-func (Backend) WslGetDistributionConfiguration(distributionName string,
+func (Backend) WslGetDistributionConfiguration(ctx context.Context, distributionName string,
 	distributionVersion *uint8,
 	defaultUID *uint32,
 	wslDistributionFlags *flags.WslFlags,
@@ -34,7 +35,8 @@ func (Backend) WslGetDistributionConfiguration(distributionName string,
 // WslLaunch is a wrapper around the WslLaunch
 // function in the wslApi.dll Win32 library.
 // This implementation will always fail on Linux.
-func (Backend) WslLaunch(distroName string,
+func (Backend) WslLaunch(ctx context.Context,
+	distroName string,
 	command string,
 	useCWD bool,
 	stdin *os.File,
@@ -47,7 +49,7 @@ func (Backend) WslLaunch(distroName string,
 // WslLaunchInteractive is a wrapper around the WslLaunchInteractive
 // function in the wslApi.dll Win32 library.
 // This implementation will always fail on Linux.
-func (Backend) WslLaunchInteractive(distributionName string, command string, useCurrentWorkingDirectory bool) (exitCode uint32, err error) {
+func (Backend) WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (exitCode uint32, err error) {
 	defer decorate.OnError(&err, "WslLaunchInteractive")
 	return math.MaxUint32, errors.New("not implemented")
 }
@@ -55,7 +57,7 @@ func (Backend) WslLaunchInteractive(distributionName string, command string, use
 // WslRegisterDistribution is a wrapper around the WslRegisterDistribution
 // function in the wslApi.dll Win32 library.
 // This implementation will always fail on Linux.
-func (Backend) WslRegisterDistribution(distributionName string, tarGzFilename string) (err error) {
+func (Backend) WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) (err error) {
 	defer decorate.OnError(&err, "WslRegisterDistribution")
 	return errors.New("not implemented")
 }
@@ -63,7 +65,7 @@ func (Backend) WslRegisterDistribution(distributionName string, tarGzFilename st
 // WslUnregisterDistribution is a wrapper around the WslUnregisterDistribution
 // function in the wslApi.dll Win32 library.
 // This implementation will always fail on Linux.
-func (Backend) WslUnregisterDistribution(distributionName string) (err error) {
+func (Backend) WslUnregisterDistribution(ctx context.Context, distributionName string) (err error) {
 	defer decorate.OnError(&err, "WslUnregisterDistribution")
 	return errors.New("not implemented")
 }
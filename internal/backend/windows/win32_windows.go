@@ -3,6 +3,7 @@ package windows
 // This file contains Win32 API definitions and imports.
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -49,7 +50,8 @@ func (Backend) IsPipe(f *os.File) (bool, error) {
 
 // WslLaunch is a wrapper around the WslLaunch
 // function in the wslApi.dll Win32 library.
-func (Backend) WslLaunch(
+func (b Backend) WslLaunch(
+	ctx context.Context,
 	distroName string,
 	command string,
 	useCWD bool,
@@ -58,6 +60,10 @@ func (Backend) WslLaunch(
 	stderr *os.File) (process *os.Process, err error) {
 	defer decorate.OnError(&err, "WslLaunch")
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	distroUTF16, err := syscall.UTF16PtrFromString(distroName)
 	if err != nil {
 		return nil, errors.New("could not convert distro name to UTF16")
@@ -83,7 +89,7 @@ func (Backend) WslLaunch(
 		stderr.Fd(),
 		uintptr(unsafe.Pointer(&handle)))
 	if err != nil {
-		return nil, err
+		return nil, b.notExist(distroName, err)
 	}
 
 	if handle == windows.Handle(0) {
@@ -100,9 +106,13 @@ func (Backend) WslLaunch(
 
 // WslConfigureDistribution is a wrapper around the WslConfigureDistribution
 // function in the wslApi.dll Win32 library.
-func (Backend) WslConfigureDistribution(distributionName string, defaultUID uint32, wslDistributionFlags flags.WslFlags) (err error) {
+func (b Backend) WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags flags.WslFlags) (err error) {
 	defer decorate.OnError(&err, "WslConfigureDistribution")
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	distroUTF16, err := syscall.UTF16PtrFromString(distributionName)
 	if err != nil {
 		return errors.New("could not convert distro name to UTF16")
@@ -114,7 +124,7 @@ func (Backend) WslConfigureDistribution(distributionName string, defaultUID uint
 		uintptr(wslDistributionFlags),
 	)
 	if err != nil {
-		return err
+		return b.notExist(distributionName, err)
 	}
 
 	return nil
@@ -122,13 +132,17 @@ func (Backend) WslConfigureDistribution(distributionName string, defaultUID uint
 
 // WslGetDistributionConfiguration is a wrapper around the WslGetDistributionConfiguration
 // function in the wslApi.dll Win32 library.
-func (Backend) WslGetDistributionConfiguration(distributionName string,
+func (b Backend) WslGetDistributionConfiguration(ctx context.Context, distributionName string,
 	distributionVersion *uint8,
 	defaultUID *uint32,
 	wslDistributionFlags *flags.WslFlags,
 	defaultEnvironmentVariables *map[string]string) (err error) {
 	defer decorate.OnError(&err, "WslGetDistributionConfiguration")
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	distroUTF16, err := syscall.UTF16PtrFromString(distributionName)
 	if err != nil {
 		return errors.New("could not convert distro name to UTF16")
@@ -149,7 +163,7 @@ func (Backend) WslGetDistributionConfiguration(distributionName string,
 	)
 
 	if err != nil {
-		return err
+		return b.notExist(distributionName, err)
 	}
 
 	*defaultEnvironmentVariables = processEnvVariables(envVarsBegin, envVarsLen)
@@ -158,7 +172,9 @@ func (Backend) WslGetDistributionConfiguration(distributionName string,
 
 // WslLaunchInteractive is a wrapper around the WslLaunchInteractive
 // function in the wslApi.dll Win32 library.
-func (Backend) WslLaunchInteractive(distributionName string, command string, useCurrentWorkingDirectory bool) (exitCode uint32, err error) {
+//
+// It is abandoned if the context is done before the command exits.
+func (b Backend) WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (exitCode uint32, err error) {
 	defer decorate.OnError(&err, "WslLaunchInteractive")
 
 	exitCode = math.MaxUint32
@@ -177,22 +193,35 @@ func (Backend) WslLaunchInteractive(distributionName string, command string, use
 		useCwd = 1
 	}
 
-	r, err := callDll(apiWslLaunchInteractive,
-		uintptr(unsafe.Pointer(distroUTF16)),
-		uintptr(unsafe.Pointer(commandUTF16)),
-		uintptr(useCwd),
-		uintptr(unsafe.Pointer(&exitCode)))
+	// The call writes the exit code in the background if it is abandoned.
+	code := new(uint32)
+	var r uint32
+	err = abandonable(ctx, func() (err error) {
+		r, err = callDll(apiWslLaunchInteractive,
+			uintptr(unsafe.Pointer(distroUTF16)),
+			uintptr(unsafe.Pointer(commandUTF16)),
+			uintptr(useCwd),
+			uintptr(unsafe.Pointer(code)))
+		return err
+	}, nil)
 
+	if err != nil && ctx.Err() != nil {
+		// Abandoned, or never started.
+		return exitCode, err
+	}
 	if err != nil {
-		return r, err
+		return r, b.notExist(distributionName, err)
 	}
 
-	return exitCode, nil
+	return *code, nil
 }
 
 // WslRegisterDistribution is a wrapper around the WslRegisterDistribution
 // function in the wslApi.dll Win32 library.
-func (Backend) WslRegisterDistribution(distributionName string, tarGzFilename string) (err error) {
+//
+// It is abandoned if the context is done before the distro is registered. The distro is
+// then unregistered as soon as the registration finishes, whether it succeeded or not.
+func (b Backend) WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) (err error) {
 	defer decorate.OnError(&err, "WslRegisterDistribution")
 
 	distroUTF16, err := syscall.UTF16PtrFromString(distributionName)
@@ -205,20 +234,24 @@ func (Backend) WslRegisterDistribution(distributionName string, tarGzFilename st
 		return errors.New("could not convert rootfs path to UTF16")
 	}
 
-	_, err = callDll(apiWslRegisterDistribution,
-		uintptr(unsafe.Pointer(distroUTF16)),
-		uintptr(unsafe.Pointer(tarGzFilenameUTF16)))
-
-	if err != nil {
+	return abandonable(ctx, func() error {
+		_, err := callDll(apiWslRegisterDistribution,
+			uintptr(unsafe.Pointer(distroUTF16)),
+			uintptr(unsafe.Pointer(tarGzFilenameUTF16)))
 		return err
-	}
-
-	return nil
+	}, func() {
+		// Nobody is waiting for this distro anymore.
+		if b.isRegistered(distributionName) {
+			_, _ = callDll(apiWslUnregisterDistribution, uintptr(unsafe.Pointer(distroUTF16)))
+		}
+	})
 }
 
 // WslUnregisterDistribution is a wrapper around the WslUnregisterDistribution
 // function in the wslApi.dll Win32 library.
-func (Backend) WslUnregisterDistribution(distributionName string) (err error) {
+//
+// It is abandoned if the context is done before the distro is unregistered.
+func (b Backend) WslUnregisterDistribution(ctx context.Context, distributionName string) (err error) {
 	defer decorate.OnError(&err, "WslUnregisterDistribution")
 
 	distroUTF16, err := syscall.UTF16PtrFromString(distributionName)
@@ -226,14 +259,61 @@ func (Backend) WslUnregisterDistribution(distributionName string) (err error) {
 		return errors.New("could not convert distro name to UTF16")
 	}
 
-	_, err = callDll(apiWslUnregisterDistribution, uintptr(unsafe.Pointer(distroUTF16)))
-	if err != nil {
+	err = abandonable(ctx, func() error {
+		_, err := callDll(apiWslUnregisterDistribution, uintptr(unsafe.Pointer(distroUTF16)))
+		return err
+	}, nil)
+
+	if err != nil && ctx.Err() != nil {
+		// Abandoned, or never started.
 		return err
 	}
+	if err != nil {
+		return b.notExist(distributionName, err)
+	}
 
 	return nil
 }
 
+// notExist returns ErrNotExist, wrapping the error of a failed syscall, if the
+// distro is not registered. Otherwise, it returns the error as is.
+func (b Backend) notExist(distroName string, err error) error {
+	if b.isRegistered(distroName) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrNotExist, err)
+}
+
+// isRegistered checks in the registry whether a distro is registered. It errs on the side of
+// caution: if the registry cannot be read, the distro is assumed to be registered.
+func (b Backend) isRegistered(distroName string) bool {
+	r, err := b.OpenLxssRegistry(context.Background(), ".")
+	if err != nil {
+		return true
+	}
+	defer r.Close()
+
+	subkeys, err := r.SubkeyNames()
+	if err != nil {
+		return true
+	}
+
+	for _, guid := range subkeys {
+		k, err := b.OpenLxssRegistry(context.Background(), guid)
+		if err != nil {
+			continue
+		}
+		name, err := k.Field("DistributionName")
+		k.Close()
+
+		if err == nil && strings.EqualFold(name, distroName) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // callDll calls a proc with the given arguments. It exists for two reasons:
 // - Avoids a panic if the DLL cannot be found, or the method cannot be found within the DLL.
 // - Interprets the return value of the syscall so that the caller only needs to check for errors.
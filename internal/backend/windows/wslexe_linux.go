@@ -11,25 +11,25 @@ import (
 
 // Shutdown shuts down all distros
 // This implementation will always fail on Linux.
-func (Backend) Shutdown() error {
+func (Backend) Shutdown(ctx context.Context) error {
 	return errors.New("not implemented")
 }
 
 // Terminate shuts down a particular distro
 // This implementation will always fail on Linux.
-func (Backend) Terminate(distroName string) error {
+func (Backend) Terminate(ctx context.Context, distroName string) error {
 	return errors.New("not implemented")
 }
 
 // SetAsDefault sets a particular distribution as the default one.
 // This implementation will always fail on Linux.
-func (Backend) SetAsDefault(distroName string) error {
+func (Backend) SetAsDefault(ctx context.Context, distroName string) error {
 	return errors.New("not implemented")
 }
 
 // State returns the state of a particular distro as seen in `wsl.exe -l -v`.
 // This implementation will always fail on Linux.
-func (Backend) State(distributionName string) (s state.State, err error) {
+func (Backend) State(ctx context.Context, distributionName string) (s state.State, err error) {
 	return s, errors.New("not implemented")
 }
 
@@ -10,9 +10,11 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/ubuntu/gowsl/backend/governor"
 	"github.com/ubuntu/gowsl/internal/state"
 )
 
@@ -24,11 +26,8 @@ var errWslTimeout = errors.New("wsl.exe did not respond: consider restarting wsl
 // It is analogous to
 //
 //	`wsl.exe --Shutdown
-func (Backend) Shutdown() error {
-	ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Second, errWslTimeout)
-	defer cancel()
-
-	_, err := wslExe(ctx, "--shutdown")
+func (b Backend) Shutdown(ctx context.Context) error {
+	_, err := b.wslExe(ctx, 10*time.Second, "--shutdown")
 	if err != nil {
 		return fmt.Errorf("could not shut WSL down: %w", err)
 	}
@@ -40,11 +39,8 @@ func (Backend) Shutdown() error {
 // It is analogous to
 //
 //	`wsl.exe --Terminate <distroName>`
-func (Backend) Terminate(distroName string) error {
-	ctx, cancel := context.WithTimeoutCause(context.Background(), 5*time.Second, errWslTimeout)
-	defer cancel()
-
-	_, err := wslExe(ctx, "--terminate", distroName)
+func (b Backend) Terminate(ctx context.Context, distroName string) error {
+	_, err := b.wslExe(ctx, 5*time.Second, "--terminate", distroName)
 	if err != nil {
 		return fmt.Errorf("could not terminate distro %q: %w", distroName, err)
 	}
@@ -56,11 +52,8 @@ func (Backend) Terminate(distroName string) error {
 // It is analogous to
 //
 //	`wsl.exe --set-default <distroName>`
-func (Backend) SetAsDefault(distroName string) error {
-	ctx, cancel := context.WithTimeoutCause(context.Background(), 5*time.Second, errWslTimeout)
-	defer cancel()
-
-	_, err := wslExe(ctx, "--set-default", distroName)
+func (b Backend) SetAsDefault(ctx context.Context, distroName string) error {
+	_, err := b.wslExe(ctx, 5*time.Second, "--set-default", distroName)
 	if err != nil {
 		return fmt.Errorf("could not set %q as default: %w", distroName, err)
 	}
@@ -68,11 +61,8 @@ func (Backend) SetAsDefault(distroName string) error {
 }
 
 // State returns the state of a particular distro as seen in `wsl.exe -l -v`.
-func (Backend) State(distributionName string) (s state.State, err error) {
-	ctx, cancel := context.WithTimeoutCause(context.Background(), 5*time.Second, errWslTimeout)
-	defer cancel()
-
-	out, err := wslExe(ctx, "--list", "--all", "--verbose")
+func (b Backend) State(ctx context.Context, distributionName string) (s state.State, err error) {
+	out, err := b.wslExe(ctx, 5*time.Second, "--list", "--all", "--verbose")
 	if err != nil {
 		return s, fmt.Errorf("could not get states of distros: %w", err)
 	}
@@ -109,7 +99,7 @@ func (Backend) State(distributionName string) (s state.State, err error) {
 // Install installs a new distro from the Windows store.
 func (b Backend) Install(ctx context.Context, appxName string) error {
 	// Using --no-launch to avoid registration and (non-interactive) user creation.
-	_, err := wslExe(ctx, "--install", appxName, "--no-launch")
+	_, err := b.wslExe(ctx, 0, "--install", appxName, "--no-launch")
 	if err != nil {
 		return fmt.Errorf("could not install %q: %w", appxName, err)
 	}
@@ -118,20 +108,68 @@ func (b Backend) Install(ctx context.Context, appxName string) error {
 
 // Import creates a new distro from a source root filesystem.
 func (b Backend) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) error {
-	_, err := wslExe(ctx, "--import", distributionName, destinationPath, sourcePath)
+	_, err := b.wslExe(ctx, 0, "--import", distributionName, destinationPath, sourcePath)
 	if err != nil {
-		return fmt.Errorf("could not install %s: %v", distributionName, err)
+		return fmt.Errorf("could not install %s: %w", distributionName, err)
 	}
 
 	return nil
 }
 
+// idempotentCommands are the wsl.exe commands that are safe to retry. Others, such as --import,
+// may have gone through even if they failed, so retrying them could fail or repeat their effects.
+var idempotentCommands = []string{"--list", "--terminate", "--set-default", "--shutdown"}
+
 // wslExe is a helper function to run wsl.exe with the given arguments.
 // It returns the stdout, or an error containing both stdout and stderr.
-func wslExe(ctx context.Context, args ...string) ([]byte, error) {
+//
+// Each attempt to run it times out after the timeout, unless it is zero. The back-end's
+// timeout, if set, replaces the non-zero ones. Attempts are limited by the governor, if
+// there is one, which also retries the idempotent commands.
+func (b Backend) wslExe(ctx context.Context, timeout time.Duration, args ...string) (out []byte, err error) {
+	g := b.config().governor
+	if g == nil {
+		return b.wslExeOnce(ctx, timeout, args...)
+	}
+
+	do := g.Once
+	if slices.Contains(idempotentCommands, args[0]) {
+		do = g.Do
+	}
+
+	err = do(ctx, func(ctx context.Context) (err error) {
+		out, err = b.wslExeOnce(ctx, timeout, args...)
+		return err
+	})
+	return out, err
+}
+
+// transientErrors are the error codes of wsl.exe that are worth retrying.
+var transientErrors = []string{
+	"E_UNEXPECTED",
+	"RPC_S_SERVER_UNAVAILABLE",
+	"RPC_S_CALL_FAILED",
+}
+
+func (b Backend) wslExeOnce(ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+	cfg := b.config()
+	if timeout > 0 && cfg.timeout > 0 {
+		timeout = cfg.timeout
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, errWslTimeout)
+		defer cancel()
+	}
+
 	var stdout, stderr bytes.Buffer
 
-	cmd := exec.CommandContext(ctx, "wsl.exe", args...)
+	exe := "wsl.exe"
+	if cfg.wslExe != "" {
+		exe = cfg.wslExe
+	}
+	cmd := exec.CommandContext(ctx, exe, args...)
 
 	// Avoid output encoding issues (WSL uses UTF-16 by default)
 	cmd.Env = append(os.Environ(), "WSL_UTF8=1")
@@ -139,11 +177,19 @@ func wslExe(ctx context.Context, args ...string) ([]byte, error) {
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err := b.run(cmd)
 	if err == nil {
 		return stdout.Bytes(), nil
 	}
 
+	if errors.Is(context.Cause(ctx), errWslTimeout) {
+		return nil, governor.Transient(errWslTimeout)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	out := stdout.String()
 	e := stderr.String()
 
@@ -155,5 +201,12 @@ func wslExe(ctx context.Context, args ...string) ([]byte, error) {
 		return nil, ErrNotExist
 	}
 
-	return nil, fmt.Errorf("%v. Stdout: %s. Stderr: %s", err, out, e)
+	err = fmt.Errorf("%v. Stdout: %s. Stderr: %s", err, out, e)
+	for _, code := range transientErrors {
+		if strings.Contains(out, code) || strings.Contains(e, code) {
+			return nil, governor.Transient(err)
+		}
+	}
+
+	return nil, err
 }
@@ -0,0 +1,67 @@
+// Package errkind serialises the identity of the errors returned by back-ends, so that
+// errors.Is still matches them after they are sent over the wire or written to disk.
+package errkind
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/ubuntu/gowsl/backend"
+)
+
+// Kinds of errors that keep their identity.
+const (
+	NotExist         = "not-exist"
+	FileNotExist     = "file-not-exist"
+	Abandoned        = "abandoned"
+	Canceled         = "canceled"
+	DeadlineExceeded = "deadline-exceeded"
+)
+
+// kinds maps the kinds of errors to the errors they match, in order of precedence.
+var kinds = []struct {
+	kind string
+	err  error
+}{
+	{NotExist, backend.ErrNotExist},
+	{FileNotExist, fs.ErrNotExist},
+	{Abandoned, backend.ErrAbandoned},
+	{Canceled, context.Canceled},
+	{DeadlineExceeded, context.DeadlineExceeded},
+}
+
+// Of returns the kind of the error, or an empty string if it is none of the known ones.
+func Of(err error) string {
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return ""
+}
+
+// Error is an error that was serialised. It matches the same known error as the original one.
+type Error struct {
+	Message string `json:"message"        yaml:"message"`
+	Kind    string `json:"kind,omitempty" yaml:"kind,omitempty"`
+}
+
+// New serialises the error.
+func New(err error) *Error {
+	return &Error{Message: err.Error(), Kind: Of(err)}
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Unwrap returns the known error that the original error matched, if any.
+func (err *Error) Unwrap() error {
+	for _, k := range kinds {
+		if k.kind == err.Kind {
+			return k.err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package errkind_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/errkind"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err error
+
+		want     error
+		wantKind string
+	}{
+		"Distros that do not exist":       {err: fmt.Errorf("could not terminate: %w", backend.ErrNotExist), want: backend.ErrNotExist, wantKind: errkind.NotExist},
+		"Files that do not exist":         {err: fmt.Errorf("registry: %w", fs.ErrNotExist), want: fs.ErrNotExist, wantKind: errkind.FileNotExist},
+		"Abandoned operations":            {err: fmt.Errorf("register: %w: %w", backend.ErrAbandoned, context.Canceled), want: backend.ErrAbandoned, wantKind: errkind.Abandoned},
+		"Cancelled contexts":              {err: context.Canceled, want: context.Canceled, wantKind: errkind.Canceled},
+		"Contexts past their deadline":    {err: context.DeadlineExceeded, want: context.DeadlineExceeded, wantKind: errkind.DeadlineExceeded},
+		"Other errors keep their message": {err: errors.New("some error")},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := json.Marshal(errkind.New(tc.err))
+			require.NoError(t, err, "Setup: could not marshal error")
+
+			var got *errkind.Error
+			require.NoError(t, json.Unmarshal(out, &got), "Setup: could not unmarshal error")
+
+			require.Equal(t, tc.wantKind, got.Kind, "Unexpected kind")
+			require.Equal(t, tc.err.Error(), got.Error(), "The message should be kept")
+			if tc.want != nil {
+				require.ErrorIs(t, got, tc.want, "The error should match the same known error as the original")
+			} else {
+				require.NoError(t, got.Unwrap(), "Unknown errors should not match any known error")
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package rootfs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// script runs a command chrooted into a distro's root. It must run in a new user and mount
+// namespace, so that it can mount the host's tools into roots that have none.
+const script = `root=$1
+set -e
+if [ ! -e "$root/bin/sh" ] && [ ! -e "$root/usr/bin/sh" ]; then
+	for d in bin sbin lib lib32 lib64 usr; do
+		if [ -e "/$d" ]; then
+			mkdir -p "$root/$d"
+			mount --rbind "/$d" "$root/$d"
+		fi
+	done
+fi
+mkdir -p "$root/dev"
+mount --rbind /dev "$root/dev"
+exec chroot "$root" /bin/sh -c 'cd "$HOME" 2>/dev/null || cd /; eval "$1"' sh "$2"
+`
+
+// Command returns a command that runs the shell command line chrooted into root, as root in a
+// user namespace so that no privileges are needed. If the root has no /bin/sh, the host's /usr,
+// /bin, /lib and so on are mounted into it. The caller sets the environment.
+func Command(ctx context.Context, root, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", script, "sandbox", root, command)
+	cmd.Env = []string{}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+
+	return cmd
+}
@@ -0,0 +1,132 @@
+// Package rootfs manages the root filesystems of distros that run on the host, rather than in WSL.
+package rootfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extract extracts a tarball, optionally gzipped, into root. Ownership is not preserved, and
+// entries that are neither directories, regular files, nor links are skipped.
+func Extract(root, tarball string) error {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not read %s: %v", tarball, err)
+		}
+
+		if err := extractEntry(root, hdr, tr); err != nil {
+			return fmt.Errorf("could not extract %s: %v", hdr.Name, err)
+		}
+	}
+}
+
+func extractEntry(root string, hdr *tar.Header, r io.Reader) error {
+	path, err := rootPath(root, hdr.Name)
+	if err != nil {
+		return err
+	}
+
+	// Following symlinks could take us out of the root.
+	if err := requireNoSymlinks(root, filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// We keep write access to everything so that the root can be modified and removed.
+	mode := os.FileMode(hdr.Mode).Perm() | 0200
+
+	// Entries may replace previous ones, which must not be followed if they are symlinks.
+	if hdr.Typeflag != tar.TypeDir {
+		_ = os.Remove(path)
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(path, mode|0700); err != nil {
+			return err
+		}
+		return os.Chmod(path, mode|0700)
+	case tar.TypeReg:
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case tar.TypeSymlink:
+		return os.Symlink(hdr.Linkname, path)
+	case tar.TypeLink:
+		target, err := rootPath(root, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		return os.Link(target, path)
+	}
+
+	return nil
+}
+
+// rootPath returns the path in the host of a path inside the root, ensuring it does not escape it.
+func rootPath(root, name string) (string, error) {
+	clean := filepath.Clean(filepath.Join(string(filepath.Separator), filepath.FromSlash(name)))
+	if clean == string(filepath.Separator) {
+		return root, nil
+	}
+
+	path := filepath.Join(root, clean)
+	if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes the root", name)
+	}
+
+	return path, nil
+}
+
+// requireNoSymlinks fails if any existing directory between the root and the path is a symlink.
+func requireNoSymlinks(root, path string) error {
+	for p := path; len(p) > len(root); p = filepath.Dir(p) {
+		info, err := os.Lstat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", p)
+		}
+	}
+	return nil
+}
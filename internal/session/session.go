@@ -0,0 +1,5 @@
+// Package session holds what back-ends need to know about the sessions of GoWSL.
+package session
+
+// Shell is the command that GoWSL launches to host a Session.
+const Shell = "exec /bin/sh"
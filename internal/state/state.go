@@ -15,6 +15,7 @@ const (
 	Installing
 	Uninstalling
 	NotRegistered
+	Converting
 )
 
 // NewFromString parses the name of a state as printed in `wsl.exe -l -v`
@@ -29,6 +30,8 @@ func NewFromString(s string) (State, error) {
 		return Installing, nil
 	case "Uninstalling":
 		return Uninstalling, nil
+	case "Converting":
+		return Converting, nil
 	}
 
 	return Error, fmt.Errorf("could not parse state %q", s)
@@ -48,6 +51,8 @@ func (s State) String() string {
 		return "NotRegistered"
 	case Uninstalling:
 		return "Uninstalling"
+	case Converting:
+		return "Converting"
 	}
 
 	return fmt.Sprintf("Unknown state %d", s)
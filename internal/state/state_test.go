@@ -20,6 +20,7 @@ func TestStateFromString(t *testing.T) {
 		"Running":     {input: "Running", want: state.Running},
 		"Installing":  {input: "Installing", want: state.Installing},
 		"Unistalling": {input: "Uninstalling", want: state.Uninstalling},
+		"Converting":  {input: "Converting", want: state.Converting},
 
 		// Error cases
 		"Error with made-up state": {input: "Discombobulating", wantErr: true},
@@ -55,6 +56,7 @@ func TestString(t *testing.T) {
 		"Installing":    {input: state.Installing, want: "Installing"},
 		"Unistalling":   {input: state.Uninstalling, want: "Uninstalling"},
 		"NotRegistered": {input: state.NotRegistered, want: "NotRegistered"},
+		"Converting":    {input: state.Converting, want: "Converting"},
 
 		// Error case
 		"Error with made-up state": {input: 35, want: "Unknown state 35"},
@@ -0,0 +1,18 @@
+package stubprocess
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Dup duplicates a file, so that it can still be used after the original is closed.
+func Dup(f *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("could not duplicate %q: %v", f.Name(), err)
+	}
+	syscall.CloseOnExec(fd)
+
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
@@ -0,0 +1,23 @@
+package stubprocess
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Dup duplicates a file, so that it can still be used after the original is closed.
+func Dup(f *os.File) (*os.File, error) {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return nil, fmt.Errorf("could not get current process handle: %v", err)
+	}
+
+	var h syscall.Handle
+	err = syscall.DuplicateHandle(process, syscall.Handle(f.Fd()), process, &h, 0, false, syscall.DUPLICATE_SAME_ACCESS)
+	if err != nil {
+		return nil, fmt.Errorf("could not duplicate %q: %v", f.Name(), err)
+	}
+
+	return os.NewFile(uintptr(h), f.Name()), nil
+}
@@ -0,0 +1,12 @@
+package stubprocess
+
+import "os"
+
+// Tracked reports whether the exit code of the process is still held.
+func Tracked(p *os.Process) bool {
+	exitCodes.Lock()
+	defer exitCodes.Unlock()
+
+	_, ok := exitCodes.m[p]
+	return ok
+}
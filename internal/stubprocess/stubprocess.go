@@ -0,0 +1,151 @@
+// Package stubprocess runs Go functions as if they were processes, so that back-ends that do not
+// launch real processes can still return an *os.Process.
+package stubprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// exitCodes holds the exit codes of the stub processes until they are reaped.
+var exitCodes = struct {
+	sync.Mutex
+	m map[*os.Process]*exitCode
+}{m: make(map[*os.Process]*exitCode)}
+
+type exitCode struct {
+	code    uint32
+	set     bool // Whether f has returned
+	waiting bool // Whether Wait is reaping the process, in which case it forgets the code itself
+}
+
+// Start launches a stub process and runs f in a goroutine. The stub process does nothing but wait
+// for f to return, and then exits with the exit code returned by f.
+//
+// The context passed to f is cancelled if the stub process exits before f returns (for instance,
+// because it was killed), so that f can stop early.
+//
+// The stub process truncates the exit code to 8 bits on Linux; reap it with Wait to get it in full.
+func Start(f func(ctx context.Context) int) (p *os.Process, err error) {
+	executable, argv := "bash", []string{"bash", "-c", "read -r code; exit $code"}
+	if runtime.GOOS == "windows" {
+		executable, argv = "cmd.exe", []string{"cmd.exe", "/v:on", "/c", "set /p code=& exit !code!"}
+	}
+
+	exe, err := exec.LookPath(executable)
+	if err != nil {
+		return nil, fmt.Errorf("could not find executable %q: %v", executable, err)
+	}
+
+	// The stub process reads its exit code from this pipe.
+	codeR, codeW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("could not create pipe: %v", err)
+	}
+	defer codeR.Close()
+
+	// The stub process holds the only writer of this pipe, so the reader reaches
+	// EOF when it exits.
+	aliveR, aliveW, err := os.Pipe()
+	if err != nil {
+		codeW.Close()
+		return nil, fmt.Errorf("could not create pipe: %v", err)
+	}
+	defer aliveW.Close()
+
+	p, err = os.StartProcess(exe, argv, &os.ProcAttr{
+		Files: []*os.File{codeR, aliveW, nil},
+	})
+	if err != nil {
+		codeW.Close()
+		aliveR.Close()
+		return nil, fmt.Errorf("could not start stub process: %v", err)
+	}
+
+	entry := &exitCode{}
+	exitCodes.Lock()
+	forgetReaped()
+	exitCodes.m[p] = entry
+	exitCodes.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer aliveR.Close()
+		_, _ = io.Copy(io.Discard, aliveR)
+		cancel()
+	}()
+
+	go func() {
+		defer cancel()
+		defer codeW.Close()
+
+		code := f(ctx)
+
+		// The code is stored before the stub process can exit, so it is known once it has.
+		exitCodes.Lock()
+		entry.code = uint32(code) //nolint:gosec // Negative exit codes wrap around, as they do on Windows.
+		entry.set = true
+		exitCodes.Unlock()
+
+		// The stub process may be dead already, in which case the exit code is irrelevant.
+		_, _ = fmt.Fprintf(codeW, "%d\n", code)
+	}()
+
+	return p, nil
+}
+
+// Wait waits for the process to exit and releases its resources, as p.Wait does. For stub
+// processes that exited with the code of their function, it also returns that code in full.
+// Otherwise, for instance if the process was killed or if it is not a stub process, ok is false.
+func Wait(p *os.Process) (state *os.ProcessState, code uint32, ok bool, err error) {
+	exitCodes.Lock()
+	entry, found := exitCodes.m[p]
+	if found {
+		entry.waiting = true
+	}
+	exitCodes.Unlock()
+
+	state, err = p.Wait()
+
+	exitCodes.Lock()
+	delete(exitCodes.m, p)
+	var e exitCode
+	if found {
+		e = *entry
+	}
+	exitCodes.Unlock()
+
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	if !e.set || !state.Exited() {
+		return state, 0, false, nil
+	}
+
+	// The stub process may have been killed after f returned.
+	//nolint:gosec // Windows exit codes are DWORDs, so this conversion is lossless.
+	if uint32(state.ExitCode())&0xff != e.code&0xff {
+		return state, 0, false, nil
+	}
+
+	return state, e.code, true, nil
+}
+
+// forgetReaped forgets the exit codes of the stub processes that were reaped without Wait,
+// whose callers did not need them. Use with exitCodes locked.
+func forgetReaped() {
+	for p, e := range exitCodes.m {
+		if !e.waiting && errors.Is(p.Signal(syscall.Signal(0)), os.ErrProcessDone) {
+			delete(exitCodes.m, p)
+		}
+	}
+}
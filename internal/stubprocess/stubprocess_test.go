@@ -0,0 +1,105 @@
+package stubprocess_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+)
+
+func TestStart(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		exitCode int
+	}{
+		"Success exiting with zero":             {exitCode: 0},
+		"Success exiting with non-zero":         {exitCode: 42},
+		"Success exiting with a code above 255": {exitCode: 0x8007019e},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := stubprocess.Start(func(context.Context) int {
+				return tc.exitCode
+			})
+			require.NoError(t, err, "Start should have succeeded")
+
+			state, code, ok, err := stubprocess.Wait(p)
+			require.NoError(t, err, "Wait should have succeeded")
+			require.Equal(t, tc.exitCode&0xff, state.ExitCode()&0xff, "Unexpected exit code")
+			require.True(t, ok, "Wait should know the exit code of a stub process")
+			require.Equal(t, uint32(tc.exitCode), code, "Wait should report the exit code in full")
+			require.False(t, stubprocess.Tracked(p), "Wait should forget the exit code once reported")
+		})
+	}
+}
+
+func TestStartForgetsReapedProcesses(t *testing.T) {
+	t.Parallel()
+
+	p, err := stubprocess.Start(func(context.Context) int { return 0 })
+	require.NoError(t, err, "Setup: Start should have succeeded")
+
+	// Callers that do not need the full exit code reap the process themselves.
+	_, err = p.Wait()
+	require.NoError(t, err, "Setup: Wait should have succeeded")
+
+	other, err := stubprocess.Start(func(context.Context) int { return 0 })
+	require.NoError(t, err, "Start should have succeeded")
+	require.False(t, stubprocess.Tracked(p), "Start should forget the exit codes of the processes reaped without Wait")
+
+	_, _, _, err = stubprocess.Wait(other)
+	require.NoError(t, err, "Wait should have succeeded")
+}
+
+func TestStartKill(t *testing.T) {
+	t.Parallel()
+
+	cancelled := make(chan struct{})
+	p, err := stubprocess.Start(func(ctx context.Context) int {
+		<-ctx.Done()
+		close(cancelled)
+		return 0
+	})
+	require.NoError(t, err, "Start should have succeeded")
+
+	require.NoError(t, p.Kill(), "Kill should have succeeded")
+
+	select {
+	case <-cancelled:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "The context should have been cancelled after killing the process")
+	}
+
+	state, _, ok, err := stubprocess.Wait(p)
+	require.NoError(t, err, "Wait should have succeeded")
+	require.NotEqual(t, 0, state.ExitCode(), "The process should not have exited successfully")
+	require.False(t, ok, "Wait should not report the code of a killed process")
+}
+
+func TestDup(t *testing.T) {
+	t.Parallel()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	defer r.Close()
+
+	dup, err := stubprocess.Dup(w)
+	require.NoError(t, err, "Dup should have succeeded")
+	require.NoError(t, w.Close(), "Setup: could not close the original file")
+
+	_, err = dup.WriteString("Hello!")
+	require.NoError(t, err, "Writing into the duplicate should succeed after closing the original")
+	require.NoError(t, dup.Close(), "Closing the duplicate should have succeeded")
+
+	out := make([]byte, 16)
+	n, err := r.Read(out)
+	require.NoError(t, err, "Reading from the pipe should have succeeded")
+	require.Equal(t, "Hello!", string(out[:n]), "Unexpected contents read from the pipe")
+}
@@ -0,0 +1,191 @@
+// Package wslpath translates paths between Windows and WSL distros following the same rules as
+// the wslpath command, so that both GoWSL and its mock can use them.
+package wslpath
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// DefaultAutomountRoot is the directory where Windows drives are mounted when
+// /etc/wsl.conf does not specify otherwise.
+const DefaultAutomountRoot = "/mnt/"
+
+// invalidChars are the characters that are valid in Linux file names but not in Windows ones.
+// DrvFs and the \\wsl.localhost share map them into the Unicode private use area by adding
+// escapeOffset to them.
+const (
+	invalidChars = "\\:*?\"<>|"
+	escapeOffset = 0xf000
+)
+
+// ToLinux translates a Windows path into a path inside the distro, the same way `wslpath -u` does.
+//
+// Paths in Windows drives are translated into paths under automountRoot. UNC paths are only
+// supported if they point to the distro itself, via either the \\wsl.localhost or the \\wsl$
+// share. Relative paths are translated without resolving them.
+func ToLinux(distroName, automountRoot, windowsPath string) (string, error) {
+	root, err := normalizeRoot(automountRoot)
+	if err != nil {
+		return "", err
+	}
+
+	if windowsPath == "" {
+		return "", errors.New("empty path")
+	}
+
+	// Both kinds of slashes are valid separators on Windows.
+	p := strings.ReplaceAll(windowsPath, "/", `\`)
+
+	// Long path prefixes: \\?\C:\... and \\?\UNC\server\share\...
+	if rest, ok := strings.CutPrefix(p, `\\?\`); ok {
+		p = rest
+		if len(p) >= 4 && strings.EqualFold(p[:4], `UNC\`) {
+			p = `\\` + p[4:]
+		}
+	}
+
+	switch {
+	case len(p) >= 2 && isLetter(p[0]) && p[1] == ':':
+		if len(p) > 2 && p[2] != '\\' {
+			return "", fmt.Errorf("%q is relative to the current directory of drive %c", windowsPath, p[0])
+		}
+		return root + strings.ToLower(p[:1]) + toLinuxSeparators(p[2:]), nil
+
+	case strings.HasPrefix(p, `\\`):
+		host, rest, _ := strings.Cut(p[2:], `\`)
+		if !strings.EqualFold(host, "wsl.localhost") && !strings.EqualFold(host, "wsl$") {
+			return "", fmt.Errorf("%q is in a network share other than WSL's", windowsPath)
+		}
+
+		share, rest, _ := strings.Cut(rest, `\`)
+		if !strings.EqualFold(share, distroName) {
+			return "", fmt.Errorf("%q is not in distro %s", windowsPath, distroName)
+		}
+		return "/" + toLinuxSeparators(rest), nil
+
+	case strings.HasPrefix(p, `\`):
+		return "", fmt.Errorf("%q is relative to the current drive", windowsPath)
+	}
+
+	return toLinuxSeparators(p), nil
+}
+
+// ToWindows translates a path inside the distro into a Windows path, the same way `wslpath -w` does.
+//
+// Paths under automountRoot are translated into paths in Windows drives. Other absolute paths
+// are translated into paths in the \\wsl.localhost share. Relative paths are translated without
+// resolving them.
+func ToWindows(distroName, automountRoot, linuxPath string) (string, error) {
+	root, err := normalizeRoot(automountRoot)
+	if err != nil {
+		return "", err
+	}
+
+	if linuxPath == "" {
+		return "", errors.New("empty path")
+	}
+
+	if !path.IsAbs(linuxPath) {
+		return toWindowsSeparators(linuxPath), nil
+	}
+
+	p := path.Clean(linuxPath)
+	if rel, ok := strings.CutPrefix(p, root); ok {
+		drive, rest, _ := strings.Cut(rel, "/")
+		if len(drive) == 1 && isLetter(drive[0]) {
+			return strings.ToUpper(drive) + `:\` + toWindowsSeparators(rest), nil
+		}
+	}
+
+	return `\\wsl.localhost\` + distroName + `\` + toWindowsSeparators(strings.TrimPrefix(p, "/")), nil
+}
+
+// ParseAutomountRoot returns the automount root configured in the contents of /etc/wsl.conf.
+func ParseAutomountRoot(r io.Reader) (string, error) {
+	root := DefaultAutomountRoot
+
+	var section string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "automount" || strings.ToLower(strings.TrimSpace(key)) != "root" {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		root = value
+	}
+
+	if err := sc.Err(); err != nil {
+		return "", fmt.Errorf("could not parse wsl.conf: %v", err)
+	}
+
+	return normalizeRoot(root)
+}
+
+// normalizeRoot ensures that the automount root is absolute and ends with a slash.
+func normalizeRoot(root string) (string, error) {
+	if root == "" {
+		return DefaultAutomountRoot, nil
+	}
+
+	if !path.IsAbs(root) {
+		return "", fmt.Errorf("automount root %q is not an absolute path", root)
+	}
+
+	root = path.Clean(root)
+	if root == "/" {
+		return root, nil
+	}
+	return root + "/", nil
+}
+
+// toLinuxSeparators converts the separators of a Windows path and unescapes characters that
+// are invalid on Windows.
+func toLinuxSeparators(p string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\\' {
+			return '/'
+		}
+		if r >= escapeOffset && r < escapeOffset+0x80 && strings.ContainsRune(invalidChars, r-escapeOffset) {
+			return r - escapeOffset
+		}
+		return r
+	}, p)
+}
+
+// toWindowsSeparators converts the separators of a Linux path and escapes characters that
+// are invalid on Windows.
+func toWindowsSeparators(p string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' {
+			return '\\'
+		}
+		if strings.ContainsRune(invalidChars, r) {
+			return r + escapeOffset
+		}
+		return r
+	}, p)
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
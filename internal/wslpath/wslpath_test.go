@@ -0,0 +1,145 @@
+package wslpath_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/internal/wslpath"
+)
+
+func TestToLinux(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		path          string
+		automountRoot string
+
+		want    string
+		wantErr bool
+	}{
+		"Drive path":                           {path: `C:\Users\me\file.txt`, want: "/mnt/c/Users/me/file.txt"},
+		"Drive path with forward slashes":      {path: `C:/Users/me/file.txt`, want: "/mnt/c/Users/me/file.txt"},
+		"Drive path with a trailing separator": {path: `D:\Users\`, want: "/mnt/d/Users/"},
+		"Drive root":                           {path: `C:\`, want: "/mnt/c/"},
+		"Drive without root":                   {path: `C:`, want: "/mnt/c"},
+		"Drive path with escaped characters":   {path: "C:\\a\uf03ab\uf03fc", want: "/mnt/c/a:b?c"},
+		"Drive path with a custom root":        {path: `C:\Users`, automountRoot: "/windir", want: "/windir/c/Users"},
+		"Drive path with the root at /":        {path: `C:\Users`, automountRoot: "/", want: "/c/Users"},
+		"Long drive path":                      {path: `\\?\C:\Users`, want: "/mnt/c/Users"},
+		"Relative path":                        {path: `Users\me`, want: "Users/me"},
+		"UNC path":                             {path: `\\wsl.localhost\Ubuntu\home\me`, want: "/home/me"},
+		"UNC path with a different casing":     {path: `\\WSL.LOCALHOST\ubuntu\home\me`, want: "/home/me"},
+		"UNC path to the distro root":          {path: `\\wsl.localhost\Ubuntu\`, want: "/"},
+		"Legacy UNC path":                      {path: `\\wsl$\Ubuntu\home\me`, want: "/home/me"},
+		"Long UNC path":                        {path: `\\?\UNC\wsl.localhost\Ubuntu\home`, want: "/home"},
+		"UNC path with escaped characters":     {path: "\\\\wsl$\\Ubuntu\\a\uf05cb", want: "/a\\b"},
+
+		"Error with an empty path":                  {path: "", wantErr: true},
+		"Error with a drive-relative path":          {path: `C:Users`, wantErr: true},
+		"Error with a path relative to the drive":   {path: `\Users`, wantErr: true},
+		"Error with a UNC path to another share":    {path: `\\server\share\file`, wantErr: true},
+		"Error with a UNC path to another distro":   {path: `\\wsl.localhost\Debian\home`, wantErr: true},
+		"Error with a relative automount root":      {path: `C:\`, automountRoot: "mnt", wantErr: true},
+		"Error with a long UNC path to other share": {path: `\\?\UNC\server\share`, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wslpath.ToLinux("Ubuntu", tc.automountRoot, tc.path)
+			if tc.wantErr {
+				require.Error(t, err, "ToLinux should have failed")
+				return
+			}
+			require.NoError(t, err, "ToLinux should have succeeded")
+			require.Equal(t, tc.want, got, "Unexpected path returned by ToLinux")
+		})
+	}
+}
+
+func TestToWindows(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		path          string
+		automountRoot string
+
+		want    string
+		wantErr bool
+	}{
+		"Drive path":                          {path: "/mnt/c/Users/me/file.txt", want: `C:\Users\me\file.txt`},
+		"Drive path with a trailing slash":    {path: "/mnt/d/Users/", want: `D:\Users`},
+		"Drive path that is not clean":        {path: "/mnt/c/Users/../Windows", want: `C:\Windows`},
+		"Drive root":                          {path: "/mnt/c", want: `C:\`},
+		"Drive path with escaped characters":  {path: "/mnt/c/a:b?c", want: "C:\\a\uf03ab\uf03fc"},
+		"Drive path with a custom root":       {path: "/windir/c/Users", automountRoot: "/windir/", want: `C:\Users`},
+		"Drive path with the root at /":       {path: "/c/Users", automountRoot: "/", want: `C:\Users`},
+		"Path in the distro":                  {path: "/home/me", want: `\\wsl.localhost\Ubuntu\home\me`},
+		"Path in the distro with escaping":    {path: `/home/a\b`, want: "\\\\wsl.localhost\\Ubuntu\\home\\a\uf05cb"},
+		"Root of the distro":                  {path: "/", want: `\\wsl.localhost\Ubuntu\`},
+		"Automount root":                      {path: "/mnt", want: `\\wsl.localhost\Ubuntu\mnt`},
+		"Directory in the automount root":     {path: "/mnt/wsl", want: `\\wsl.localhost\Ubuntu\mnt\wsl`},
+		"Drive path with another root":        {path: "/mnt/c/Users", automountRoot: "/windir/", want: `\\wsl.localhost\Ubuntu\mnt\c\Users`},
+		"Relative path":                       {path: "home/me", want: `home\me`},
+		"Relative path with escaped chars":    {path: "a|b", want: "a\uf07cb"},
+		"Relative path that starts with dots": {path: "../me", want: `..\me`},
+
+		"Error with an empty path":             {path: "", wantErr: true},
+		"Error with a relative automount root": {path: "/mnt/c", automountRoot: "mnt", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wslpath.ToWindows("Ubuntu", tc.automountRoot, tc.path)
+			if tc.wantErr {
+				require.Error(t, err, "ToWindows should have failed")
+				return
+			}
+			require.NoError(t, err, "ToWindows should have succeeded")
+			require.Equal(t, tc.want, got, "Unexpected path returned by ToWindows")
+		})
+	}
+}
+
+func TestParseAutomountRoot(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		contents string
+
+		want    string
+		wantErr bool
+	}{
+		"Empty file":                  {contents: "", want: "/mnt/"},
+		"No automount section":        {contents: "[user]\ndefault=me\n", want: "/mnt/"},
+		"Root in another section":     {contents: "[user]\nroot=/windir/\n", want: "/mnt/"},
+		"Root in automount section":   {contents: "[automount]\nroot = /windir/\n", want: "/windir/"},
+		"Root without trailing slash": {contents: "[automount]\nroot=/windir\n", want: "/windir/"},
+		"Root with quotes":            {contents: "[automount]\nroot=\"/windir/\"\n", want: "/windir/"},
+		"Root at /":                   {contents: "[automount]\nroot=/\n", want: "/"},
+		"Root with different casing":  {contents: "[AutoMount]\nRoot=/windir/\n", want: "/windir/"},
+		"Root after other settings":   {contents: "[boot]\nsystemd=true\n\n[automount]\nenabled=true\n# root=/commented/\nroot=/windir/\n", want: "/windir/"},
+		"Root with carriage returns":  {contents: "[automount]\r\nroot=/windir/\r\n", want: "/windir/"},
+
+		"Error with a relative root":         {contents: "[automount]\nroot=windir\n", wantErr: true},
+		"Error with a line that is too long": {contents: "[automount]\nroot=/" + strings.Repeat("a", 1<<17) + "\n", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := wslpath.ParseAutomountRoot(strings.NewReader(tc.contents))
+			if tc.wantErr {
+				require.Error(t, err, "ParseAutomountRoot should have failed")
+				return
+			}
+			require.NoError(t, err, "ParseAutomountRoot should have succeeded")
+			require.Equal(t, tc.want, got, "Unexpected automount root")
+		})
+	}
+}
@@ -4,38 +4,124 @@ package mock
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/mock/internal/clock"
 )
 
 // Backend implements the Backend interface.
 type Backend struct {
 	lxssRootKey *RegistryKey // Registry mock
 
-	// Error injectors. These all have the form of:
-	//
-	// NameOfTheFunctionError
-	//
-	// Their effect is to make the relevant function return an error of type mock.Error
-	// instantly upon being called.
-	WslConfigureDistributionError        bool
-	WslGetDistributionConfigurationError bool
-	WslLaunchError                       bool
-	WslLaunchInteractiveError            bool
-	WslRegisterDistributionError         bool
-	WslUnregisterDistributionError       bool
-	OpenLxssKeyError                     bool
-	ShutdownError                        bool
-	TerminateError                       bool
-	SetAsDefaultError                    bool
-	StateError                           bool
-	InstallError                         bool
-	RemoveAppxFamilyError                bool
+	clock       Clock         // Clock used to time the distros and the calls
+	idleTimeout time.Duration // Time after which idle distros are terminated
+	durations   Durations     // Time that operations take
+	sandbox     string        // Directory with the root filesystems of the distros, if sandboxed
+
+	// Calls made to the back-end, in order.
+	calls   []*Call
+	callsMu sync.Mutex
+
+	// Handlers for the commands launched inside distros, registered via Handle.
+	handlers   []commandHandler
+	handlersMu sync.RWMutex
+
+	// Faults injected via InjectFault, by method.
+	faults   map[string]*injectedFault
+	faultsMu sync.Mutex
+}
+
+// DefaultIdleTimeout is the time after which WSL terminates a distro that has
+// no shells left.
+const DefaultIdleTimeout = 8 * time.Second
+
+// Option is an optional parameter for New. Use any of the provided
+// functions such as WithIdleTimeout().
+type Option func(*options)
+
+type options struct {
+	idleTimeout time.Duration
+	clock       Clock
+	durations   Durations
+	sandbox     string
+}
+
+// WithIdleTimeout is an optional parameter for New that sets the time after which
+// distros with no shells left are terminated. It defaults to DefaultIdleTimeout.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = timeout
+	}
+}
+
+// WithClock is an optional parameter for New that sets the clock used to time the
+// distros and the recorded calls. Use a FakeClock to control the passing of time in
+// tests. It defaults to the system's clock.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// Durations are the times that the mocked operations take, as measured by the back-end's
+// clock. While an operation lasts, the distro involved is reported in a transitional state,
+// and cannot be started. Zero durations make the operations instantaneous.
+type Durations struct {
+	Register   time.Duration // WslRegisterDistribution, reported as Installing
+	Import     time.Duration // Import, reported as Installing
+	Install    time.Duration // Install
+	Unregister time.Duration // WslUnregisterDistribution, reported as Uninstalling
+	Convert    time.Duration // SetVersion, reported as Converting
+}
+
+// WithDurations is an optional parameter for New that sets how long operations take.
+// By default, they are instantaneous.
+func WithDurations(d Durations) Option {
+	return func(o *options) {
+		o.durations = d
+	}
+}
+
+// Clock tells the time and schedules functions to be run in the future.
+type Clock = clock.Clock
+
+// Timer is a function scheduled by a Clock.
+type Timer = clock.Timer
+
+// FakeClock is a Clock whose time only passes when it is advanced. Timers that
+// expire are fired synchronously by Advance, so tests are deterministic:
+//
+//	c := mock.NewFakeClock(time.Now())
+//	b := mock.New(mock.WithClock(c))
+//	// ... launch a command so the distro starts running ...
+//	c.Advance(mock.DefaultIdleTimeout)
+//	// ... the distro is stopped ...
+type FakeClock = clock.Fake
+
+// NewFakeClock returns a fake clock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return clock.NewFake(now)
 }
 
 // New constructs a new mocked back-end for WSL.
-func New() *Backend {
+func New(opts ...Option) *Backend {
+	o := options{
+		idleTimeout: DefaultIdleTimeout,
+		clock:       clock.Real(),
+	}
+	for _, f := range opts {
+		f(&o)
+	}
+
 	return &Backend{
+		clock:       o.clock,
+		idleTimeout: o.idleTimeout,
+		durations:   o.durations,
+		sandbox:     o.sandbox,
 		lxssRootKey: &RegistryKey{
 			path: lxssPath,
 			children: map[string]*RegistryKey{
@@ -50,21 +136,6 @@ func New() *Backend {
 	}
 }
 
-// ResetErrors sets all the error flags to false.
-func (b *Backend) ResetErrors() {
-	b.WslConfigureDistributionError = false
-	b.WslGetDistributionConfigurationError = false
-	b.WslLaunchError = false
-	b.WslLaunchInteractiveError = false
-	b.WslRegisterDistributionError = false
-	b.WslUnregisterDistributionError = false
-	b.OpenLxssKeyError = false
-	b.ShutdownError = false
-	b.TerminateError = false
-	b.SetAsDefaultError = false
-	b.StateError = false
-}
-
 // Error is an error triggered by the mock, and not a real problem.
 type Error struct{}
 
@@ -73,12 +144,14 @@ func (err Error) Error() string {
 }
 
 // ErrNotExist is returned when a distro does not exist.
-var ErrNotExist = errors.New("distro does not exist")
+var ErrNotExist = backend.ErrNotExist
 
 // RemoveAppxFamily mocks the removal of packages under a package family.
-func (b Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string) error {
-	if b.RemoveAppxFamilyError {
-		return Error{}
+func (b *Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string) (err error) {
+	defer b.record("RemoveAppxFamily", packageFamilyName)(&err)
+
+	if _, err := b.fault(ctx, "RemoveAppxFamily"); err != nil {
+		return err
 	}
 
 	select {
@@ -89,3 +162,43 @@ func (b Backend) RemoveAppxFamily(ctx context.Context, packageFamilyName string)
 
 	return nil
 }
+
+// abandonable runs f, which stands for a Win32 call that cannot be cancelled. If the context is
+// done first, it stops waiting for f, which goes on in the background, and returns an error
+// wrapping backend.ErrAbandoned.
+func abandonable(ctx context.Context, f func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", backend.ErrAbandoned, context.Cause(ctx))
+	}
+}
+
+// sleep waits for the duration to pass on the back-end's clock, or for the context to be cancelled.
+func (b *Backend) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	done := make(chan struct{})
+	t := b.clock.AfterFunc(d, func() { close(done) })
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
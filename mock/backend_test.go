@@ -0,0 +1,324 @@
+package mock_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/backend"
+	"github.com/ubuntu/gowsl/internal/flags"
+	"github.com/ubuntu/gowsl/internal/state"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		idleTimeout time.Duration
+
+		wantTimeout time.Duration
+	}{
+		"Success with the default idle timeout": {wantTimeout: mock.DefaultIdleTimeout},
+		"Success with a custom idle timeout":    {idleTimeout: time.Hour, wantTimeout: time.Hour},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := mock.NewFakeClock(time.Now())
+			opts := []mock.Option{mock.WithClock(c)}
+			if tc.idleTimeout != 0 {
+				opts = append(opts, mock.WithIdleTimeout(tc.idleTimeout))
+			}
+
+			b := mock.New(opts...)
+			err := b.WslRegisterDistribution(context.Background(), testDistro, "rootfs.tar.gz")
+			require.NoError(t, err, "Setup: could not register distro")
+
+			requireState(t, b, state.Stopped, "The distro should be stopped after registering it")
+
+			// The shell keeps the distro running for as long as it runs.
+			release := make(chan struct{})
+			b.Handle(mock.Exact("wait"), func(context.Context, io.Reader, io.Writer, io.Writer) int {
+				<-release
+				return 0
+			})
+
+			done := make(chan error)
+			go func() {
+				_, err := b.WslLaunchInteractive(context.Background(), testDistro, "wait", false)
+				done <- err
+			}()
+
+			require.Eventually(t, func() bool {
+				s, err := b.State(context.Background(), testDistro)
+				return err == nil && s == state.Running
+			}, 10*time.Second, 10*time.Millisecond, "The distro should be running while the shell runs")
+
+			c.Advance(10 * tc.wantTimeout)
+			requireState(t, b, state.Running, "The distro should not time out while the shell runs")
+
+			close(release)
+			require.NoError(t, <-done, "WslLaunchInteractive should have succeeded")
+
+			// The idle timer starts once the last shell exits.
+			c.Advance(tc.wantTimeout - time.Nanosecond)
+			requireState(t, b, state.Running, "The distro should still be running before the idle timeout")
+
+			c.Advance(time.Nanosecond)
+			requireState(t, b, state.Stopped, "The distro should be stopped after the idle timeout")
+		})
+	}
+}
+
+func TestFakeClockTimesCalls(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := mock.New(mock.WithClock(mock.NewFakeClock(start)))
+
+	_, err := b.State(context.Background(), testDistro)
+	require.NoError(t, err, "Setup: State should have succeeded")
+
+	calls := b.Calls("State")
+	require.Len(t, calls, 1, "Unexpected number of calls")
+	require.Equal(t, start, calls[0].Time, "Calls should be timed by the back-end's clock")
+	require.Zero(t, calls[0].Duration, "No time should pass unless the fake clock is advanced")
+}
+
+// requireState checks that the test distro is in the expected state.
+func requireState(t *testing.T, b *mock.Backend, want state.State, msg string) {
+	t.Helper()
+
+	got, err := b.State(context.Background(), testDistro)
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, want, got, msg)
+}
+
+func TestDurations(t *testing.T) {
+	t.Parallel()
+
+	const duration = time.Minute
+
+	testCases := map[string]struct {
+		durations  mock.Durations
+		registered bool
+		operation  func(context.Context, *mock.Backend) error
+
+		wantTransition state.State
+		wantFinal      state.State
+	}{
+		"Success registering a distro": {
+			durations: mock.Durations{Register: duration},
+			operation: func(ctx context.Context, b *mock.Backend) error {
+				return b.WslRegisterDistribution(ctx, testDistro, "rootfs.tar.gz")
+			},
+			wantTransition: state.Installing, wantFinal: state.Stopped,
+		},
+		"Success importing a distro": {
+			durations:      mock.Durations{Import: duration},
+			operation:      func(ctx context.Context, b *mock.Backend) error { return b.Import(ctx, testDistro, rootfs(t), "") },
+			wantTransition: state.Installing, wantFinal: state.Stopped,
+		},
+		"Success unregistering a distro": {
+			durations: mock.Durations{Unregister: duration}, registered: true,
+			operation: func(ctx context.Context, b *mock.Backend) error {
+				return b.WslUnregisterDistribution(ctx, testDistro)
+			},
+			wantTransition: state.Uninstalling, wantFinal: state.NotRegistered,
+		},
+		"Success converting a distro": {
+			durations: mock.Durations{Convert: duration}, registered: true,
+			operation:      func(ctx context.Context, b *mock.Backend) error { return b.SetVersion(ctx, testDistro, 1) },
+			wantTransition: state.Converting, wantFinal: state.Stopped,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := mock.NewFakeClock(time.Now())
+			b := mock.New(mock.WithClock(c), mock.WithDurations(tc.durations))
+
+			if tc.registered {
+				require.NoError(t, b.WslRegisterDistribution(context.Background(), testDistro, "rootfs.tar.gz"), "Setup: could not register distro")
+				_, err := b.WslLaunchInteractive(context.Background(), testDistro, "exit 0", false)
+				require.NoError(t, err, "Setup: could not start distro")
+				requireState(t, b, state.Running, "Setup: distro should be running")
+			}
+
+			done := make(chan error)
+			go func() { done <- tc.operation(context.Background(), b) }()
+
+			waitForTransition(t, b, c, tc.wantTransition)
+
+			_, err := b.WslLaunchInteractive(context.Background(), testDistro, "exit 0", false)
+			require.Error(t, err, "Distros in a transitional state should not start")
+
+			c.Advance(duration - time.Nanosecond)
+			requireState(t, b, tc.wantTransition, "The distro should be in a transitional state until the operation ends")
+
+			c.Advance(time.Nanosecond)
+			require.NoError(t, <-done, "The operation should have succeeded")
+			requireState(t, b, tc.wantFinal, "Unexpected state after the operation")
+		})
+	}
+}
+
+func TestUnregisterWhileRegistering(t *testing.T) {
+	t.Parallel()
+
+	c := mock.NewFakeClock(time.Now())
+	b := mock.New(mock.WithClock(c), mock.WithDurations(mock.Durations{Register: time.Minute}))
+
+	done := make(chan error)
+	go func() { done <- b.WslRegisterDistribution(context.Background(), testDistro, "rootfs.tar.gz") }()
+
+	waitForTransition(t, b, c, state.Installing)
+
+	err := b.WslUnregisterDistribution(context.Background(), testDistro)
+	require.Error(t, err, "Distros should not be unregistered while they are being installed")
+
+	c.Advance(time.Minute)
+	require.NoError(t, <-done, "The registration should have succeeded")
+	requireState(t, b, state.Stopped, "The distro should have been registered")
+
+	require.NoError(t, b.WslUnregisterDistribution(context.Background(), testDistro), "Unregistering should succeed once the registration is done")
+	requireState(t, b, state.NotRegistered, "The distro should have been unregistered")
+}
+
+func TestDurationsCancel(t *testing.T) {
+	t.Parallel()
+
+	c := mock.NewFakeClock(time.Now())
+	b := mock.New(mock.WithClock(c), mock.WithDurations(mock.Durations{Import: time.Minute, Install: time.Minute}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.Import(ctx, testDistro, rootfs(t), "") }()
+
+	waitForTransition(t, b, c, state.Installing)
+
+	cancel()
+	require.Error(t, <-done, "Import should have been cancelled")
+	requireState(t, b, state.NotRegistered, "Cancelling an import should remove the distro")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { done <- b.Install(ctx, "Ubuntu") }()
+
+	require.Eventually(t, func() bool { return c.Timers() == 1 }, 10*time.Second, time.Millisecond, "Setup: Install should have started")
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled, "Install should have been cancelled")
+
+	// Win32 calls cannot be cancelled: they are abandoned instead.
+	b = mock.New(mock.WithClock(c), mock.WithDurations(mock.Durations{Register: time.Minute}))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { done <- b.WslRegisterDistribution(ctx, testDistro, "rootfs.tar.gz") }()
+
+	waitForTransition(t, b, c, state.Installing)
+
+	cancel()
+	err := <-done
+	require.ErrorIs(t, err, backend.ErrAbandoned, "WslRegisterDistribution should have been abandoned")
+	require.ErrorIs(t, err, context.Canceled, "WslRegisterDistribution should report why it was abandoned")
+	requireState(t, b, state.NotRegistered, "Abandoning a registration should remove the distro")
+
+	b = mock.New(mock.WithClock(c), mock.WithDurations(mock.Durations{Unregister: time.Minute}))
+	require.NoError(t, b.WslRegisterDistribution(context.Background(), testDistro, "rootfs.tar.gz"), "Setup: could not register distro")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { done <- b.WslUnregisterDistribution(ctx, testDistro) }()
+
+	waitForTransition(t, b, c, state.Uninstalling)
+
+	cancel()
+	require.ErrorIs(t, <-done, backend.ErrAbandoned, "WslUnregisterDistribution should have been abandoned")
+	requireState(t, b, state.Uninstalling, "Abandoned unregistrations should go on in the background")
+
+	c.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		s, err := b.State(context.Background(), testDistro)
+		return err == nil && s == state.NotRegistered
+	}, 10*time.Second, time.Millisecond, "Abandoned unregistrations should complete in the background")
+}
+
+func TestUnregisterDefaultDistro(t *testing.T) {
+	t.Parallel()
+
+	b := mock.New()
+	for _, name := range []string{"First", "Second", "Third"} {
+		require.NoError(t, b.WslRegisterDistribution(context.Background(), name, "rootfs.tar.gz"), "Setup: could not register distro")
+	}
+	require.Equal(t, "First", b.Snapshot().DefaultDistro, "Setup: the first distro registered should be the default one")
+
+	require.NoError(t, b.WslUnregisterDistribution(context.Background(), "First"), "WslUnregisterDistribution should have succeeded")
+
+	// The distro with the lowest GUID becomes the default one.
+	f := b.Snapshot()
+	require.Len(t, f.Distros, 2, "Setup: two distros should remain")
+	want := f.Distros[0]
+	if f.Distros[1].GUID < want.GUID {
+		want = f.Distros[1]
+	}
+	require.Equal(t, want.Name, f.DefaultDistro, "The remaining distro with the lowest GUID should have become the default one")
+
+	require.NoError(t, b.WslUnregisterDistribution(context.Background(), "Second"), "WslUnregisterDistribution should have succeeded")
+	require.NoError(t, b.WslUnregisterDistribution(context.Background(), "Third"), "WslUnregisterDistribution should have succeeded")
+	require.Empty(t, b.Snapshot().DefaultDistro, "There should be no default distro once all of them are unregistered")
+}
+
+func TestSetVersion(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+	require.NoError(t, b.WslConfigureDistribution(context.Background(), testDistro, 0, flags.WslFlags(0xd)), "Setup: could not configure distro")
+
+	for _, version := range []uint8{1, 2} {
+		require.NoError(t, b.SetVersion(context.Background(), testDistro, version), "SetVersion should have succeeded")
+		require.Error(t, b.SetVersion(context.Background(), testDistro, version), "SetVersion should fail if the distro is at the requested version already")
+
+		var fsVersion uint8
+		var uid uint32
+		var wslFlags flags.WslFlags
+		var env map[string]string
+		err := b.WslGetDistributionConfiguration(context.Background(), testDistro, &fsVersion, &uid, &wslFlags, &env)
+		require.NoError(t, err, "WslGetDistributionConfiguration should have succeeded")
+		require.Equal(t, uint8(2), fsVersion, "The version of the filesystem should not change")
+
+		// WSL reports the version of a distro in its flags.
+		got := flags.Unpack(wslFlags)
+		require.Equal(t, version, got.UndocumentedWSLVersion, "The flags should report the new WSL version")
+		require.True(t, got.InteropEnabled, "The other flags should have been kept")
+		require.False(t, got.PathAppended, "The other flags should have been kept")
+		require.True(t, got.DriveMountingEnabled, "The other flags should have been kept")
+	}
+}
+
+// waitForTransition waits until the distro is in the transitional state, and the operation
+// under test is waiting on the fake clock.
+func waitForTransition(t *testing.T, b *mock.Backend, c *mock.FakeClock, want state.State) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		s, err := b.State(context.Background(), testDistro)
+		return err == nil && s == want && c.Timers() == 1
+	}, 10*time.Second, time.Millisecond, "Setup: the operation should have started")
+}
+
+// rootfs creates a dummy rootfs tarball for Import.
+func rootfs(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("rootfs"), 0600), "Setup: could not write rootfs")
+
+	return path
+}
@@ -1,14 +1,25 @@
 package mock
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
 	"runtime"
+	"strings"
+
+	"github.com/ubuntu/gowsl/internal/wslpath"
 )
 
-// mockedCommand is in charge of creating processes that behave the same way
-// than the ones used in tests.
+// hostCommand returns a handler that runs a command in the host that behaves the same
+// way as the one used in tests:
+//
+//	windows: cmd.exe /c <windows>
+//	linux:   bash -c <linux>
+//
+// Empty commands are replaced with the command being handled. If the back-end is
+// sandboxed, the command runs inside the distro's root directory.
 //
 // A few notes about Windows:
 //
@@ -24,77 +35,206 @@ import (
 //
 //   - In cmd.exe, "echo Hello >&2" will print "Hello " to stderr. Instead, you have
 //     to do "(ECHO Hello) >&2" to avoid the trailing space.
-type mockedCommand struct {
-	linux, windows string
-}
+func hostCommand(linux, windows string) Handler {
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) int {
+		executable, flag, command := "bash", "-c", linux
+		if runtime.GOOS == "windows" {
+			executable, flag, command = "cmd.exe", "/c", windows
+		}
 
-func newMockedCommand(cmd string) mockedCommand {
-	m, ok := translateCommand[cmd]
-	if !ok {
-		panic(fmt.Sprintf("WslLaunch command not supported: %s", cmd))
-	}
+		c := CommandFromContext(ctx)
+		if command == "" {
+			command = c.Line
+		}
+
+		//nolint:gosec // Running arbitrary commands is the whole point of the mock.
+		cmd := exec.CommandContext(ctx, executable, flag, command)
+		if c.Root != "" {
+			cmd = sandboxCommand(ctx, c.Root, command)
+		}
+		cmd.Stdin = stdin
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
 
-	if m.linux == "" {
-		m.linux = cmd
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		} else if err != nil {
+			fmt.Fprintf(stderr, "mock: could not run command: %v\n", err)
+			return 1
+		}
+
+		return 0
 	}
-	if m.windows == "" {
-		m.windows = cmd
+}
+
+// sandboxedCommand returns a handler that runs the command being handled inside the distro's
+// root directory. It fails unless the back-end is sandboxed, as the paths in the command would
+// otherwise refer to the host's files.
+func sandboxedCommand() Handler {
+	run := hostCommand("", "")
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) int {
+		if c := CommandFromContext(ctx); c.Root == "" {
+			fmt.Fprintf(stderr, "mock: could not run %q: it accesses the distro's files, which requires a back-end created WithSandbox\n", c.Line)
+			return 1
+		}
+		return run(ctx, stdin, stdout, stderr)
 	}
+}
 
-	return m
+// exitCode returns a handler that does nothing but exit with the given code.
+func exitCode(code int) Handler {
+	return func(context.Context, io.Reader, io.Writer, io.Writer) int {
+		return code
+	}
 }
 
-var translateCommand = map[string]mockedCommand{
+// defaultHandlers are the handlers that GoWSL's own tests rely on.
+var defaultHandlers = []commandHandler{
 	// Exit x
-	"":        {linux: "exit 0", windows: "EXIT 0"},
-	"exit 0":  {},
-	"exit 42": {},
+	{Exact(""), hostCommand("exit 0", "EXIT 0")},
+	{Exact("exit 0"), exitCode(0)},
+	{Exact("exit 42"), exitCode(42)},
 
 	// Sleep x
-	"sleep 5":        {windows: "PING localhost -n 6 >NUL"},
-	"sleep 10":       {windows: "PING localhost -n 11 >NUL"},
-	"sleep infinity": {windows: "PING localhost -n -1 >NUL"},
+	{Exact("sleep 5"), hostCommand("", "PING localhost -n 6 >NUL")},
+	{Exact("sleep 10"), hostCommand("", "PING localhost -n 11 >NUL")},
+	{Exact("sleep infinity"), hostCommand("", "PING localhost -n -1 >NUL")},
 
 	// Echo
-	"echo 'Hello!'":     {windows: "(ECHO Hello!)"},
-	"echo 'Error!' >&2": {windows: "(ECHO Error!) >&2"},
+	{Exact("echo 'Hello!'"), hostCommand("", "(ECHO Hello!)")},
+	{Exact("echo 'Error!' >&2"), hostCommand("", "(ECHO Error!) >&2")},
 
 	// Combinations
-	"echo 'Error!' >&2 && exit 42":                             {windows: "(ECHO Error!) >&2 && EXIT 42"},
-	"echo 'Hello!' && sleep 1 && echo 'Error!' >&2":            {windows: "(ECHO Hello!) && (PING localhost -n 2) >NUL && (ECHO Error!) >&2"},
-	"echo 'Hello!' && sleep 1 && echo 'Error!' >&2 && exit 42": {windows: "(ECHO Hello!) && (PING localhost -n 2) >NUL && (ECHO Error!) >&2 && EXIT 42"},
+	{Exact("echo 'Error!' >&2 && exit 42"), hostCommand("", "(ECHO Error!) >&2 && EXIT 42")},
+	{Exact("echo 'Hello!' && sleep 1 && echo 'Error!' >&2"), hostCommand("", "(ECHO Hello!) && (PING localhost -n 2) >NUL && (ECHO Error!) >&2")},
+	{Exact("echo 'Hello!' && sleep 1 && echo 'Error!' >&2 && exit 42"), hostCommand("", "(ECHO Hello!) && (PING localhost -n 2) >NUL && (ECHO Error!) >&2 && EXIT 42")},
+
+	// Working directory: we are either wherever wsl.exe was called from, or home (hence /root).
+	{Exact("[ `pwd` = /root ]"), useCWD(1, 0)},
+	{Exact("[ `pwd` != /root ]"), useCWD(0, 1)},
 
 	// Other
-	"useradd testuser": {linux: "exit 0", windows: "EXIT 0"},
-	"hostname":         {linux: "hostname", windows: "hostname"},
+	{Exact("useradd testuser"), exitCode(0)},
+	{Exact("hostname"), hostCommand("", "")},
+
+	// Session shell: the commands it receives via stdin are run in the host's shell.
+	// Not supported on Windows, as there is no POSIX shell to run them.
+	{Exact("exec /bin/sh"), hostCommand("", "")},
+
+	// Copying files to and from the distro. Windows ships bsdtar, which understands the same flags.
+	{Regexp(`^tar `), sandboxedCommand()},
+
+	// Writing files via (*Distro).FS.
+	{Regexp(`^cat > `), sandboxedCommand()},
+
+	// Translating paths, assuming the default automount root.
+	{isWSLPath, wslpathCommand},
 }
 
-// newCommandProcess starts a process of type:
-//
-//	windows: powershell.exe -Command <c.windows>
-//	linux:   bash -c <c.linux>
-//
-// If cmd is empty, an interactive shell is started instead.
-func (c mockedCommand) start(stdin, stdout, stderr *os.File) (*os.Process, error) {
-	executable := "bash"
-	argv := []string{executable, "-c", c.linux}
-	if runtime.GOOS == "windows" {
-		executable = "cmd.exe"
-		argv = []string{executable, "/c", c.windows}
+// useCWD returns a handler that exits with one code or the other depending on
+// whether the command was launched in the current working directory.
+func useCWD(inCWD, notInCWD int) Handler {
+	return func(ctx context.Context, _ io.Reader, _, _ io.Writer) int {
+		if CommandFromContext(ctx).UseCWD {
+			return inCWD
+		}
+		return notInCWD
+	}
+}
+
+func isWSLPath(command string) bool {
+	argv, ok := splitArgv(command)
+	return ok && len(argv) == 3 && argv[0] == "wslpath"
+}
+
+// wslpathCommand mocks `wslpath <-u|-w|-m> <path>`.
+func wslpathCommand(ctx context.Context, _ io.Reader, stdout, stderr io.Writer) int {
+	c := CommandFromContext(ctx)
+	flag, path := c.Argv[1], c.Argv[2]
+
+	var out string
+	var err error
+	switch flag {
+	case "-u":
+		out, err = wslpath.ToLinux(c.Distro, "", path)
+	case "-w":
+		out, err = wslpath.ToWindows(c.Distro, "", path)
+	case "-m":
+		out, err = wslpath.ToWindows(c.Distro, "", path)
+		out = strings.ReplaceAll(out, `\`, "/")
+	default:
+		err = fmt.Errorf("unknown option %s", flag)
 	}
 
-	exec, err := exec.LookPath(executable)
 	if err != nil {
-		panic(fmt.Sprintf("could not find executable %q", executable))
+		fmt.Fprintf(stderr, "wslpath: %s: %v\n", path, err)
+		return 1
 	}
 
-	p, err := os.StartProcess(exec, argv, &os.ProcAttr{
-		Files: []*os.File{stdin, stdout, stderr},
-	})
+	fmt.Fprintln(stdout, out)
+	return 0
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("could not start mock process: %v", err)
+// splitArgv splits a command into words the way a POSIX shell would. It fails if the command is
+// not a simple command, i.e. if it contains expansions, redirections, pipes, lists, etc.
+func splitArgv(command string) ([]string, bool) {
+	var argv []string
+	var word strings.Builder
+	inWord := false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				argv = append(argv, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, false
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			for i++; i < len(command) && command[i] != '"'; i++ {
+				switch command[i] {
+				case '$', '`':
+					return nil, false
+				case '\\':
+					if i+1 < len(command) && strings.IndexByte("$`\"\\", command[i+1]) >= 0 {
+						i++
+					}
+				}
+				word.WriteByte(command[i])
+			}
+			if i == len(command) {
+				return nil, false
+			}
+		case c == '\\':
+			if i+1 == len(command) {
+				return nil, false
+			}
+			i++
+			word.WriteByte(command[i])
+		case strings.IndexByte("|&;<>()$`*?[", c) >= 0:
+			return nil, false
+		case !inWord && (c == '#' || c == '~'):
+			return nil, false
+		default:
+			word.WriteByte(c)
+		}
+		inWord = true
+	}
+
+	if inWord {
+		argv = append(argv, word.String())
 	}
 
-	return p, nil
+	return argv, true
 }
@@ -0,0 +1,169 @@
+package mock
+
+// This file contains the injection of faults into the mock back-end.
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Fault describes how a method of the mock back-end misbehaves. The zero value makes every
+// call fail instantly with an error of type mock.Error. The fields can be combined; for
+// instance, the following makes the third call to WslLaunch take one second and then fail
+// with a custom error:
+//
+//	b.InjectFault("WslLaunch", mock.Fault{OnCall: 3, Latency: time.Second, Err: myErr})
+type Fault struct {
+	// Err is the error returned by the affected calls. If it is nil, the calls fail with
+	// mock.Error, unless the fault only adds Latency, Hangs, or sets an ExitCode.
+	Err error
+
+	// ExitCode is the exit code returned by the affected calls to WslLaunchInteractive,
+	// which otherwise return an error. Exit codes above 255 are reported by GoWSL as
+	// Windows errors, which is how WSL surfaces its error codes (such as 0x8007019e when
+	// the WSL optional component is not enabled). Other methods ignore it and proceed.
+	ExitCode uint32
+
+	// OnCall makes only the Nth call after the fault is injected fail, starting at 1.
+	// Zero means every call.
+	OnCall int
+
+	// Probability makes the affected calls fail only with this probability, between 0 and 1.
+	// Zero, like one, means every call: there is no probability that never affects them.
+	// Use ClearFault to stop a fault instead.
+	Probability float64
+
+	// Latency delays the affected calls, as measured by the back-end's clock. Used on its own,
+	// the calls succeed afterwards.
+	Latency time.Duration
+
+	// Hang blocks the affected calls until their context is done, or the fault is cleared or
+	// replaced. Once released, the calls proceed normally unless Err is set.
+	Hang bool
+}
+
+// faultyMethods are the methods of the back-end that faults can be injected into.
+var faultyMethods = []string{
+	"WslConfigureDistribution",
+	"WslGetDistributionConfiguration",
+	"WslLaunch",
+	"WslLaunchInteractive",
+	"WslRegisterDistribution",
+	"WslUnregisterDistribution",
+	"OpenLxssRegistry",
+	"Shutdown",
+	"Terminate",
+	"SetAsDefault",
+	"State",
+	"Install",
+	"Import",
+	"RemoveAppxFamily",
+}
+
+// injectedFault is a fault along with its bookkeeping.
+type injectedFault struct {
+	Fault
+	calls   int
+	release chan struct{} // Closed when the fault is removed, to release the hung calls.
+}
+
+// InjectFault makes calls to the method misbehave as described by the fault, replacing any
+// fault previously injected into it. The method is named as in the recorded calls, for
+// instance "WslLaunch" or "OpenLxssRegistry". It panics if the method is not known.
+//
+// Faults can be injected and cleared while other goroutines use the back-end.
+func (b *Backend) InjectFault(method string, f Fault) {
+	if !slices.Contains(faultyMethods, method) {
+		panic(fmt.Sprintf("mock: cannot inject faults into unknown method %q", method))
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		panic(fmt.Sprintf("mock: fault probability %v is not between 0 and 1", f.Probability))
+	}
+
+	b.faultsMu.Lock()
+	defer b.faultsMu.Unlock()
+
+	if b.faults == nil {
+		b.faults = make(map[string]*injectedFault)
+	}
+
+	if old, ok := b.faults[method]; ok {
+		close(old.release)
+	}
+	b.faults[method] = &injectedFault{Fault: f, release: make(chan struct{})}
+}
+
+// ClearFault removes the fault injected into the method, releasing any calls hung by it.
+func (b *Backend) ClearFault(method string) {
+	b.faultsMu.Lock()
+	defer b.faultsMu.Unlock()
+
+	if old, ok := b.faults[method]; ok {
+		close(old.release)
+		delete(b.faults, method)
+	}
+}
+
+// ClearFaults removes all injected faults, releasing any calls hung by them.
+func (b *Backend) ClearFaults() {
+	b.faultsMu.Lock()
+	defer b.faultsMu.Unlock()
+
+	for _, f := range b.faults {
+		close(f.release)
+	}
+	b.faults = nil
+}
+
+// fault applies the fault injected into the method, if any, to the current call. It returns the
+// applied fault, or nil if the call was not affected, and the error the call must fail with. A nil
+// error with a non-nil fault means that the call must proceed, or return the fault's ExitCode.
+//
+// Like the real back-end, calls fail right away once their context is done.
+func (b *Backend) fault(ctx context.Context, method string) (*Fault, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.faultsMu.Lock()
+	f, ok := b.faults[method]
+	if !ok {
+		b.faultsMu.Unlock()
+		return nil, nil
+	}
+
+	f.calls++
+	affected := (f.OnCall == 0 || f.calls == f.OnCall) &&
+		//nolint:gosec // Faults need not be cryptographically random.
+		(f.Probability == 0 || rand.Float64() < f.Probability)
+	b.faultsMu.Unlock()
+
+	if !affected {
+		return nil, nil
+	}
+
+	if err := b.sleep(ctx, f.Latency); err != nil {
+		return &f.Fault, err
+	}
+
+	if f.Hang {
+		select {
+		case <-ctx.Done():
+			return &f.Fault, ctx.Err()
+		case <-f.release:
+		}
+	}
+
+	if f.Err != nil {
+		return &f.Fault, f.Err
+	}
+
+	if f.Latency > 0 || f.Hang || f.ExitCode != 0 {
+		return &f.Fault, nil
+	}
+
+	return &f.Fault, Error{}
+}
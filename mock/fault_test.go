@@ -0,0 +1,188 @@
+package mock_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestInjectFault(t *testing.T) {
+	t.Parallel()
+
+	errCustom := errors.New("custom error")
+
+	testCases := map[string]struct {
+		fault mock.Fault
+
+		wantFailures []bool
+		wantErr      error
+		wantDelay    time.Duration
+	}{
+		"Success failing every call":                       {fault: mock.Fault{}, wantFailures: []bool{true, true, true}, wantErr: mock.Error{}},
+		"Success failing the Nth call":                     {fault: mock.Fault{OnCall: 2}, wantFailures: []bool{false, true, false}, wantErr: mock.Error{}},
+		"Success failing with an error":                    {fault: mock.Fault{Err: errCustom}, wantFailures: []bool{true, true}, wantErr: errCustom},
+		"Success failing always with a probability of one": {fault: mock.Fault{Probability: 1}, wantFailures: []bool{true, true}, wantErr: mock.Error{}},
+		"Success adding latency":                           {fault: mock.Fault{Latency: 100 * time.Millisecond}, wantFailures: []bool{false}, wantDelay: 100 * time.Millisecond},
+		"Success adding latency to a failure":              {fault: mock.Fault{Latency: 100 * time.Millisecond, Err: errCustom}, wantFailures: []bool{true}, wantErr: errCustom, wantDelay: 100 * time.Millisecond},
+		"Success ignoring the exit code in other methods":  {fault: mock.Fault{ExitCode: 42}, wantFailures: []bool{false}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBackendWithDistro(t)
+			b.InjectFault("Terminate", tc.fault)
+
+			for i, wantFailure := range tc.wantFailures {
+				start := time.Now()
+				err := b.Terminate(context.Background(), testDistro)
+				require.GreaterOrEqual(t, time.Since(start), tc.wantDelay, "Call #%d returned too early", i+1)
+
+				if !wantFailure {
+					require.NoError(t, err, "Call #%d should have succeeded", i+1)
+					continue
+				}
+				require.ErrorIs(t, err, tc.wantErr, "Call #%d should have failed with the injected error", i+1)
+			}
+
+			b.ClearFault("Terminate")
+			require.NoError(t, b.Terminate(context.Background(), testDistro), "Calls should succeed after clearing the fault")
+		})
+	}
+}
+
+func TestInjectFaultProbability(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+	b.InjectFault("State", mock.Fault{Probability: 0.5})
+
+	var failures int
+	for range 1000 {
+		if _, err := b.State(context.Background(), testDistro); err != nil {
+			failures++
+		}
+	}
+
+	// The chance of falling outside of these bounds is negligible.
+	require.Greater(t, failures, 300, "Too few calls failed")
+	require.Less(t, failures, 700, "Too many calls failed")
+}
+
+func TestInjectFaultExitCode(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+	b.InjectFault("WslLaunchInteractive", mock.Fault{ExitCode: 0x8007019e})
+
+	exitCode, err := b.WslLaunchInteractive(context.Background(), testDistro, "exit 0", false)
+	require.NoError(t, err, "WslLaunchInteractive should not return an error when only the exit code is injected")
+	require.Equal(t, uint32(0x8007019e), exitCode, "Unexpected exit code")
+}
+
+func TestInjectFaultHang(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Hanging until the context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		b := mock.New()
+		b.InjectFault("Install", mock.Fault{Hang: true})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := b.Install(ctx, "Ubuntu")
+		require.ErrorIs(t, err, context.DeadlineExceeded, "Install should have hung until the context was cancelled")
+	})
+
+	t.Run("Hanging until the fault is cleared", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBackendWithDistro(t)
+		b.InjectFault("Terminate", mock.Fault{Hang: true})
+
+		done := make(chan error)
+		go func() { done <- b.Terminate(context.Background(), testDistro) }()
+
+		select {
+		case err := <-done:
+			require.Fail(t, "Terminate should have hung", "Returned: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		b.ClearFaults()
+
+		select {
+		case err := <-done:
+			require.NoError(t, err, "Terminate should have proceeded normally after clearing the fault")
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "Terminate should have been released after clearing the fault")
+		}
+	})
+}
+
+func TestInjectFaultLatencyFollowsClock(t *testing.T) {
+	t.Parallel()
+
+	c := mock.NewFakeClock(time.Now())
+	b := mock.New(mock.WithClock(c))
+	b.InjectFault("State", mock.Fault{Latency: time.Hour})
+
+	done := make(chan error)
+	go func() {
+		_, err := b.State(context.Background(), testDistro)
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool { return c.Timers() == 1 }, 10*time.Second, time.Millisecond, "Setup: the call should have started")
+
+	c.Advance(time.Hour - time.Nanosecond)
+	select {
+	case err := <-done:
+		require.Fail(t, "State should have been delayed until the clock advanced", "Returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.Advance(time.Nanosecond)
+	require.NoError(t, <-done, "State should have succeeded after the latency")
+}
+
+func TestInjectFaultConcurrently(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				_, _ = b.State(context.Background(), testDistro)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				b.InjectFault("State", mock.Fault{OnCall: i})
+				b.ClearFaults()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestInjectFaultPanics(t *testing.T) {
+	t.Parallel()
+
+	b := mock.New()
+	require.Panics(t, func() { b.InjectFault("NotAMethod", mock.Fault{}) }, "Injecting a fault into an unknown method should panic")
+	require.Panics(t, func() { b.InjectFault("State", mock.Fault{Probability: 2}) }, "Injecting a fault with an invalid probability should panic")
+}
@@ -0,0 +1,183 @@
+package mock
+
+// This file contains the machinery to mock the commands launched inside distros.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/ubuntu/gowsl/internal/stubprocess"
+)
+
+// Handler mocks a command launched inside a distro. It must read the command's standard
+// input from stdin, write its output into stdout and stderr, and return its exit code.
+//
+// The context is cancelled if the command is killed (for instance, when the distro is
+// terminated). Use CommandFromContext to find out which command is being handled.
+//
+// Exit codes above 255 are reported by GoWSL as Windows errors, as WSL does with its own
+// error codes.
+type Handler func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) int
+
+// Matcher reports whether a Handler must be used to mock a command.
+// Use any of the provided functions such as Exact(), or write your own.
+type Matcher func(command string) bool
+
+// Exact matches commands that are exactly equal to the one provided.
+func Exact(command string) Matcher {
+	return func(c string) bool {
+		return c == command
+	}
+}
+
+// Regexp matches commands that match the provided regular expression.
+// It panics if the expression cannot be parsed.
+func Regexp(expr string) Matcher {
+	re := regexp.MustCompile(expr)
+	return re.MatchString
+}
+
+// Argv matches commands which, once split into words the way a shell would, are exactly
+// equal to the provided arguments. Commands that are not simple (such as those containing
+// pipes, redirections or variables) never match.
+func Argv(args ...string) Matcher {
+	return func(c string) bool {
+		argv, ok := splitArgv(c)
+		return ok && slices.Equal(argv, args)
+	}
+}
+
+// Command describes a command that is being mocked.
+type Command struct {
+	Distro      string   // Name of the distro the command was launched in
+	Root        string   // Root directory of the distro in the host, if the back-end is sandboxed
+	Line        string   // The command, as passed to WslLaunch or WslLaunchInteractive
+	Argv        []string // The command split into words, or nil if it is not a simple command
+	UseCWD      bool     // Whether the command was launched in the current working directory
+	Interactive bool     // Whether the command was launched via WslLaunchInteractive
+}
+
+type commandKeyType struct{}
+
+var commandKey commandKeyType
+
+// CommandFromContext returns the command being mocked by a Handler.
+func CommandFromContext(ctx context.Context) Command {
+	c, _ := ctx.Value(commandKey).(Command)
+	return c
+}
+
+// Handle registers a Handler for the commands that match. Handlers registered later take
+// precedence over earlier ones, and all of them take precedence over the default handlers
+// that GoWSL's own tests rely on.
+//
+// Commands that match no handler fail to launch.
+func (b *Backend) Handle(match Matcher, handler Handler) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.handlers = append(b.handlers, commandHandler{match: match, handler: handler})
+}
+
+// ResetHandlers removes all the handlers registered via Handle. The default handlers remain.
+func (b *Backend) ResetHandlers() {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.handlers = nil
+}
+
+type commandHandler struct {
+	match   Matcher
+	handler Handler
+}
+
+// customHandler returns the last handler registered via Handle that matches the command.
+func (b *Backend) customHandler(command string) (Handler, bool) {
+	b.handlersMu.RLock()
+	defer b.handlersMu.RUnlock()
+
+	for i := len(b.handlers) - 1; i >= 0; i-- {
+		if b.handlers[i].match(command) {
+			return b.handlers[i].handler, true
+		}
+	}
+
+	return nil, false
+}
+
+// findHandler returns the handler for the command, be it custom or default.
+func (b *Backend) findHandler(command string) (Handler, error) {
+	if h, ok := b.customHandler(command); ok {
+		return h, nil
+	}
+	return b.defaultHandler(command)
+}
+
+// defaultHandler returns the default handler for the command. If the back-end
+// is sandboxed, commands without a default handler run in the sandbox.
+func (b *Backend) defaultHandler(command string) (Handler, error) {
+	for _, h := range defaultHandlers {
+		if h.match(command) {
+			return h.handler, nil
+		}
+	}
+
+	if b.sandbox != "" {
+		return hostCommand("", ""), nil
+	}
+
+	return nil, fmt.Errorf("command not supported by the mock: %q", command)
+}
+
+// newCommand describes a command to be passed to its handler.
+func newCommand(distro, root, line string, useCWD, interactive bool) Command {
+	argv, _ := splitArgv(line)
+	return Command{
+		Distro:      distro,
+		Root:        root,
+		Line:        line,
+		Argv:        argv,
+		UseCWD:      useCWD,
+		Interactive: interactive,
+	}
+}
+
+// launch runs the handler in the background, and returns a stub process that
+// exits with the handler's exit code.
+func launch(cmd Command, handler Handler, stdin, stdout, stderr *os.File) (p *os.Process, err error) {
+	// The caller may close its files as soon as we return.
+	var files []*os.File
+	for _, f := range []*os.File{stdin, stdout, stderr} {
+		dup, err := stubprocess.Dup(f)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, dup)
+	}
+
+	p, err = stubprocess.Start(func(ctx context.Context) int {
+		defer func() {
+			for _, f := range files {
+				f.Close()
+			}
+		}()
+
+		return handler(context.WithValue(ctx, commandKey, cmd), files[0], files[1], files[2])
+	})
+	if err != nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+
+	return p, nil
+}
@@ -0,0 +1,234 @@
+package mock_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+const testDistro = "testDistro"
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	echo := func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) int {
+		in, err := io.ReadAll(stdin)
+		if err != nil {
+			return 255
+		}
+		fmt.Fprintf(stdout, "%s|%s", mock.CommandFromContext(ctx).Line, in)
+		fmt.Fprint(stderr, "Error!")
+		return 7
+	}
+
+	testCases := map[string]struct {
+		match   mock.Matcher
+		command string
+
+		wantStdout   string
+		wantStderr   string
+		wantExitCode int
+		wantErr      bool
+	}{
+		"Success with an exact matcher":              {match: mock.Exact("do something"), command: "do something", wantStdout: "do something|input", wantStderr: "Error!", wantExitCode: 7},
+		"Success with a regexp matcher":              {match: mock.Regexp(`^do \w+$`), command: "do something", wantStdout: "do something|input", wantStderr: "Error!", wantExitCode: 7},
+		"Success with an argv matcher":               {match: mock.Argv("do", "some thing"), command: `do 'some thing'`, wantStdout: "do 'some thing'|input", wantStderr: "Error!", wantExitCode: 7},
+		"Success with an argv matcher and escaping":  {match: mock.Argv("do", `"it's"`), command: `do "\"it's\""`, wantStdout: `do "\"it's\""|input`, wantStderr: "Error!", wantExitCode: 7},
+		"Success overriding a default handler":       {match: mock.Exact("exit 0"), command: "exit 0", wantStdout: "exit 0|input", wantStderr: "Error!", wantExitCode: 7},
+		"Success falling back to a default handler":  {match: mock.Exact("do something"), command: "exit 42", wantExitCode: 42},
+		"Success with a default handler for wslpath": {match: mock.Exact("do something"), command: `wslpath -u 'C:\Users'`, wantStdout: "/mnt/c/Users\n"},
+
+		"Error when no handler matches":                  {match: mock.Exact("do something"), command: "do something else", wantErr: true},
+		"Error when the argv matcher gets a pipe":        {match: mock.Argv("do", "something"), command: "do something | cat", wantErr: true},
+		"Error when the argv matcher gets a variable":    {match: mock.Argv("do", "something"), command: "do $something", wantErr: true},
+		"Error when the argv matcher gets extra words":   {match: mock.Argv("do", "something"), command: "do something else", wantErr: true},
+		"Error when the regexp matcher does not match":   {match: mock.Regexp(`^do \w+$`), command: "do some thing", wantErr: true},
+		"Error when the argv matcher gets unclosed text": {match: mock.Argv("do", "something"), command: "do 'something", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBackendWithDistro(t)
+			b.Handle(tc.match, echo)
+
+			stdout, stderr, exitCode, err := launch(t, b, tc.command, "input")
+			if tc.wantErr {
+				require.Error(t, err, "WslLaunch should have failed")
+				return
+			}
+			require.NoError(t, err, "WslLaunch should have succeeded")
+
+			require.Equal(t, tc.wantStdout, stdout, "Unexpected contents in stdout")
+			require.Equal(t, tc.wantStderr, stderr, "Unexpected contents in stderr")
+			require.Equal(t, tc.wantExitCode, exitCode, "Unexpected exit code")
+		})
+	}
+}
+
+func TestHandlePrecedence(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+	b.Handle(mock.Regexp("^do"), exitWith(1))
+	b.Handle(mock.Exact("do something"), exitWith(2))
+
+	_, _, exitCode, err := launch(t, b, "do something", "")
+	require.NoError(t, err, "WslLaunch should have succeeded")
+	require.Equal(t, 2, exitCode, "The handler registered last should have been used")
+
+	_, _, exitCode, err = launch(t, b, "do something else", "")
+	require.NoError(t, err, "WslLaunch should have succeeded")
+	require.Equal(t, 1, exitCode, "The only matching handler should have been used")
+
+	b.ResetHandlers()
+	_, _, _, err = launch(t, b, "do something", "")
+	require.Error(t, err, "WslLaunch should fail after resetting the handlers")
+}
+
+func TestHandleKill(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+
+	cancelled := make(chan struct{})
+	b.Handle(mock.Exact("wait"), func(ctx context.Context, _ io.Reader, _, _ io.Writer) int {
+		<-ctx.Done()
+		close(cancelled)
+		return 0
+	})
+
+	p, _, _ := startProcess(t, b, "wait", "")
+	require.NoError(t, b.Terminate(context.Background(), testDistro), "Terminate should have succeeded")
+
+	select {
+	case <-cancelled:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "The handler's context should have been cancelled after the distro was terminated")
+	}
+
+	state, err := p.Wait()
+	require.NoError(t, err, "Wait should have succeeded")
+	require.NotEqual(t, 0, state.ExitCode(), "The process should have been killed")
+}
+
+func TestHandleInteractive(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+
+	var got mock.Command
+	b.Handle(mock.Exact("do something"), func(ctx context.Context, _ io.Reader, _, _ io.Writer) int {
+		got = mock.CommandFromContext(ctx)
+		return 5
+	})
+
+	exitCode, err := b.WslLaunchInteractive(context.Background(), testDistro, "do something", true)
+	require.NoError(t, err, "WslLaunchInteractive should have succeeded")
+	require.Equal(t, uint32(5), exitCode, "Unexpected exit code")
+
+	want := mock.Command{
+		Distro:      testDistro,
+		Line:        "do something",
+		Argv:        []string{"do", "something"},
+		UseCWD:      true,
+		Interactive: true,
+	}
+	require.Equal(t, want, got, "Unexpected command passed to the handler")
+
+	_, err = b.WslLaunchInteractive(context.Background(), testDistro, "do something else", true)
+	require.Error(t, err, "WslLaunchInteractive should fail when no handler matches")
+}
+
+// newBackendWithDistro returns a new mock back-end with a registered distro.
+func newBackendWithDistro(t *testing.T) *mock.Backend {
+	t.Helper()
+
+	b := mock.New()
+	err := b.WslRegisterDistribution(context.Background(), testDistro, "rootfs.tar.gz")
+	require.NoError(t, err, "Setup: could not register distro")
+
+	return b
+}
+
+// exitWith returns a handler that exits with the given code.
+func exitWith(code int) mock.Handler {
+	return func(context.Context, io.Reader, io.Writer, io.Writer) int {
+		return code
+	}
+}
+
+// startProcess launches the command with the given input, and returns the process
+// along with the readers for its stdout and stderr.
+func startProcess(t *testing.T, b *mock.Backend, command, input string) (p *os.Process, stdout, stderr *os.File) {
+	t.Helper()
+
+	p, stdout, stderr, err := tryStartProcess(t, b, command, input)
+	require.NoError(t, err, "Setup: could not launch command")
+
+	return p, stdout, stderr
+}
+
+// tryStartProcess is the same as startProcess, but it returns an error if the command cannot be launched.
+func tryStartProcess(t *testing.T, b *mock.Backend, command, input string) (p *os.Process, stdout, stderr *os.File, err error) {
+	t.Helper()
+
+	var files [3][2]*os.File
+	for i := range files {
+		r, w, err := os.Pipe()
+		require.NoError(t, err, "Setup: could not create pipe")
+		files[i] = [2]*os.File{r, w}
+	}
+
+	p, err = b.WslLaunch(context.Background(), testDistro, command, false, files[0][0], files[1][1], files[2][1])
+
+	// The mock must keep working after we close our copies of the process's files.
+	files[0][0].Close()
+	files[1][1].Close()
+	files[2][1].Close()
+
+	t.Cleanup(func() {
+		files[0][1].Close()
+		files[1][0].Close()
+		files[2][0].Close()
+	})
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	_, err = files[0][1].WriteString(input)
+	require.NoError(t, err, "Setup: could not write into stdin")
+	files[0][1].Close()
+
+	return p, files[1][0], files[2][0], nil
+}
+
+// launch runs the command with the given input, and returns its output and exit code.
+func launch(t *testing.T, b *mock.Backend, command, input string) (stdout, stderr string, exitCode int, err error) {
+	t.Helper()
+
+	p, stdoutR, stderrR, err := tryStartProcess(t, b, command, input)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	var out, errOut strings.Builder
+	_, err = io.Copy(&out, stdoutR)
+	require.NoError(t, err, "could not read stdout")
+	_, err = io.Copy(&errOut, stderrR)
+	require.NoError(t, err, "could not read stderr")
+
+	state, err := p.Wait()
+	require.NoError(t, err, "could not wait for process")
+
+	return out.String(), errOut.String(), state.ExitCode(), nil
+}
@@ -0,0 +1,123 @@
+// Package clock abstracts the passing of time, so that tests of the mock can control it.
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules functions to be run in the future.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a function scheduled by a Clock.
+type Timer interface {
+	// Stop prevents the function from being called. It returns false if
+	// the function was already called or stopped.
+	Stop() bool
+}
+
+// Real returns the clock of the system, as implemented by the time package.
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Fake is a Clock whose time only passes when it is advanced.
+type Fake struct {
+	now    time.Time
+	timers []*fakeTimer // Sorted by deadline
+
+	mu sync.Mutex
+}
+
+type fakeTimer struct {
+	clock    *Fake
+	deadline time.Time
+	f        func()
+}
+
+// NewFake returns a fake clock set to the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the current time of the fake clock.
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc schedules f to be called once the clock has been advanced by d.
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), f: f}
+
+	// Timers with the same deadline fire in the order they were scheduled.
+	i := slices.IndexFunc(c.timers, func(other *fakeTimer) bool { return other.deadline.After(t.deadline) })
+	if i < 0 {
+		i = len(c.timers)
+	}
+	c.timers = slices.Insert(c.timers, i, t)
+
+	return t
+}
+
+// Advance moves the clock forward by d. The functions of the timers that expire are
+// called synchronously, in order of expiry, before Advance returns.
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+
+	for len(c.timers) > 0 && !c.timers[0].deadline.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.deadline
+
+		// The function may use the clock.
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Timers returns the number of timers that have not expired nor been stopped yet.
+func (c *Fake) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// Stop prevents the timer from firing.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	i := slices.Index(t.clock.timers, t)
+	if i < 0 {
+		return false
+	}
+
+	t.clock.timers = slices.Delete(t.clock.timers, i, i+1)
+	return true
+}
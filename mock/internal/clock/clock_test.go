@@ -0,0 +1,82 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/mock/internal/clock"
+)
+
+func TestFake(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+
+	var fired []string
+	record := func(name string) func() {
+		return func() {
+			fired = append(fired, name)
+			require.Equal(t, name, c.Now().Sub(start).String(), "Timers should fire with the clock at their deadline")
+		}
+	}
+
+	c.AfterFunc(2*time.Second, record("2s"))
+	c.AfterFunc(time.Second, record("1s"))
+	c.AfterFunc(2*time.Second, record("2s"))
+	stopped := c.AfterFunc(time.Second, record("stopped"))
+	c.AfterFunc(time.Minute, record("1m0s"))
+
+	require.True(t, stopped.Stop(), "Stopping a pending timer should succeed")
+	require.False(t, stopped.Stop(), "Stopping a timer twice should fail")
+	require.Equal(t, 4, c.Timers(), "Unexpected number of pending timers")
+
+	c.Advance(500 * time.Millisecond)
+	require.Empty(t, fired, "No timer should fire before its deadline")
+	require.Equal(t, start.Add(500*time.Millisecond), c.Now(), "Unexpected time after advancing")
+
+	c.Advance(1500 * time.Millisecond)
+	require.Equal(t, []string{"1s", "2s", "2s"}, fired, "Expired timers should fire in order")
+	require.Equal(t, 1, c.Timers(), "Unexpected number of pending timers")
+
+	c.Advance(time.Hour)
+	require.Equal(t, []string{"1s", "2s", "2s", "1m0s"}, fired, "Expired timers should fire in order")
+	require.Equal(t, start.Add(2*time.Second+time.Hour), c.Now(), "Unexpected time after advancing")
+	require.Zero(t, c.Timers(), "No timers should be pending")
+}
+
+func TestFakeReschedule(t *testing.T) {
+	t.Parallel()
+
+	c := clock.NewFake(time.Now())
+
+	var count int
+	var tick func()
+	tick = func() {
+		count++
+		c.AfterFunc(time.Second, tick)
+	}
+	c.AfterFunc(time.Second, tick)
+
+	c.Advance(5 * time.Second)
+	require.Equal(t, 5, count, "Timers scheduled while advancing should fire if they expire")
+}
+
+func TestReal(t *testing.T) {
+	t.Parallel()
+
+	c := clock.Real()
+
+	before := time.Now()
+	require.False(t, c.Now().Before(before), "The real clock should tell the current time")
+
+	done := make(chan struct{})
+	c.AfterFunc(time.Millisecond, func() { close(done) })
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "The real timer should have fired")
+	}
+}
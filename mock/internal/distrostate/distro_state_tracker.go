@@ -4,10 +4,14 @@ package distrostate
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/ubuntu/gowsl/internal/state"
+	"github.com/ubuntu/gowsl/mock/internal/clock"
 )
 
 // DistroState tracks whether a dsitro is active or not.
@@ -15,8 +19,10 @@ type DistroState struct {
 	// running indicates whether the distro is running or not.
 	running bool
 
-	// terminateTimer stops the distro 8 seconds after no processes are left.
-	terminateTimer *time.Timer
+	// terminateTimer stops the distro idleTimeout after no shells are left.
+	terminateTimer clock.Timer
+	idleTimeout    time.Duration
+	clock          clock.Clock
 
 	// processes is a set of attached processes.
 	processes map[*os.Process]struct{}
@@ -28,6 +34,11 @@ type DistroState struct {
 	// flag to avoid races where you may attach a process after the distro has been uninstalled.
 	uninstalled bool
 
+	// transition is the transitional state (such as Installing) the distro is in, if inTransition is set.
+	// The distro cannot be started while in transition.
+	transition   state.State
+	inTransition bool
+
 	mu sync.RWMutex
 }
 
@@ -37,13 +48,27 @@ type DistroState struct {
 type Shell struct {
 	done   chan struct{}
 	closed atomic.Bool
+
+	// onClose is called when the shell exits on its own.
+	onClose func()
 }
 
-// Close mocks the killing of the shell process.
+// Close mocks the exiting of the shell process. If it was the last
+// shell, the distro will be terminated after its idle timeout.
 func (s *Shell) Close() {
-	if updated := s.closed.CompareAndSwap(false, true); updated {
-		close(s.done)
+	if s.close() && s.onClose != nil {
+		s.onClose()
+	}
+}
+
+// close closes the shell without notifying the distro. It returns
+// false if it was already closed.
+func (s *Shell) close() bool {
+	if updated := s.closed.CompareAndSwap(false, true); !updated {
+		return false
 	}
+	close(s.done)
+	return true
 }
 
 // Wait waits until the shell is closed.
@@ -54,11 +79,14 @@ func (s *Shell) Wait() uint32 {
 	return 0
 }
 
-// New creates a new disro state with state Stopped.
-func New() *DistroState {
+// New creates a new disro state with state Stopped. The distro is terminated once it has been
+// idle for idleTimeout, as measured by the clock.
+func New(clk clock.Clock, idleTimeout time.Duration) *DistroState {
 	return &DistroState{
-		processes: make(map[*os.Process]struct{}),
-		shells:    make(map[*Shell]struct{}),
+		processes:   make(map[*os.Process]struct{}),
+		shells:      make(map[*Shell]struct{}),
+		idleTimeout: idleTimeout,
+		clock:       clk,
 	}
 }
 
@@ -70,13 +98,48 @@ func (t *DistroState) IsRunning() bool {
 	return t.running
 }
 
+// Transition returns the transitional state the distro is in, if any.
+func (t *DistroState) Transition() (state.State, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.transition, t.inTransition
+}
+
+// BeginTransition stops the distro and puts it into a transitional state such as
+// Installing, until EndTransition is called.
+func (t *DistroState) BeginTransition(s state.State) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.terminate(); err != nil {
+		return err
+	}
+	if t.inTransition {
+		return fmt.Errorf("distro is %s", t.transition)
+	}
+
+	t.transition = s
+	t.inTransition = true
+
+	return nil
+}
+
+// EndTransition takes the distro out of its transitional state, leaving it stopped.
+func (t *DistroState) EndTransition() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.inTransition = false
+}
+
 // Touch resets the terminate timer if there was one.
 func (t *DistroState) Touch() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if t.uninstalled {
-		return errors.New("distro unregistered")
+	if err := t.canStart(); err != nil {
+		return err
 	}
 
 	t.running = true
@@ -116,8 +179,8 @@ func (t *DistroState) AttachProcess(p *os.Process) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if t.uninstalled {
-		return errors.New("distro unregistered")
+	if err := t.canStart(); err != nil {
+		return err
 	}
 
 	t.cancelTimer()
@@ -134,18 +197,36 @@ func (t *DistroState) NewShell() (*Shell, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if t.uninstalled {
-		return nil, errors.New("distro unregistered")
+	if err := t.canStart(); err != nil {
+		return nil, err
 	}
 
 	t.cancelTimer()
 
 	s := &Shell{done: make(chan struct{})}
+	s.onClose = func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.refresh()
+	}
 	t.shells[s] = struct{}{}
 
 	return s, nil
 }
 
+// canStart returns an error if the distro cannot be started.
+//
+// Use under a mutex.
+func (t *DistroState) canStart() error {
+	if t.uninstalled {
+		return errors.New("distro unregistered")
+	}
+	if t.inTransition {
+		return fmt.Errorf("distro is %s", t.transition)
+	}
+	return nil
+}
+
 // terminate kills all attached processes and sets running to false
 //
 // Use under a write mutex.
@@ -165,8 +246,8 @@ func (t *DistroState) terminate() error {
 	t.processes = make(map[*os.Process]struct{})
 
 	// Closing shells
-	for p := range t.shells {
-		p.Close()
+	for s := range t.shells {
+		s.close()
 	}
 	t.shells = make(map[*Shell]struct{})
 
@@ -198,13 +279,13 @@ func (t *DistroState) refresh() {
 	t.startTimer()
 }
 
-// Starts the 8 second timeout terminate timer.
+// Starts the idle timeout terminate timer.
 // If it was already ticking, it is restarted.
 //
 // Use under a write mutex.
 func (t *DistroState) startTimer() {
 	t.cancelTimer()
-	t.terminateTimer = time.AfterFunc(8*time.Second, func() { _ = t.Terminate() })
+	t.terminateTimer = t.clock.AfterFunc(t.idleTimeout, func() { _ = t.Terminate() })
 }
 
 // Cancels the terminate timer.
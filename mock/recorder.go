@@ -0,0 +1,208 @@
+package mock
+
+// This file contains the recording of the calls made to the mock back-end.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Call is a record of an invocation of a method of the mock back-end.
+type Call struct {
+	Method   string        // Name of the method
+	Args     []any         // Arguments, except for contexts and files
+	Time     time.Time     // Time when the method was called
+	Duration time.Duration // Time it took for the method to return
+	Err      error         // Error returned by the method
+}
+
+// String returns a human-readable representation of the call.
+func (c Call) String() string {
+	args := make([]string, 0, len(c.Args))
+	for _, a := range c.Args {
+		args = append(args, fmt.Sprintf("%#v", a))
+	}
+
+	s := fmt.Sprintf("%s(%s)", c.Method, strings.Join(args, ", "))
+	if c.Err != nil {
+		s += fmt.Sprintf(": %v", c.Err)
+	}
+	return s
+}
+
+// MarshalJSON implements json.Marshaler. The error is exported as its message.
+func (c Call) MarshalJSON() ([]byte, error) {
+	var errMsg string
+	if c.Err != nil {
+		errMsg = c.Err.Error()
+	}
+
+	return json.Marshal(struct {
+		Method   string        `json:"method"`
+		Args     []any         `json:"args"`
+		Time     time.Time     `json:"time"`
+		Duration time.Duration `json:"duration"`
+		Err      string        `json:"error,omitempty"`
+	}{
+		Method:   c.Method,
+		Args:     c.Args,
+		Time:     c.Time,
+		Duration: c.Duration,
+		Err:      errMsg,
+	})
+}
+
+// record records a call to a method. The returned function must be deferred with a
+// pointer to the method's returned error, so that the outcome of the call is recorded
+// when the method returns:
+//
+//	defer b.record("Method", arg1, arg2)(&err)
+func (b *Backend) record(method string, args ...any) func(*error) {
+	c := &Call{
+		Method: method,
+		Args:   args,
+		Time:   b.clock.Now(),
+	}
+
+	b.callsMu.Lock()
+	b.calls = append(b.calls, c)
+	b.callsMu.Unlock()
+
+	return func(err *error) {
+		b.callsMu.Lock()
+		defer b.callsMu.Unlock()
+
+		c.Duration = b.clock.Now().Sub(c.Time)
+		c.Err = *err
+	}
+}
+
+// Calls returns all the recorded calls to the method, in the order they were made.
+// If the method is empty, the calls to all methods are returned. Calls that have
+// not returned yet have a zero Duration.
+func (b *Backend) Calls(method string) []Call {
+	b.callsMu.Lock()
+	defer b.callsMu.Unlock()
+
+	var calls []Call
+	for _, c := range b.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, *c)
+		}
+	}
+
+	return calls
+}
+
+// ResetCalls forgets all the recorded calls.
+func (b *Backend) ResetCalls() {
+	b.callsMu.Lock()
+	defer b.callsMu.Unlock()
+
+	b.calls = nil
+}
+
+// WriteCallsJSON exports all the recorded calls into w as a JSON array.
+func (b *Backend) WriteCallsJSON(w io.Writer) error {
+	calls := b.Calls("")
+	if calls == nil {
+		calls = []Call{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(calls); err != nil {
+		return fmt.Errorf("could not export calls: %v", err)
+	}
+
+	return nil
+}
+
+// TestingT is the subset of testing.TB used by the assertions of the mock.
+type TestingT interface {
+	Errorf(format string, args ...any)
+}
+
+// AssertCalledWith checks that the method was called at least once with the given arguments,
+// as they appear in Call.Args. Arguments are compared with reflect.DeepEqual, so their types
+// must match exactly. It reports an error to t and returns false otherwise.
+func (b *Backend) AssertCalledWith(t TestingT, method string, args ...any) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	calls := b.Calls(method)
+	for _, c := range calls {
+		if reflect.DeepEqual(c.Args, args) {
+			return true
+		}
+	}
+
+	t.Errorf("Expected a call to %s with arguments %#v.\nRecorded calls:\n%s", method, args, formatCalls(calls))
+	return false
+}
+
+// AssertNotCalled checks that the method was never called.
+// It reports an error to t and returns false otherwise.
+func (b *Backend) AssertNotCalled(t TestingT, method string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	calls := b.Calls(method)
+	if len(calls) == 0 {
+		return true
+	}
+
+	t.Errorf("Expected no calls to %s.\nRecorded calls:\n%s", method, formatCalls(calls))
+	return false
+}
+
+// AssertCalledInOrder checks that the methods were called in the given order, although other
+// calls may have happened in between. For instance, the following checks that a distro was
+// terminated before being unregistered:
+//
+//	b.AssertCalledInOrder(t, "Terminate", "WslUnregisterDistribution")
+//
+// It reports an error to t and returns false otherwise.
+func (b *Backend) AssertCalledInOrder(t TestingT, methods ...string) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	calls := b.Calls("")
+
+	next := 0
+	for _, c := range calls {
+		if next < len(methods) && c.Method == methods[next] {
+			next++
+		}
+	}
+
+	if next == len(methods) {
+		return true
+	}
+
+	t.Errorf("Expected calls to %s in this order, but found no call to %s after the preceding ones.\nRecorded calls:\n%s",
+		strings.Join(methods, ", "), methods[next], formatCalls(calls))
+	return false
+}
+
+func formatCalls(calls []Call) string {
+	if len(calls) == 0 {
+		return "  (none)"
+	}
+
+	var sb strings.Builder
+	for i, c := range calls {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "  %s", c)
+	}
+	return sb.String()
+}
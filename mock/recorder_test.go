@@ -0,0 +1,140 @@
+package mock_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/internal/flags"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestCalls(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+
+	_, _, _, err := launch(t, b, "exit 0", "")
+	require.NoError(t, err, "Setup: could not launch command")
+	require.NoError(t, b.WslConfigureDistribution(context.Background(), testDistro, 1000, flags.WslFlags(0x7)), "Setup: could not configure distro")
+	require.NoError(t, b.Terminate(context.Background(), testDistro), "Setup: could not terminate distro")
+	require.NoError(t, b.WslUnregisterDistribution(context.Background(), testDistro), "Setup: could not unregister distro")
+	require.Error(t, b.Terminate(context.Background(), testDistro), "Setup: terminating an unregistered distro should fail")
+
+	calls := b.Calls("")
+	methods := make([]string, 0, len(calls))
+	for _, c := range calls {
+		methods = append(methods, c.Method)
+	}
+	want := []string{"WslRegisterDistribution", "WslLaunch", "WslConfigureDistribution", "Terminate", "WslUnregisterDistribution", "Terminate"}
+	require.Equal(t, want, methods, "Unexpected sequence of calls")
+
+	terminate := b.Calls("Terminate")
+	require.Len(t, terminate, 2, "Unexpected number of calls to Terminate")
+	require.Equal(t, []any{testDistro}, terminate[0].Args, "Unexpected arguments for Terminate")
+	require.NoError(t, terminate[0].Err, "The first call to Terminate should have been recorded as successful")
+	require.Error(t, terminate[1].Err, "The second call to Terminate should have been recorded as failed")
+	require.False(t, terminate[0].Time.After(terminate[1].Time), "Calls should be recorded in order")
+
+	require.Empty(t, b.Calls("Shutdown"), "Methods that were never called should have no calls")
+
+	b.ResetCalls()
+	require.Empty(t, b.Calls(""), "There should be no calls after resetting them")
+}
+
+func TestCallAssertions(t *testing.T) {
+	t.Parallel()
+
+	b := newBackendWithDistro(t)
+	require.NoError(t, b.WslConfigureDistribution(context.Background(), testDistro, 1000, flags.WslFlags(0x7)), "Setup: could not configure distro")
+	require.NoError(t, b.Terminate(context.Background(), testDistro), "Setup: could not terminate distro")
+	require.NoError(t, b.WslUnregisterDistribution(context.Background(), testDistro), "Setup: could not unregister distro")
+
+	testCases := map[string]struct {
+		assert func(mock.TestingT) bool
+
+		wantFail bool
+	}{
+		"Success asserting a call with its arguments": {assert: func(t mock.TestingT) bool {
+			return b.AssertCalledWith(t, "WslConfigureDistribution", testDistro, uint32(1000), flags.WslFlags(0x7))
+		}},
+		"Success asserting that a call was not made": {assert: func(t mock.TestingT) bool { return b.AssertNotCalled(t, "Shutdown") }},
+		"Success asserting the order of calls": {assert: func(t mock.TestingT) bool {
+			return b.AssertCalledInOrder(t, "WslRegisterDistribution", "Terminate", "WslUnregisterDistribution")
+		}},
+
+		"Error asserting a call with other arguments": {wantFail: true, assert: func(t mock.TestingT) bool {
+			return b.AssertCalledWith(t, "WslConfigureDistribution", testDistro, uint32(0), flags.WslFlags(0x7))
+		}},
+		"Error asserting a call with arguments of another type": {wantFail: true, assert: func(t mock.TestingT) bool {
+			return b.AssertCalledWith(t, "WslConfigureDistribution", testDistro, 1000, flags.WslFlags(0x7))
+		}},
+		"Error asserting a call that was not made": {wantFail: true, assert: func(t mock.TestingT) bool {
+			return b.AssertCalledWith(t, "Shutdown")
+		}},
+		"Error asserting that a call was not made": {wantFail: true, assert: func(t mock.TestingT) bool { return b.AssertNotCalled(t, "Terminate") }},
+		"Error asserting the wrong order of calls": {wantFail: true, assert: func(t mock.TestingT) bool {
+			return b.AssertCalledInOrder(t, "WslUnregisterDistribution", "Terminate")
+		}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := &recordingT{}
+			ok := tc.assert(rec)
+
+			if tc.wantFail {
+				require.False(t, ok, "Assertion should have failed")
+				require.NotEmpty(t, rec.errors, "Assertion should have reported an error")
+				return
+			}
+			require.True(t, ok, "Assertion should have succeeded")
+			require.Empty(t, rec.errors, "Assertion should not have reported any error")
+		})
+	}
+}
+
+func TestWriteCallsJSON(t *testing.T) {
+	t.Parallel()
+
+	b := mock.New()
+
+	var buff bytes.Buffer
+	require.NoError(t, b.WriteCallsJSON(&buff), "WriteCallsJSON should have succeeded")
+	require.JSONEq(t, "[]", buff.String(), "No calls should be exported as an empty array")
+
+	b.InjectFault("Terminate", mock.Fault{})
+	require.Error(t, b.Terminate(context.Background(), testDistro), "Setup: Terminate should have failed")
+	_, err := b.State(context.Background(), testDistro)
+	require.NoError(t, err, "Setup: State should have succeeded")
+
+	buff.Reset()
+	require.NoError(t, b.WriteCallsJSON(&buff), "WriteCallsJSON should have succeeded")
+
+	var got []map[string]any
+	require.NoError(t, json.Unmarshal(buff.Bytes(), &got), "Exported calls should be valid JSON")
+	require.Len(t, got, 2, "Unexpected number of exported calls")
+
+	require.Equal(t, "Terminate", got[0]["method"], "Unexpected method for the first call")
+	require.Equal(t, []any{testDistro}, got[0]["args"], "Unexpected arguments for the first call")
+	require.Equal(t, mock.Error{}.Error(), got[0]["error"], "Unexpected error for the first call")
+	require.Contains(t, got[0], "time", "Exported calls should have a timestamp")
+	require.Contains(t, got[0], "duration", "Exported calls should have a duration")
+
+	require.Equal(t, "State", got[1]["method"], "Unexpected method for the second call")
+	require.NotContains(t, got[1], "error", "Successful calls should not have an error")
+}
+
+// recordingT is a mock.TestingT that records the errors reported to it.
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
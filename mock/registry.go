@@ -1,13 +1,14 @@
 package mock
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"path/filepath"
 	"sync"
 
 	"github.com/ubuntu/decorate"
-	"github.com/ubuntu/gowsl/internal/backend"
+	"github.com/ubuntu/gowsl/backend"
 	"github.com/ubuntu/gowsl/mock/internal/distrostate"
 )
 
@@ -21,6 +22,7 @@ type RegistryKey struct {
 	Data     map[string]any
 
 	state *distrostate.DistroState
+	root  string // Root filesystem of the distro in the host, if sandboxed
 
 	mu sync.RWMutex
 }
@@ -32,11 +34,12 @@ const (
 // OpenLxssRegistry opens a registry key at the chosen path subpath of the Lxss key.
 //
 // This implementation is a mock used for testing.
-func (b Backend) OpenLxssRegistry(path string) (r backend.RegistryKey, err error) {
+func (b *Backend) OpenLxssRegistry(ctx context.Context, path string) (r backend.RegistryKey, err error) {
+	defer b.record("OpenLxssRegistry", path)(&err)
 	defer decorate.OnError(&err, "registry: could not open %s", filepath.Join("HKEY_CURRENT_USER", lxssPath, path))
 
-	if b.OpenLxssKeyError {
-		return nil, Error{}
+	if _, err := b.fault(ctx, "OpenLxssRegistry"); err != nil {
+		return nil, err
 	}
 
 	b.lxssRootKey.mu.RLock()
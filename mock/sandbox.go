@@ -0,0 +1,78 @@
+package mock
+
+// This file contains the root filesystems of the distros, when the back-end is sandboxed.
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/internal/rootfs"
+)
+
+// WithSandbox is an optional parameter for New that gives each registered distro its own root
+// directory under dir, with the contents of the rootfs tarball it was registered from. Commands
+// run by the default handlers, as well as those that no handler matches, then run inside that
+// directory rather than on the host:
+//
+//   - On Linux, they are chrooted into it, in a user namespace so that no privileges are needed.
+//     If the rootfs has no /bin/sh, the host's /usr, /bin, /lib and so on are mounted into it.
+//   - On Windows, they start in it, but absolute paths still refer to the host.
+//
+// Use RootDir to inspect the files of a distro from the host. Directories are removed when
+// their distro is unregistered. Without a sandbox, the default handlers that access the files
+// of distros, such as the ones behind gowsl.Distro.CopyTo and gowsl.Distro.FS, fail instead of
+// touching the host's files.
+func WithSandbox(dir string) Option {
+	return func(o *options) {
+		o.sandbox = dir
+	}
+}
+
+// RootDir returns the directory in the host with the root filesystem of the distro.
+// It fails if the back-end was not created WithSandbox.
+func (b *Backend) RootDir(distroName string) (dir string, err error) {
+	defer decorate.OnError(&err, "could not get the root directory of %q", distroName)
+
+	if b.sandbox == "" {
+		return "", errors.New("the mock back-end is not sandboxed")
+	}
+
+	b.lxssRootKey.mu.RLock()
+	defer b.lxssRootKey.mu.RUnlock()
+
+	_, key := b.findDistroKey(distroName)
+	if key == nil {
+		return "", ErrNotExist
+	}
+
+	return key.root, nil
+}
+
+// createRoot creates the root directory for a new distro, and extracts the tarball into it. It
+// returns an empty path if the back-end is not sandboxed. Empty tarballs make for empty roots.
+func (b *Backend) createRoot(GUID, tarball string) (root string, err error) {
+	if b.sandbox == "" {
+		return "", nil
+	}
+
+	defer decorate.OnError(&err, "could not create root filesystem")
+
+	root = filepath.Join(b.sandbox, strings.Trim(GUID, "{}"))
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return "", err
+	}
+
+	if tarball == "" {
+		return root, nil
+	}
+
+	if err := rootfs.Extract(root, tarball); err != nil {
+		_ = os.RemoveAll(root)
+		return "", err
+	}
+
+	return root, nil
+}
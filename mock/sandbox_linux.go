@@ -0,0 +1,20 @@
+package mock
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/ubuntu/gowsl/internal/rootfs"
+)
+
+// sandboxCommand returns a command that runs the shell command line chrooted into root.
+func sandboxCommand(ctx context.Context, root, command string) *exec.Cmd {
+	cmd := rootfs.Command(ctx, root, command)
+	cmd.Env = []string{
+		"HOME=/root",
+		"LANG=C.UTF-8",
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	}
+
+	return cmd
+}
@@ -0,0 +1,202 @@
+package mock_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/mock"
+)
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		gzipped bool
+		empty   bool
+	}{
+		"Success with a tarball":         {},
+		"Success with a gzipped tarball": {gzipped: true},
+		"Success with an empty tarball":  {empty: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tarball := filepath.Join(t.TempDir(), "rootfs.tar")
+			if tc.empty {
+				require.NoError(t, os.WriteFile(tarball, nil, 0600), "Setup: could not write empty tarball")
+			} else {
+				writeRootfs(t, tarball, tc.gzipped)
+			}
+
+			b := mock.New(mock.WithSandbox(t.TempDir()))
+			require.NoError(t, b.WslRegisterDistribution(context.Background(), testDistro, tarball), "WslRegisterDistribution should have succeeded")
+
+			root, err := b.RootDir(testDistro)
+			require.NoError(t, err, "RootDir should have succeeded")
+			require.DirExists(t, root, "The root directory should exist")
+
+			if !tc.empty {
+				requireFileContents(t, filepath.Join(root, "etc", "hello"), "Hello from the distro!\n", "Files should have been extracted")
+				requireFileContents(t, filepath.Join(root, "evil"), "I tried to escape\n", "Paths escaping the root should be kept inside of it")
+				require.NoFileExists(t, filepath.Join(filepath.Dir(filepath.Dir(root)), "evil"), "Paths escaping the root should not be extracted outside of it")
+
+				target, err := os.Readlink(filepath.Join(root, "etc", "greeting"))
+				require.NoError(t, err, "Symlinks should have been extracted")
+				require.Equal(t, "hello", target, "Unexpected symlink target")
+			}
+
+			if runtime.GOOS == "linux" {
+				stdout, _, exitCode, err := launch(t, b, `pwd && mkdir -p /etc && echo 'Written!' > /etc/new && cat /etc/hello 2>/dev/null`, "")
+				require.NoError(t, err, "WslLaunch should have succeeded")
+
+				want := "/\n"
+				if !tc.empty {
+					want = "/root\nHello from the distro!\n"
+				}
+				require.Equal(t, want, stdout, "Commands should run inside the distro's root")
+				if tc.empty {
+					require.Equal(t, 1, exitCode, "The file should not exist in an empty distro")
+				}
+				requireFileContents(t, filepath.Join(root, "etc", "new"), "Written!\n", "Commands should write inside the distro's root")
+			}
+
+			require.NoError(t, b.WslUnregisterDistribution(context.Background(), testDistro), "WslUnregisterDistribution should have succeeded")
+			require.NoDirExists(t, root, "The root directory should be removed along with the distro")
+		})
+	}
+}
+
+func TestSandboxErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.tar.gz")
+	require.NoError(t, os.WriteFile(corrupt, []byte{0x1f, 0x8b, 0, 0}, 0600), "Setup: could not write corrupt tarball")
+
+	testCases := map[string]struct {
+		tarball string
+	}{
+		"Error when the tarball does not exist": {tarball: filepath.Join(dir, "not-a-file.tar.gz")},
+		"Error when the tarball is corrupt":     {tarball: corrupt},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b := mock.New(mock.WithSandbox(t.TempDir()))
+			require.Error(t, b.WslRegisterDistribution(context.Background(), testDistro, tc.tarball), "WslRegisterDistribution should have failed")
+			require.Empty(t, b.Snapshot().Distros, "No distro should have been registered")
+		})
+	}
+
+	t.Run("Error accessing the files of a non-sandboxed back-end", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		b := newBackendWithDistro(t)
+
+		for _, command := range []string{"cat > " + filepath.Join(dir, "file"), "tar -x -f - -C " + dir} {
+			_, stderr, exitCode, err := launch(t, b, command, "Hello!\n")
+			require.NoError(t, err, "WslLaunch should have succeeded")
+			require.NotEqual(t, 0, exitCode, "%q should have failed", command)
+			require.Contains(t, stderr, "WithSandbox", "The error should explain that a sandbox is needed")
+		}
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err, "Setup: could not read directory")
+		require.Empty(t, entries, "Nothing should have been written into the host's filesystem")
+	})
+
+	t.Run("Error getting the root of a non-sandboxed back-end", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBackendWithDistro(t)
+		_, err := b.RootDir(testDistro)
+		require.Error(t, err, "RootDir should have failed")
+	})
+}
+
+func TestSandboxRegisterConcurrently(t *testing.T) {
+	t.Parallel()
+
+	tarball := filepath.Join(t.TempDir(), "rootfs.tar")
+	writeRootfs(t, tarball, false)
+
+	sandbox := t.TempDir()
+	b := mock.New(mock.WithSandbox(sandbox))
+
+	const n = 8
+	errs := make(chan error, n)
+	for range n {
+		go func() { errs <- b.WslRegisterDistribution(context.Background(), testDistro, tarball) }()
+	}
+
+	var succeeded int
+	for range n {
+		if <-errs == nil {
+			succeeded++
+		}
+	}
+	require.Equal(t, 1, succeeded, "Only one registration should have succeeded")
+
+	entries, err := os.ReadDir(sandbox)
+	require.NoError(t, err, "Setup: could not read the sandbox")
+	require.Len(t, entries, 1, "The roots of the failed registrations should have been removed")
+}
+
+// writeRootfs writes a tarball with a small root filesystem.
+func writeRootfs(t *testing.T, path string, gzipped bool) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err, "Setup: could not create tarball")
+	defer f.Close()
+
+	var w io.Writer = f
+	if gzipped {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	entries := []struct {
+		hdr      tar.Header
+		contents string
+	}{
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0755}},
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./etc/", Mode: 0755}},
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./root/", Mode: 0700}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./etc/hello", Mode: 0644}, contents: "Hello from the distro!\n"},
+		{hdr: tar.Header{Typeflag: tar.TypeSymlink, Name: "./etc/greeting", Linkname: "hello"}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "../../evil", Mode: 0644}, contents: "I tried to escape\n"},
+	}
+
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.contents))
+		require.NoError(t, tw.WriteHeader(&e.hdr), "Setup: could not write tar header")
+		_, err := tw.Write([]byte(e.contents))
+		require.NoError(t, err, "Setup: could not write tar contents")
+	}
+}
+
+// requireFileContents checks that the file exists and has the expected contents.
+func requireFileContents(t *testing.T, path, want, msg string) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err, "%s: could not read %s", msg, path)
+	require.Equal(t, want, string(got), msg)
+}
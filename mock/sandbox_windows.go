@@ -0,0 +1,16 @@
+package mock
+
+import (
+	"context"
+	"os/exec"
+)
+
+// sandboxCommand returns a command that runs the cmd.exe command line in root.
+// There is no chroot on Windows, so absolute paths still refer to the host.
+func sandboxCommand(ctx context.Context, root, command string) *exec.Cmd {
+	//nolint:gosec // Running arbitrary commands is the whole point of the mock.
+	cmd := exec.CommandContext(ctx, "cmd.exe", "/c", command)
+	cmd.Dir = root
+
+	return cmd
+}
@@ -0,0 +1,226 @@
+package mock
+
+// This file contains the saving and loading of the state of the mock back-end.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/internal/flags"
+	"github.com/ubuntu/gowsl/mock/internal/distrostate"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture describes the state of a mock back-end: its registered distros, their
+// configuration and whether they are running. Obtain it with Backend.Snapshot, and
+// create a back-end from it with Load. It can be serialised as YAML or JSON:
+//
+//	defaultDistro: Ubuntu
+//	distros:
+//	  - name: Ubuntu
+//	    defaultUid: 1000
+//	    running: true
+//	  - name: Debian
+//	    flags: 0x7
+//	    env:
+//	      LANG: C.UTF-8
+type Fixture struct {
+	DefaultDistro string          `json:"defaultDistro,omitempty" yaml:"defaultDistro,omitempty"` // Name of the default distro
+	Distros       []DistroFixture `json:"distros"                 yaml:"distros"`
+}
+
+// DistroFixture describes a distro registered in a mock back-end. Omitted fields take the
+// same values as in a newly registered distro.
+type DistroFixture struct {
+	Name       string            `json:"name"               yaml:"name"`
+	GUID       string            `json:"guid,omitempty"     yaml:"guid,omitempty"`     // Random if omitted
+	Version    uint8             `json:"version,omitempty"  yaml:"version,omitempty"`  // Filesystem version, 2 if omitted
+	DefaultUID uint32            `json:"defaultUid"         yaml:"defaultUid"`         // UID of the default user
+	Flags      *uint32           `json:"flags,omitempty"    yaml:"flags,omitempty"`    // Distro flags, 0xf if omitted. Bit 0x8 is set for WSL 2
+	Env        map[string]string `json:"env,omitempty"      yaml:"env,omitempty"`      // Default environment variables
+	Running    bool              `json:"running,omitempty"  yaml:"running,omitempty"`  // Whether the distro is running
+	Registry   map[string]string `json:"registry,omitempty" yaml:"registry,omitempty"` // Other fields in the distro's registry key
+}
+
+// String returns the fixture as YAML, which is handy to dump the state of the mock when a test fails:
+//
+//	t.Logf("Mock state:\n%s", b.Snapshot())
+func (f Fixture) String() string {
+	out, err := yaml.Marshal(f)
+	if err != nil {
+		return fmt.Sprintf("could not marshal fixture: %v", err)
+	}
+	return string(out)
+}
+
+// distroKeyFields are the fields of a distro's registry key that are part of DistroFixture.
+var distroKeyFields = []string{"DistributionName", "Version", "DefaultUid", "Flags", "DefaultEnvironment"}
+
+// Snapshot returns the current state of the back-end. Distros are sorted by name.
+func (b *Backend) Snapshot() Fixture {
+	b.lxssRootKey.mu.RLock()
+	defer b.lxssRootKey.mu.RUnlock()
+
+	f := Fixture{Distros: []DistroFixture{}}
+	defaultGUID, _ := b.lxssRootKey.Data["DefaultDistribution"].(string)
+
+	for GUID, key := range b.lxssRootKey.children {
+		if _, err := uuid.Parse(GUID); err != nil {
+			continue // Not a distro
+		}
+
+		key.mu.RLock()
+		//nolint:forcetypeassert // We're the only ones with access to these fields.
+		d := DistroFixture{
+			Name:       key.Data["DistributionName"].(string),
+			GUID:       GUID,
+			Version:    key.Data["Version"].(uint8),
+			DefaultUID: key.Data["DefaultUid"].(uint32),
+			Flags:      new(uint32),
+			Env:        parseEnvironment(key.Data["DefaultEnvironment"].([]string)),
+			Running:    key.state.IsRunning(),
+		}
+		*d.Flags = uint32(key.Data["Flags"].(flags.WslFlags)) //nolint:forcetypeassert // We're the only ones with access to this field.
+
+		for name, value := range key.Data {
+			s, ok := value.(string)
+			if !ok || slices.Contains(distroKeyFields, name) {
+				continue
+			}
+			if d.Registry == nil {
+				d.Registry = make(map[string]string)
+			}
+			d.Registry[name] = s
+		}
+		key.mu.RUnlock()
+
+		if GUID == defaultGUID {
+			f.DefaultDistro = d.Name
+		}
+
+		f.Distros = append(f.Distros, d)
+	}
+
+	sort.Slice(f.Distros, func(i, j int) bool { return f.Distros[i].Name < f.Distros[j].Name })
+
+	return f
+}
+
+// Load creates a new mock back-end from a fixture serialised as YAML or JSON. See Fixture for
+// the format. Options are the same as in New.
+func Load(r io.Reader, opts ...Option) (b *Backend, err error) {
+	defer decorate.OnError(&err, "could not load mock fixture")
+
+	var f Fixture
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	b = New(opts...)
+
+	defaultGUID := ""
+	for _, d := range f.Distros {
+		GUID, key, err := b.newDistroKey(d)
+		if err != nil {
+			return nil, fmt.Errorf("distro %q: %v", d.Name, err)
+		}
+
+		if strings.EqualFold(d.Name, f.DefaultDistro) {
+			defaultGUID = GUID
+		}
+
+		b.lxssRootKey.children[GUID] = key
+
+		if d.Running {
+			if err := key.state.Touch(); err != nil {
+				return nil, fmt.Errorf("distro %q: %v", d.Name, err)
+			}
+		}
+	}
+
+	if f.DefaultDistro != "" && defaultGUID == "" {
+		return nil, fmt.Errorf("default distro %q is not registered", f.DefaultDistro)
+	}
+	b.lxssRootKey.Data["DefaultDistribution"] = defaultGUID
+
+	return b, nil
+}
+
+// newDistroKey creates the registry key for a distro described in a fixture.
+func (b *Backend) newDistroKey(d DistroFixture) (GUID string, key *RegistryKey, err error) {
+	if err := validDistroName(d.Name); err != nil {
+		return "", nil, err
+	}
+
+	if _, key := b.findDistroKey(d.Name); key != nil {
+		return "", nil, errors.New("distro is registered twice")
+	}
+
+	u, err := uuid.NewRandom()
+	if d.GUID != "" {
+		u, err = uuid.Parse(d.GUID)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid GUID: %v", err)
+	}
+
+	GUID = fmt.Sprintf("{%s}", u.String())
+	if _, ok := b.lxssRootKey.children[GUID]; ok {
+		return "", nil, fmt.Errorf("GUID %s is used twice", GUID)
+	}
+
+	version := d.Version
+	if version == 0 {
+		version = 2
+	}
+
+	wslFlags := flags.WslFlags(0xf)
+	if d.Flags != nil {
+		wslFlags = flags.WslFlags(*d.Flags)
+	}
+
+	env := slices.Clone(defaultEnvironment)
+	if len(d.Env) != 0 {
+		env = make([]string, 0, len(d.Env))
+		for k, v := range d.Env {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+		slices.Sort(env)
+	}
+
+	data := map[string]any{
+		"DistributionName":   d.Name,
+		"Flags":              wslFlags,
+		"Version":            version,
+		"DefaultUid":         d.DefaultUID,
+		"DefaultEnvironment": env,
+	}
+
+	for name, value := range d.Registry {
+		if _, ok := data[name]; ok {
+			return "", nil, fmt.Errorf("registry field %q must be set via its own fixture field", name)
+		}
+		data[name] = value
+	}
+
+	root, err := b.createRoot(GUID, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return GUID, &RegistryKey{
+		path:  filepath.Join("HKEY_CURRENT_USER", lxssPath, GUID),
+		Data:  data,
+		state: distrostate.New(b.clock, b.idleTimeout),
+		root:  root,
+	}, nil
+}
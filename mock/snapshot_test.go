@@ -0,0 +1,151 @@
+package mock_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/gowsl/internal/flags"
+	"github.com/ubuntu/gowsl/internal/state"
+	"github.com/ubuntu/gowsl/mock"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open(filepath.Join("testdata", "fixture.yaml"))
+	require.NoError(t, err, "Setup: could not open fixture")
+	defer f.Close()
+
+	b, err := mock.Load(f)
+	require.NoError(t, err, "Load should have succeeded")
+
+	// Configuration
+	var version uint8
+	var uid uint32
+	var wslFlags flags.WslFlags
+	var env map[string]string
+
+	err = b.WslGetDistributionConfiguration(context.Background(), "Ubuntu", &version, &uid, &wslFlags, &env)
+	require.NoError(t, err, "WslGetDistributionConfiguration should have succeeded")
+	require.Equal(t, uint8(2), version, "Omitted versions should default to WSL 2")
+	require.Equal(t, uint32(1000), uid, "Unexpected default UID")
+	require.Equal(t, flags.WslFlags(0xf), wslFlags, "Omitted flags should take the default value")
+	require.Equal(t, "en_US.UTF-8", env["LANG"], "Omitted environments should take the default value")
+
+	err = b.WslGetDistributionConfiguration(context.Background(), "Debian", &version, &uid, &wslFlags, &env)
+	require.NoError(t, err, "WslGetDistributionConfiguration should have succeeded")
+	require.Equal(t, flags.WslFlags(0x7), wslFlags, "Unexpected flags")
+	require.Equal(t, map[string]string{"LANG": "C.UTF-8", "PATH": "/usr/bin:/bin"}, env, "Unexpected environment")
+
+	err = b.WslGetDistributionConfiguration(context.Background(), "Legacy", &version, &uid, &wslFlags, &env)
+	require.NoError(t, err, "WslGetDistributionConfiguration should have succeeded")
+	require.Equal(t, uint8(1), version, "Unexpected version")
+	require.Equal(t, uint8(1), flags.Unpack(wslFlags).UndocumentedWSLVersion, "Legacy should be a WSL 1 distro")
+
+	// State
+	s, err := b.State(context.Background(), "Ubuntu")
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, state.Running, s, "Ubuntu should be running")
+
+	s, err = b.State(context.Background(), "Debian")
+	require.NoError(t, err, "State should have succeeded")
+	require.Equal(t, state.Stopped, s, "Debian should be stopped")
+
+	// Registry
+	k, err := b.OpenLxssRegistry(context.Background(), ".")
+	require.NoError(t, err, "OpenLxssRegistry should have succeeded")
+	defaultGUID, err := k.Field("DefaultDistribution")
+	require.NoError(t, err, "Could not read the default distro")
+	k.Close()
+	require.Equal(t, "{8a0ef2e9-2f0a-4b14-a0b2-6f6b1c2d3e4f}", defaultGUID, "Unexpected default distro")
+
+	k, err = b.OpenLxssRegistry(context.Background(), defaultGUID)
+	require.NoError(t, err, "OpenLxssRegistry should have succeeded")
+	family, err := k.Field("PackageFamilyName")
+	require.NoError(t, err, "Could not read extra registry fields")
+	k.Close()
+	require.Equal(t, "CanonicalGroupLimited.Ubuntu_79rhkp1fndgsc", family, "Unexpected registry field")
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	b := mock.New()
+	require.NoError(t, b.WslRegisterDistribution(context.Background(), "First", "rootfs.tar.gz"), "Setup: could not register distro")
+	require.NoError(t, b.WslRegisterDistribution(context.Background(), "Second", "rootfs.tar.gz"), "Setup: could not register distro")
+	require.NoError(t, b.WslConfigureDistribution(context.Background(), "Second", 1000, flags.WslFlags(0x5)), "Setup: could not configure distro")
+	require.NoError(t, b.SetAsDefault(context.Background(), "Second"), "Setup: could not set default distro")
+	_, err := b.WslLaunchInteractive(context.Background(), "First", "exit 0", false)
+	require.NoError(t, err, "Setup: could not launch command")
+
+	snapshot := b.Snapshot()
+	require.Equal(t, "Second", snapshot.DefaultDistro, "Unexpected default distro")
+	require.Len(t, snapshot.Distros, 2, "Unexpected number of distros")
+	require.Equal(t, "First", snapshot.Distros[0].Name, "Distros should be sorted by name")
+	require.True(t, snapshot.Distros[0].Running, "First should be running")
+	require.Equal(t, uint32(1000), snapshot.Distros[1].DefaultUID, "Unexpected default UID")
+	require.Equal(t, uint32(0x5), *snapshot.Distros[1].Flags, "Unexpected flags")
+
+	testCases := map[string]struct {
+		marshal func(any) ([]byte, error)
+	}{
+		"Round trip via YAML": {marshal: yaml.Marshal},
+		"Round trip via JSON": {marshal: json.Marshal},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := tc.marshal(snapshot)
+			require.NoError(t, err, "Setup: could not marshal snapshot")
+
+			loaded, err := mock.Load(bytes.NewReader(out))
+			require.NoError(t, err, "Load should have succeeded")
+			require.Equal(t, snapshot, loaded.Snapshot(), "Loading a snapshot should restore the same state")
+		})
+	}
+
+	require.Contains(t, snapshot.String(), "defaultDistro: Second", "Fixtures should be printed as YAML")
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		fixture string
+	}{
+		"Error when the fixture is not valid YAML":              {fixture: "distros: ["},
+		"Error when the fixture has unknown fields":             {fixture: "distros: [{name: Ubuntu, color: orange}]"},
+		"Error when a distro name is invalid":                   {fixture: "distros: [{name: Not valid}]"},
+		"Error when a distro is registered twice":               {fixture: "distros: [{name: Ubuntu}, {name: ubuntu}]"},
+		"Error when a GUID is invalid":                          {fixture: "distros: [{name: Ubuntu, guid: nope}]"},
+		"Error when a GUID is used twice":                       {fixture: "distros: [{name: A, guid: 8a0ef2e9-2f0a-4b14-a0b2-6f6b1c2d3e4f}, {name: B, guid: 8a0ef2e9-2f0a-4b14-a0b2-6f6b1c2d3e4f}]"},
+		"Error when the default distro is not registered":       {fixture: "defaultDistro: Ubuntu\ndistros: [{name: Debian}]"},
+		"Error when a registry field overrides a fixture field": {fixture: "distros: [{name: Ubuntu, registry: {DistributionName: Debian}}]"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := mock.Load(strings.NewReader(tc.fixture))
+			require.Error(t, err, "Load should have failed")
+		})
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	t.Parallel()
+
+	b, err := mock.Load(strings.NewReader(""))
+	require.NoError(t, err, "Load should accept an empty fixture")
+	require.Empty(t, b.Snapshot().Distros, "An empty fixture should have no distros")
+}
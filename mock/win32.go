@@ -3,29 +3,43 @@ package mock
 // This file contains mocks for Win32 API definitions and imports.
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/backend"
 	"github.com/ubuntu/gowsl/internal/flags"
+	"github.com/ubuntu/gowsl/internal/state"
 	"github.com/ubuntu/gowsl/mock/internal/distrostate"
 )
 
 // When something fails Windows-side, it returns (1<<32 - 1).
 const windowsError = math.MaxUint32
 
+// defaultEnvironment is the environment of newly registered distros.
+var defaultEnvironment = []string{
+	"HOSTTYPE=x86_64",
+	"LANG=en_US.UTF-8",
+	"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/usr/games:/usr/local/games",
+	"TERM=xterm-256color",
+}
+
 // WslConfigureDistribution mocks the WslConfigureDistribution call to the Win32 API.
-func (b *Backend) WslConfigureDistribution(distributionName string, defaultUID uint32, wslDistributionFlags flags.WslFlags) (err error) {
+func (b *Backend) WslConfigureDistribution(ctx context.Context, distributionName string, defaultUID uint32, wslDistributionFlags flags.WslFlags) (err error) {
+	defer b.record("WslConfigureDistribution", distributionName, defaultUID, wslDistributionFlags)(&err)
 	defer decorate.OnError(&err, "WslConfigureDistribution")
 
-	if b.WslConfigureDistributionError {
-		return Error{}
+	if _, err := b.fault(ctx, "WslConfigureDistribution"); err != nil {
+		return err
 	}
 
 	if err := validDistroName(distributionName); err != nil {
@@ -37,7 +51,7 @@ func (b *Backend) WslConfigureDistribution(distributionName string, defaultUID u
 	b.lxssRootKey.mu.RUnlock()
 
 	if key == nil {
-		return errors.New("failed syscall: distro not registered")
+		return fmt.Errorf("failed syscall: %w", ErrNotExist)
 	}
 
 	key.mu.Lock()
@@ -50,15 +64,16 @@ func (b *Backend) WslConfigureDistribution(distributionName string, defaultUID u
 }
 
 // WslGetDistributionConfiguration mocks the WslGetDistributionConfiguration call to the Win32 API.
-func (b *Backend) WslGetDistributionConfiguration(distributionName string,
+func (b *Backend) WslGetDistributionConfiguration(ctx context.Context, distributionName string,
 	distributionVersion *uint8,
 	defaultUID *uint32,
 	wslDistributionFlags *flags.WslFlags,
 	defaultEnvironmentVariables *map[string]string) (err error) {
+	defer b.record("WslGetDistributionConfiguration", distributionName)(&err)
 	defer decorate.OnError(&err, "WslGetDistributionConfiguration")
 
-	if b.WslGetDistributionConfigurationError {
-		return Error{}
+	if _, err := b.fault(ctx, "WslGetDistributionConfiguration"); err != nil {
+		return err
 	}
 
 	if err := validDistroName(distributionName); err != nil {
@@ -70,7 +85,7 @@ func (b *Backend) WslGetDistributionConfiguration(distributionName string,
 
 	_, key := b.findDistroKey(distributionName)
 	if key == nil {
-		return errors.New("failed syscall: not registered")
+		return fmt.Errorf("failed syscall: %w", ErrNotExist)
 	}
 
 	key.mu.RLock()
@@ -81,27 +96,24 @@ func (b *Backend) WslGetDistributionConfiguration(distributionName string,
 	*defaultUID = key.Data["DefaultUid"].(uint32)              //nolint: forcetypeassert // we're the only ones with access to these fields.
 	*wslDistributionFlags = key.Data["Flags"].(flags.WslFlags) //nolint: forcetypeassert // we're the only ones with access to these fields.
 
-	*defaultEnvironmentVariables = map[string]string{
-		"HOSTTYPE": "x86_64",
-		"LANG":     "en_US.UTF-8",
-		"PATH":     "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin:/usr/games:/usr/local/games",
-		"TERM":     "xterm-256color",
-	}
+	*defaultEnvironmentVariables = parseEnvironment(key.Data["DefaultEnvironment"].([]string)) //nolint: forcetypeassert // we're the only ones with access to these fields.
 
 	return nil
 }
 
 // WslLaunch mocks the WslLaunch call to the Win32 API.
-func (b *Backend) WslLaunch(distributionName string,
+func (b *Backend) WslLaunch(ctx context.Context,
+	distributionName string,
 	command string,
 	useCWD bool,
 	stdin *os.File,
 	stdout *os.File,
 	stderr *os.File) (process *os.Process, err error) {
+	defer b.record("WslLaunch", distributionName, command, useCWD)(&err)
 	defer decorate.OnError(&err, "WslLaunch")
 
-	if b.WslLaunchError {
-		return nil, Error{}
+	if _, err := b.fault(ctx, "WslLaunch"); err != nil {
+		return nil, err
 	}
 
 	if err := validWin32String(distributionName); err != nil {
@@ -117,7 +129,7 @@ func (b *Backend) WslLaunch(distributionName string,
 	_, distroKey := b.findDistroKey(distributionName)
 	if distroKey == nil {
 		b.lxssRootKey.mu.RUnlock()
-		return nil, errors.New("Failed syscall: distro does not exist")
+		return nil, fmt.Errorf("failed syscall: %w", ErrNotExist)
 	}
 
 	b.lxssRootKey.mu.RUnlock()
@@ -132,7 +144,12 @@ func (b *Backend) WslLaunch(distributionName string,
 		panic("Stderr must be a pipe")
 	}
 
-	p, err := newMockedCommand(command).start(stdin, stdout, stderr)
+	handler, err := b.findHandler(command)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := launch(newCommand(distributionName, distroKey.root, command, useCWD, false), handler, stdin, stdout, stderr)
 	if err != nil {
 		return nil, err
 	}
@@ -146,11 +163,14 @@ func (b *Backend) WslLaunch(distributionName string,
 }
 
 // WslLaunchInteractive mocks the WslLaunchInteractive call to the Win32 API.
-func (b *Backend) WslLaunchInteractive(distributionName string, command string, useCurrentWorkingDirectory bool) (exitCode uint32, err error) {
+func (b *Backend) WslLaunchInteractive(ctx context.Context, distributionName string, command string, useCurrentWorkingDirectory bool) (exitCode uint32, err error) {
+	defer b.record("WslLaunchInteractive", distributionName, command, useCurrentWorkingDirectory)(&err)
 	defer decorate.OnError(&err, "WslLaunchInteractive")
 
-	if b.WslLaunchInteractiveError {
-		return windowsError, Error{}
+	if f, err := b.fault(ctx, "WslLaunchInteractive"); err != nil {
+		return windowsError, err
+	} else if f != nil && f.ExitCode != 0 {
+		return f.ExitCode, nil
 	}
 
 	if err := validWin32String(distributionName); err != nil {
@@ -166,7 +186,7 @@ func (b *Backend) WslLaunchInteractive(distributionName string, command string,
 	_, distroKey := b.findDistroKey(distributionName)
 	if distroKey == nil {
 		b.lxssRootKey.mu.RUnlock()
-		return windowsError, errors.New("failed syscall: distro not found")
+		return windowsError, fmt.Errorf("failed syscall: %w", ErrNotExist)
 	}
 
 	b.lxssRootKey.mu.RUnlock()
@@ -175,46 +195,68 @@ func (b *Backend) WslLaunchInteractive(distributionName string, command string,
 		return windowsError, fmt.Errorf("failed syscall: %v", err)
 	}
 
-	switch command {
-	case "":
+	handler, ok := b.customHandler(command)
+	if !ok && command == "" {
+		// The default interactive shell stays open until the distro is terminated.
 		s, err := distroKey.state.NewShell()
 		if err != nil {
 			return windowsError, err
 		}
-		exit := s.Wait()
-
-		return exit, nil
-	case "exit 0":
-		return 0, nil
-	case "exit 42":
-		return 42, nil
-	case "[ `pwd` = /root ]":
-		if useCurrentWorkingDirectory {
-			// We are wherever wsl.exe was called from
-			return 1, nil
+
+		// Like the real one, the call is abandoned when the context is done.
+		var code uint32
+		if err := abandonable(ctx, func() { code = s.Wait() }); err != nil {
+			return windowsError, err
 		}
-		// We are home (hence /root)
-		return 0, nil
-	case "[ `pwd` != /root ]":
-		if useCurrentWorkingDirectory {
-			// We are wherever wsl.exe was called from
-			return 0, nil
+		return code, nil
+	} else if !ok {
+		if handler, err = b.defaultHandler(command); err != nil {
+			return windowsError, err
 		}
-		// We are home (hence /root)
-		return 1, nil
-	default:
-		panic(fmt.Sprintf("WslLaunchInteractive command not supported: %q", command))
 	}
+
+	// The distro is kept running while the command runs, and becomes idle once it exits.
+	s, err := distroKey.state.NewShell()
+	if err != nil {
+		return windowsError, err
+	}
+
+	var code int
+	err = abandonable(ctx, func() {
+		defer s.Close()
+		cmdCtx := context.WithValue(context.Background(), commandKey, newCommand(distributionName, distroKey.root, command, useCurrentWorkingDirectory, true))
+		code = handler(cmdCtx, os.Stdin, os.Stdout, os.Stderr)
+	})
+	if err != nil {
+		return windowsError, err
+	}
+
+	//nolint:gosec // Negative exit codes wrap around, as they do on Windows.
+	return uint32(code), nil
 }
 
 // WslRegisterDistribution mocks the WslRegisterDistribution call to the Win32 API.
-func (b *Backend) WslRegisterDistribution(distributionName string, tarGzFilename string) (err error) {
+func (b *Backend) WslRegisterDistribution(ctx context.Context, distributionName string, tarGzFilename string) (err error) {
+	defer b.record("WslRegisterDistribution", distributionName, tarGzFilename)(&err)
 	defer decorate.OnError(&err, "WslRegisterDistribution")
 
-	if b.WslRegisterDistributionError {
-		return Error{}
+	if _, err := b.fault(ctx, "WslRegisterDistribution"); err != nil {
+		return err
 	}
 
+	err = b.registerDistribution(ctx, distributionName, tarGzFilename, b.durations.Register)
+	if err != nil && ctx.Err() != nil {
+		// Unlike wsl.exe --import, the real call cannot be cancelled: it is abandoned instead,
+		// and the half-registered distro is removed.
+		return fmt.Errorf("%w: %w", backend.ErrAbandoned, err)
+	}
+	return err
+}
+
+// registerDistribution registers a new distro. It is shared by WslRegisterDistribution and Import.
+// The distro is reported as Installing for the given duration, and removed if the context is
+// cancelled in the meantime.
+func (b *Backend) registerDistribution(ctx context.Context, distributionName string, tarGzFilename string, duration time.Duration) error {
 	if err := validDistroName(distributionName); err != nil {
 		return err
 	}
@@ -223,10 +265,11 @@ func (b *Backend) WslRegisterDistribution(distributionName string, tarGzFilename
 		return err
 	}
 
-	b.lxssRootKey.mu.Lock()
-	defer b.lxssRootKey.mu.Unlock()
+	b.lxssRootKey.mu.RLock()
+	_, key := b.findDistroKey(distributionName)
+	b.lxssRootKey.mu.RUnlock()
 
-	if _, key := b.findDistroKey(distributionName); key != nil {
+	if key != nil {
 		return errors.New("failed syscall: distro already exists")
 	}
 
@@ -237,16 +280,35 @@ func (b *Backend) WslRegisterDistribution(distributionName string, tarGzFilename
 
 	guidStr := fmt.Sprintf("{%s}", GUID.String())
 
-	b.lxssRootKey.children[guidStr] = &RegistryKey{
+	// Extracting the tarball can take a while, so we do it before locking the registry.
+	root, err := b.createRoot(guidStr, tarGzFilename)
+	if err != nil {
+		return err
+	}
+
+	b.lxssRootKey.mu.Lock()
+	defer b.lxssRootKey.mu.Unlock()
+
+	if _, key := b.findDistroKey(distributionName); key != nil {
+		if root != "" {
+			_ = os.RemoveAll(root)
+		}
+		return errors.New("failed syscall: distro already exists")
+	}
+
+	key = &RegistryKey{
 		path: filepath.Join("HKEY_CURRENT_USER", lxssPath, guidStr),
 		Data: map[string]any{
-			"DistributionName": distributionName,
-			"Flags":            flags.WslFlags(0xf),
-			"Version":          uint8(2),
-			"DefaultUid":       uint32(0),
+			"DistributionName":   distributionName,
+			"Flags":              flags.WslFlags(0xf),
+			"Version":            uint8(2),
+			"DefaultUid":         uint32(0),
+			"DefaultEnvironment": slices.Clone(defaultEnvironment),
 		},
-		state: distrostate.New(),
+		state: distrostate.New(b.clock, b.idleTimeout),
+		root:  root,
 	}
+	b.lxssRootKey.children[guidStr] = key
 
 	// When registering the first distro, DefaultDistribution
 	// is updated with its GUID
@@ -255,38 +317,105 @@ func (b *Backend) WslRegisterDistribution(distributionName string, tarGzFilename
 		b.lxssRootKey.Data["DefaultDistribution"] = guidStr
 	}
 
+	if duration <= 0 {
+		return nil
+	}
+
+	if err := key.state.BeginTransition(state.Installing); err != nil {
+		return err
+	}
+
+	b.lxssRootKey.mu.Unlock()
+	err = b.sleep(ctx, duration)
+	b.lxssRootKey.mu.Lock()
+
+	if err != nil {
+		// The installation was aborted.
+		_ = b.removeDistroKey(guidStr, key)
+		return err
+	}
+
+	key.state.EndTransition()
+
 	return nil
 }
 
 // WslUnregisterDistribution mocks the WslUnregisterDistribution call to the Win32 API.
-func (b *Backend) WslUnregisterDistribution(distributionName string) (err error) {
+func (b *Backend) WslUnregisterDistribution(ctx context.Context, distributionName string) (err error) {
+	defer b.record("WslUnregisterDistribution", distributionName)(&err)
 	defer decorate.OnError(&err, "WslUnregisterDistribution")
 
-	if b.WslUnregisterDistributionError {
-		return Error{}
+	if _, err := b.fault(ctx, "WslUnregisterDistribution"); err != nil {
+		return err
 	}
 
 	if err := validDistroName(distributionName); err != nil {
 		return err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	b.lxssRootKey.mu.Lock()
-	defer b.lxssRootKey.mu.Unlock()
 
 	GUID, key := b.findDistroKey(distributionName)
 	if key == nil {
-		return errors.New("failed syscall: distro not registered")
+		b.lxssRootKey.mu.Unlock()
+		return fmt.Errorf("failed syscall: %w", ErrNotExist)
+	}
+
+	d := b.durations.Unregister
+	if d <= 0 {
+		defer b.lxssRootKey.mu.Unlock()
+
+		// Distros being installed are removed by the installation itself if it is aborted.
+		if s, ok := key.state.Transition(); ok {
+			return fmt.Errorf("failed syscall: distro is %s", s)
+		}
+		return b.removeDistroKey(GUID, key)
 	}
 
-	err = key.state.MarkUninstalled()
+	if err := key.state.BeginTransition(state.Uninstalling); err != nil {
+		b.lxssRootKey.mu.Unlock()
+		return fmt.Errorf("failed syscall: %v", err)
+	}
+	b.lxssRootKey.mu.Unlock()
+
+	// Like the real one, the call is abandoned when the context is done, and the
+	// distro is unregistered in the background.
+	var removeErr error
+	err = abandonable(ctx, func() {
+		_ = b.sleep(context.Background(), d)
+
+		b.lxssRootKey.mu.Lock()
+		defer b.lxssRootKey.mu.Unlock()
+		removeErr = b.removeDistroKey(GUID, key)
+	})
+	if err != nil {
+		return err
+	}
+
+	return removeErr
+}
+
+// removeDistroKey marks the distro as uninstalled and removes its key from the registry.
+//
+// Use under the root key's write mutex.
+func (b *Backend) removeDistroKey(GUID string, key *RegistryKey) error {
+	err := key.state.MarkUninstalled()
 	delete(b.lxssRootKey.children, GUID)
 
+	if key.root != "" {
+		err = errors.Join(err, os.RemoveAll(key.root))
+	}
+
 	//  When you unregister the default distro, the one with the lowest GUID
 	// (lexicographically) is set as default. If there are none, the field is
 	// set to empty string.
 
 	if b.lxssRootKey.Data["DefaultDistribution"] != GUID {
-		return nil
+		return err
 	}
 
 	var firstGUID string
@@ -295,7 +424,7 @@ func (b *Backend) WslUnregisterDistribution(distributionName string) (err error)
 			continue // Not a distro
 		}
 
-		if strings.Compare(GUID, firstGUID) == -1 {
+		if firstGUID == "" || strings.Compare(GUID, firstGUID) == -1 {
 			firstGUID = GUID
 		}
 	}
@@ -305,6 +434,17 @@ func (b *Backend) WslUnregisterDistribution(distributionName string) (err error)
 	return err
 }
 
+// parseEnvironment parses a list of environment variables in the form KEY=VALUE,
+// as they are stored in the registry.
+func parseEnvironment(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, v := range env {
+		key, value, _ := strings.Cut(v, "=")
+		m[key] = value
+	}
+	return m
+}
+
 func validWin32String(str string) error {
 	if strings.ContainsRune(str, rune(0)) {
 		return fmt.Errorf("could not convert %q to UTF-16", str)
@@ -7,13 +7,17 @@ import (
 	"os"
 
 	"github.com/google/uuid"
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/internal/flags"
 	"github.com/ubuntu/gowsl/internal/state"
 )
 
 // Shutdown mocks the behaviour of shutting down WSL.
-func (backend *Backend) Shutdown() (err error) {
-	if backend.ShutdownError {
-		return Error{}
+func (backend *Backend) Shutdown(ctx context.Context) (err error) {
+	defer backend.record("Shutdown")(&err)
+
+	if _, err := backend.fault(ctx, "Shutdown"); err != nil {
+		return err
 	}
 
 	backend.lxssRootKey.mu.RLock()
@@ -34,9 +38,11 @@ func (backend *Backend) Shutdown() (err error) {
 }
 
 // Terminate mocks the behaviour of shutting down one WSL distro.
-func (backend *Backend) Terminate(distroName string) error {
-	if backend.TerminateError {
-		return Error{}
+func (backend *Backend) Terminate(ctx context.Context, distroName string) (err error) {
+	defer backend.record("Terminate", distroName)(&err)
+
+	if _, err := backend.fault(ctx, "Terminate"); err != nil {
+		return err
 	}
 
 	backend.lxssRootKey.mu.RLock()
@@ -51,9 +57,11 @@ func (backend *Backend) Terminate(distroName string) error {
 }
 
 // SetAsDefault mocks the behaviour of setting one distro as default.
-func (backend *Backend) SetAsDefault(distroName string) error {
-	if backend.SetAsDefaultError {
-		return Error{}
+func (backend *Backend) SetAsDefault(ctx context.Context, distroName string) (err error) {
+	defer backend.record("SetAsDefault", distroName)(&err)
+
+	if _, err := backend.fault(ctx, "SetAsDefault"); err != nil {
+		return err
 	}
 
 	if err := validDistroName(distroName); err != nil {
@@ -74,9 +82,11 @@ func (backend *Backend) SetAsDefault(distroName string) error {
 }
 
 // State returns the state of a particular distro as seen in `wsl.exe -l -v`.
-func (backend Backend) State(distributionName string) (s state.State, err error) {
-	if backend.StateError {
-		return state.Error, Error{}
+func (backend *Backend) State(ctx context.Context, distributionName string) (s state.State, err error) {
+	defer backend.record("State", distributionName)(&err)
+
+	if _, err := backend.fault(ctx, "State"); err != nil {
+		return state.Error, err
 	}
 
 	backend.lxssRootKey.mu.RLock()
@@ -87,6 +97,10 @@ func (backend Backend) State(distributionName string) (s state.State, err error)
 		return state.NotRegistered, nil
 	}
 
+	if s, ok := key.state.Transition(); ok {
+		return s, nil
+	}
+
 	if key.state.IsRunning() {
 		return state.Running, nil
 	}
@@ -94,9 +108,11 @@ func (backend Backend) State(distributionName string) (s state.State, err error)
 }
 
 // Install installs a new distro from the Windows store.
-func (backend Backend) Install(ctx context.Context, appxName string) (err error) {
-	if backend.InstallError {
-		return Error{}
+func (backend *Backend) Install(ctx context.Context, appxName string) (err error) {
+	defer backend.record("Install", appxName)(&err)
+
+	if _, err := backend.fault(ctx, "Install"); err != nil {
+		return err
 	}
 
 	select {
@@ -109,11 +125,21 @@ func (backend Backend) Install(ctx context.Context, appxName string) (err error)
 		return fmt.Errorf("could not install: %w", ErrNotExist)
 	}
 
+	if err := backend.sleep(ctx, backend.durations.Install); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // Import creates a new distro from a source root filesystem.
-func (backend *Backend) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) error {
+func (backend *Backend) Import(ctx context.Context, distributionName, sourcePath, destinationPath string) (err error) {
+	defer backend.record("Import", distributionName, sourcePath, destinationPath)(&err)
+
+	if _, err := backend.fault(ctx, "Import"); err != nil {
+		return err
+	}
+
 	out, err := os.ReadFile(sourcePath)
 	if err != nil {
 		return fmt.Errorf("import error: %v", err)
@@ -122,9 +148,61 @@ func (backend *Backend) Import(ctx context.Context, distributionName, sourcePath
 		return Error{}
 	}
 
-	if err := backend.WslRegisterDistribution(distributionName, sourcePath); err != nil {
+	if err := backend.registerDistribution(ctx, distributionName, sourcePath, backend.durations.Import); err != nil {
 		return fmt.Errorf("import error: %v", err)
 	}
 
 	return nil
 }
+
+// SetVersion mocks the behaviour of `wsl.exe --set-version <distro> <version>`. The distro
+// is stopped, and reported as Converting for the duration set via WithDurations.
+//
+// It is not part of the WSL back-end, but lets tests simulate conversions.
+func (backend *Backend) SetVersion(ctx context.Context, distroName string, version uint8) (err error) {
+	defer decorate.OnError(&err, "could not convert distro %q to WSL %d", distroName, version)
+
+	if version != 1 && version != 2 {
+		return errors.New("invalid version")
+	}
+
+	backend.lxssRootKey.mu.RLock()
+	_, key := backend.findDistroKey(distroName)
+	backend.lxssRootKey.mu.RUnlock()
+
+	if key == nil {
+		return ErrNotExist
+	}
+
+	// WSL reports the version of a distro in its flags. The Version field is the version of
+	// its filesystem, which conversions leave alone.
+	key.mu.RLock()
+	current := flags.Unpack(key.Data["Flags"].(flags.WslFlags)).UndocumentedWSLVersion //nolint: forcetypeassert // we're the only ones with access to these fields.
+	key.mu.RUnlock()
+
+	if current == version {
+		return errors.New("the distro is already at the requested version")
+	}
+
+	if err := key.state.BeginTransition(state.Converting); err != nil {
+		return err
+	}
+	defer key.state.EndTransition()
+
+	if err := backend.sleep(ctx, backend.durations.Convert); err != nil {
+		return err
+	}
+
+	key.mu.Lock()
+	defer key.mu.Unlock()
+
+	f := flags.Unpack(key.Data["Flags"].(flags.WslFlags)) //nolint: forcetypeassert // we're the only ones with access to these fields.
+	f.UndocumentedWSLVersion = version
+	packed, err := f.Pack()
+	if err != nil {
+		return err
+	}
+	key.Data["Flags"] = packed
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package gowsl
+
+// This file contains utilities to translate paths between Windows and WSL distros.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/internal/wslpath"
+)
+
+// DefaultAutomountRoot is the directory where Windows drives are mounted in a distro whose
+// /etc/wsl.conf does not specify otherwise.
+const DefaultAutomountRoot = wslpath.DefaultAutomountRoot
+
+// ToLinuxPath translates a Windows path into a path inside a distro, following the same rules as
+// `wslpath -u`, without launching any process.
+//
+// Paths in Windows drives are translated into paths under automountRoot (see (*Distro).AutomountRoot).
+// An empty automountRoot means DefaultAutomountRoot. UNC paths are only supported if they point to the
+// distro itself, via either the \\wsl.localhost or the \\wsl$ share. Relative paths are translated
+// without resolving them.
+func ToLinuxPath(distroName, automountRoot, windowsPath string) (p string, err error) {
+	defer decorate.OnError(&err, "could not translate %q into a Linux path", windowsPath)
+	return wslpath.ToLinux(distroName, automountRoot, windowsPath)
+}
+
+// ToWindowsPath translates a path inside a distro into a Windows path, following the same rules as
+// `wslpath -w`, without launching any process.
+//
+// Paths under automountRoot (see (*Distro).AutomountRoot) are translated into paths in Windows drives.
+// An empty automountRoot means DefaultAutomountRoot. Other absolute paths are translated into paths in
+// the \\wsl.localhost share. Relative paths are translated without resolving them.
+func ToWindowsPath(distroName, automountRoot, linuxPath string) (p string, err error) {
+	defer decorate.OnError(&err, "could not translate %q into a Windows path", linuxPath)
+	return wslpath.ToWindows(distroName, automountRoot, linuxPath)
+}
+
+// AutomountRoot returns the directory where Windows drives are mounted in the distro, as
+// configured in its /etc/wsl.conf. The result can be used with ToLinuxPath and ToWindowsPath.
+func (d *Distro) AutomountRoot(ctx context.Context) (root string, err error) {
+	defer decorate.OnError(&err, "could not obtain automount root of distro %s", d.name)
+
+	fsys := d.FS(ctx)
+	defer fsys.Close()
+
+	out, err := fsys.ReadFile("etc/wsl.conf")
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultAutomountRoot, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return wslpath.ParseAutomountRoot(bytes.NewReader(out))
+}
+
+// WSLPathMode is the kind of translation performed by (*Distro).WSLPath.
+type WSLPathMode int
+
+const (
+	// WSLPathToLinux translates a Windows path into a Linux path (wslpath -u).
+	WSLPathToLinux WSLPathMode = iota
+	// WSLPathToWindows translates a Linux path into a Windows path (wslpath -w).
+	WSLPathToWindows
+	// WSLPathToWindowsMixed translates a Linux path into a Windows path with forward slashes (wslpath -m).
+	WSLPathToWindowsMixed
+)
+
+// WSLPath translates a path by running the wslpath command inside the distro. It is slower than
+// ToLinuxPath and ToWindowsPath, but it is exact as the distro itself performs the translation.
+func (d *Distro) WSLPath(ctx context.Context, mode WSLPathMode, path string) (p string, err error) {
+	defer decorate.OnError(&err, "could not translate path %q in distro %s", path, d.name)
+
+	var flag string
+	switch mode {
+	case WSLPathToLinux:
+		flag = "-u"
+	case WSLPathToWindows:
+		flag = "-w"
+	case WSLPathToWindowsMixed:
+		flag = "-m"
+	default:
+		return "", fmt.Errorf("unknown mode %d", mode)
+	}
+
+	// wslpath does not understand "--", so paths starting with a dash would be taken as flags.
+	if strings.HasPrefix(path, "-") {
+		return "", errors.New("paths starting with a dash are not supported")
+	}
+
+	out, err := d.Command(ctx, fmt.Sprintf("wslpath %s %s", flag, shellQuote(path))).Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
@@ -0,0 +1,82 @@
+package gowsl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+)
+
+func TestAutomountRoot(t *testing.T) {
+	skipSessionOnWindowsMock(t)
+	ctx, _ := setupBackend(t, context.Background())
+
+	realDistro := newTestDistro(t, ctx, rootFS)
+	fakeDistro := wsl.NewDistro(ctx, uniqueDistroName(t))
+
+	root, err := realDistro.AutomountRoot(ctx)
+	require.NoError(t, err, "AutomountRoot should have succeeded")
+	require.Equal(t, wsl.DefaultAutomountRoot, root, "The test distro should use the default automount root")
+
+	_, err = fakeDistro.AutomountRoot(ctx)
+	require.Error(t, err, "AutomountRoot should fail when the distro is not registered")
+}
+
+func TestWSLPath(t *testing.T) {
+	ctx, _ := setupBackend(t, context.Background())
+
+	realDistro := newTestDistro(t, ctx, rootFS)
+	fakeDistro := wsl.NewDistro(ctx, uniqueDistroName(t))
+
+	testCases := map[string]struct {
+		distro *wsl.Distro
+		mode   wsl.WSLPathMode
+		path   string
+
+		want    string
+		wantErr bool
+	}{
+		"Success translating a drive path into Linux":      {mode: wsl.WSLPathToLinux, path: `C:\Users`, want: "/mnt/c/Users"},
+		"Success translating a drive path into Windows":    {mode: wsl.WSLPathToWindows, path: "/mnt/c/Users", want: `C:\Users`},
+		"Success translating a drive path with mixed mode": {mode: wsl.WSLPathToWindowsMixed, path: "/mnt/c/Users", want: "C:/Users"},
+
+		"Error when the distro is not registered":   {distro: &fakeDistro, mode: wsl.WSLPathToLinux, path: `C:\Users`, wantErr: true},
+		"Error when the mode is unknown":            {mode: wsl.WSLPathMode(42), path: `C:\Users`, wantErr: true},
+		"Error when the path starts with a dash":    {mode: wsl.WSLPathToWindows, path: "-a", wantErr: true},
+		"Error when the path cannot be translated":  {mode: wsl.WSLPathToLinux, path: `\\server\share\file`, wantErr: true},
+		"Error when the path to translate is empty": {mode: wsl.WSLPathToWindows, path: "", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := tc.distro
+			if d == nil {
+				d = &realDistro
+			}
+
+			got, err := d.WSLPath(ctx, tc.mode, tc.path)
+			if tc.wantErr {
+				require.Error(t, err, "WSLPath should have failed")
+				return
+			}
+			require.NoError(t, err, "WSLPath should have succeeded")
+			require.Equal(t, tc.want, got, "Unexpected path returned by WSLPath")
+
+			// The pure-Go translation must agree with the distro's.
+			var pure string
+			switch tc.mode {
+			case wsl.WSLPathToLinux:
+				pure, err = wsl.ToLinuxPath(d.Name(), "", tc.path)
+			case wsl.WSLPathToWindows:
+				pure, err = wsl.ToWindowsPath(d.Name(), "", tc.path)
+			default:
+				return
+			}
+			require.NoError(t, err, "Pure-Go translation should have succeeded")
+			require.Equal(t, got, pure, "Pure-Go translation does not match wslpath's")
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package provision makes the registered distros match a manifest, which declares the distros
+// that should exist and how they should be configured:
+//
+//	distros:
+//	  - name: Ubuntu-Dev
+//	    source: ubuntu.tar.gz          # Root filesystem to import the distro from
+//	    location: C:\WSL\Ubuntu-Dev    # Directory to store the distro's disk in
+//	    version: 2
+//	    defaultUser: dev
+//	    interop: true
+//	    pathAppended: false
+//	    wslConf: |
+//	      [boot]
+//	      systemd=true
+//	    postInstall:
+//	      - useradd --create-home --shell /bin/bash dev
+//
+// NewPlan compares the manifest against the registered distros, and returns the changes that
+// are needed. Print the plan to review it, and Apply it:
+//
+//	plan, err := provision.NewPlan(ctx, client, manifest)
+//	...
+//	fmt.Print(plan)
+//	err = plan.Apply(ctx)
+//
+// Applying a manifest is idempotent: once applied, planning it again returns no changes. Post-install
+// commands only run after importing the distro, as they are not expected to be idempotent. Only the
+// settings present in the manifest are enforced, and distros that are missing from it are left alone.
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest declares the distros that should be registered, and how to configure them.
+// It can be serialised as YAML or JSON.
+type Manifest struct {
+	Distros []Distro `json:"distros" yaml:"distros"`
+}
+
+// Distro declares a distro. Omitted settings are not enforced.
+type Distro struct {
+	Name     string `json:"name"     yaml:"name"`
+	Source   string `json:"source"   yaml:"source"`   // Root filesystem tarball to import the distro from
+	Location string `json:"location" yaml:"location"` // Directory to store the distro's disk in
+
+	Version       uint8  `json:"version,omitempty"       yaml:"version,omitempty"`       // WSL version
+	DefaultUser   string `json:"defaultUser,omitempty"   yaml:"defaultUser,omitempty"`   // Name or UID of the default user
+	Interop       *bool  `json:"interop,omitempty"       yaml:"interop,omitempty"`       // Whether Windows executables can be launched
+	PathAppended  *bool  `json:"pathAppended,omitempty"  yaml:"pathAppended,omitempty"`  // Whether the Windows PATH is appended
+	DriveMounting *bool  `json:"driveMounting,omitempty" yaml:"driveMounting,omitempty"` // Whether the Windows drives are mounted
+
+	WSLConf     *string  `json:"wslConf,omitempty"     yaml:"wslConf,omitempty"`     // Contents of /etc/wsl.conf
+	PostInstall []string `json:"postInstall,omitempty" yaml:"postInstall,omitempty"` // Commands to run as root after importing the distro
+}
+
+// Load reads a manifest serialised as YAML or JSON. Unknown fields are rejected, so that typos
+// do not go unnoticed.
+func Load(r io.Reader) (m Manifest, err error) {
+	defer decorate.OnError(&err, "could not load manifest")
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return Manifest{}, err
+	}
+
+	if err := m.validate(); err != nil {
+		return Manifest{}, err
+	}
+
+	return m, nil
+}
+
+// LoadFile reads a manifest from a file. Relative sources and locations are interpreted
+// relative to the directory of the manifest.
+func LoadFile(path string) (Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("could not load manifest: %v", err)
+	}
+	defer f.Close()
+
+	m, err := Load(f)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	dir := filepath.Dir(path)
+	for i := range m.Distros {
+		d := &m.Distros[i]
+		if !filepath.IsAbs(d.Source) {
+			d.Source = filepath.Join(dir, d.Source)
+		}
+		if !filepath.IsAbs(d.Location) {
+			d.Location = filepath.Join(dir, d.Location)
+		}
+	}
+
+	return m, nil
+}
+
+// validate checks that the manifest can be applied.
+func (m Manifest) validate() error {
+	seen := make(map[string]bool)
+	for i, d := range m.Distros {
+		if d.Name == "" {
+			return fmt.Errorf("distro #%d has no name", i+1)
+		}
+		// Distro names are case-insensitive.
+		if seen[strings.ToLower(d.Name)] {
+			return fmt.Errorf("distro %q is declared twice", d.Name)
+		}
+		seen[strings.ToLower(d.Name)] = true
+
+		if d.Source == "" {
+			return fmt.Errorf("distro %q has no source", d.Name)
+		}
+		if d.Location == "" {
+			return fmt.Errorf("distro %q has no location", d.Name)
+		}
+		if d.Version != 0 && d.Version != 1 && d.Version != 2 {
+			return fmt.Errorf("distro %q has invalid version %d", d.Name, d.Version)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,483 @@
+package provision
+
+// This file contains the planning and application of the changes needed to match a manifest.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+	wsl "github.com/ubuntu/gowsl"
+)
+
+// wslConfPath is the path of wsl.conf, as an FS name.
+const wslConfPath = "etc/wsl.conf"
+
+// Action is the kind of a change.
+type Action int
+
+const (
+	// Import imports the distro from its source.
+	Import Action = iota
+	// WriteWSLConf writes /etc/wsl.conf.
+	WriteWSLConf
+	// Run runs a post-install command.
+	Run
+	// Configure sets the default user and flags of the distro.
+	Configure
+	// Restart terminates the distro, so that changes to /etc/wsl.conf are applied on next start.
+	Restart
+	// Conflict is a difference with the manifest that cannot be fixed. Plans with conflicts cannot be applied.
+	Conflict
+)
+
+func (a Action) String() string {
+	switch a {
+	case Import:
+		return "import"
+	case WriteWSLConf:
+		return "write /etc/wsl.conf"
+	case Run:
+		return "run"
+	case Configure:
+		return "configure"
+	case Restart:
+		return "restart"
+	case Conflict:
+		return "conflict"
+	}
+	return fmt.Sprintf("Unknown action %d", a)
+}
+
+// Change is a change to a distro that is needed to match the manifest.
+type Change struct {
+	Distro string // Name of the distro
+	Action Action
+	Detail string // Description of the change, for humans
+
+	apply func(ctx context.Context) error
+	undo  func(ctx context.Context) error // Reverts the change if a later one for the distro fails
+}
+
+// String describes the change for humans.
+func (c Change) String() string {
+	symbol := "~"
+	switch c.Action {
+	case Import, Run:
+		symbol = "+"
+	case Conflict:
+		symbol = "!"
+	}
+
+	if c.Detail == "" {
+		return fmt.Sprintf("%s %s", symbol, c.Action)
+	}
+	return fmt.Sprintf("%s %s: %s", symbol, c.Action, c.Detail)
+}
+
+// Plan is the list of changes needed for the distros to match a manifest, in the order they
+// are applied. Create it with NewPlan.
+type Plan struct {
+	Changes []Change
+}
+
+// NewPlan compares the manifest against the distros registered in the client, and returns the
+// changes needed to match it. The distros that are registered may be started to read their
+// /etc/wsl.conf and look their default user up.
+func NewPlan(ctx context.Context, c *wsl.Client, m Manifest) (p *Plan, err error) {
+	defer decorate.OnError(&err, "could not plan the changes to the distros")
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	distros, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registered := make(map[string]bool)
+	for _, d := range distros {
+		registered[strings.ToLower(d.Name())] = true
+	}
+
+	p = &Plan{}
+	for _, want := range m.Distros {
+		if !registered[strings.ToLower(want.Name)] {
+			p.Changes = append(p.Changes, planImport(c, want)...)
+			continue
+		}
+
+		changes, err := planUpdate(ctx, c.Distro(want.Name), want)
+		if err != nil {
+			return nil, fmt.Errorf("distro %s: %v", want.Name, err)
+		}
+		p.Changes = append(p.Changes, changes...)
+	}
+
+	return p, nil
+}
+
+// Empty returns true if the distros already match the manifest.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String describes the plan for humans, with the changes grouped by distro.
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "No changes: the distros match the manifest.\n"
+	}
+
+	var b strings.Builder
+	distro := ""
+	for _, c := range p.Changes {
+		if c.Distro != distro {
+			distro = c.Distro
+			fmt.Fprintf(&b, "%s:\n", distro)
+		}
+		fmt.Fprintf(&b, "  %s\n", c)
+	}
+	return b.String()
+}
+
+// Apply applies the changes in order, and stops at the first one that fails. If the distro
+// of that change was imported by the plan, it is unregistered, so that the next plan sets it
+// up from scratch. Plans with conflicts are not applied at all.
+func (p *Plan) Apply(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, "could not apply the changes to the distros")
+
+	var conflicts []string
+	for _, c := range p.Changes {
+		if c.Action == Conflict {
+			conflicts = append(conflicts, fmt.Sprintf("distro %s: %s", c.Distro, c.Detail))
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("the plan has conflicts:\n%s", strings.Join(conflicts, "\n"))
+	}
+
+	// A distro that was imported is unregistered if any of its later changes fails. Otherwise,
+	// it would be registered without them, and the next plan would not run its post-install
+	// commands again.
+	undo := make(map[string]func(context.Context) error)
+	for _, c := range p.Changes {
+		if err := c.apply(ctx); err != nil {
+			err = fmt.Errorf("distro %s: could not %s: %w", c.Distro, c.Action, err)
+			if f, ok := undo[c.Distro]; ok {
+				err = errors.Join(err, f(ctx))
+			}
+			return err
+		}
+		if c.undo != nil {
+			undo[c.Distro] = c.undo
+		}
+	}
+
+	return nil
+}
+
+// planImport returns the changes to import a distro and set it up.
+func planImport(c *wsl.Client, want Distro) []Change {
+	d := c.Distro(want.Name)
+
+	changes := []Change{{
+		Action: Import,
+		Detail: fmt.Sprintf("from %s into %s", want.Source, want.Location),
+		apply: func(ctx context.Context) error {
+			if _, err := c.Import(ctx, want.Name, want.Source, want.Location); err != nil {
+				return err
+			}
+			if err := checkVersion(ctx, d, want.Version); err != nil {
+				return errors.Join(err, unregister(ctx, d))
+			}
+			return nil
+		},
+		undo: func(ctx context.Context) error { return unregister(ctx, d) },
+	}}
+
+	// The default user may be created by the post-install commands, so the distro
+	// is configured last.
+	if want.WSLConf != nil {
+		changes = append(changes, writeWSLConfChange(d, *want.WSLConf))
+	}
+
+	for _, cmd := range want.PostInstall {
+		changes = append(changes, Change{
+			Action: Run,
+			Detail: cmd,
+			apply:  func(ctx context.Context) error { return run(ctx, d, cmd) },
+		})
+	}
+
+	if settings := wantedSettings(want); len(settings) > 0 {
+		changes = append(changes, Change{
+			Action: Configure,
+			Detail: strings.Join(settings, ", "),
+			apply:  func(ctx context.Context) error { return configure(ctx, d, want) },
+		})
+	}
+
+	if want.WSLConf != nil {
+		changes = append(changes, restartChange(d))
+	}
+
+	for i := range changes {
+		changes[i].Distro = want.Name
+	}
+
+	return changes
+}
+
+// unregister unregisters a distro whose setup failed, even if the context is done.
+func unregister(ctx context.Context, d wsl.Distro) error {
+	if err := d.Unregister(context.WithoutCancel(ctx)); err != nil {
+		return fmt.Errorf("could not unregister the distro after the failure: %v", err)
+	}
+	return nil
+}
+
+// planUpdate returns the changes for a registered distro to match the manifest.
+func planUpdate(ctx context.Context, d wsl.Distro, want Distro) ([]Change, error) {
+	conf, err := d.GetConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+
+	if want.Version != 0 && conf.UndocumentedWSLVersion != want.Version {
+		changes = append(changes, Change{
+			Action: Conflict,
+			Detail: fmt.Sprintf("the distro uses WSL %d instead of WSL %d: convert it with wsl.exe --set-version", conf.UndocumentedWSLVersion, want.Version),
+		})
+	}
+
+	wslConfChanged := false
+	if want.WSLConf != nil {
+		current, err := readWSLConf(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || *current != *want.WSLConf {
+			wslConfChanged = true
+			changes = append(changes, writeWSLConfChange(d, *want.WSLConf))
+		}
+	}
+
+	var diffs []string
+	if want.DefaultUser != "" {
+		uid, err := lookupUser(ctx, d, want.DefaultUser)
+		if err != nil {
+			return nil, err
+		}
+		if uid != conf.DefaultUID {
+			diffs = append(diffs, fmt.Sprintf("default user %d -> %d (%s)", conf.DefaultUID, uid, want.DefaultUser))
+		}
+	}
+	diffs = appendFlagDiff(diffs, "interop", conf.InteropEnabled, want.Interop)
+	diffs = appendFlagDiff(diffs, "pathAppended", conf.PathAppended, want.PathAppended)
+	diffs = appendFlagDiff(diffs, "driveMounting", conf.DriveMountingEnabled, want.DriveMounting)
+
+	if len(diffs) > 0 {
+		changes = append(changes, Change{
+			Action: Configure,
+			Detail: strings.Join(diffs, ", "),
+			apply:  func(ctx context.Context) error { return configure(ctx, d, want) },
+		})
+	}
+
+	if wslConfChanged {
+		changes = append(changes, restartChange(d))
+	}
+
+	for i := range changes {
+		changes[i].Distro = want.Name
+	}
+
+	return changes, nil
+}
+
+// wantedSettings describes the settings of the manifest, for distros that are not imported yet.
+func wantedSettings(want Distro) []string {
+	var settings []string
+	if want.DefaultUser != "" {
+		settings = append(settings, "default user "+want.DefaultUser)
+	}
+	for _, f := range []struct {
+		name  string
+		value *bool
+	}{
+		{"interop", want.Interop},
+		{"pathAppended", want.PathAppended},
+		{"driveMounting", want.DriveMounting},
+	} {
+		if f.value != nil {
+			settings = append(settings, fmt.Sprintf("%s %t", f.name, *f.value))
+		}
+	}
+	return settings
+}
+
+// appendFlagDiff appends the description of the flag to diffs if it differs from the manifest.
+func appendFlagDiff(diffs []string, name string, current bool, want *bool) []string {
+	if want == nil || *want == current {
+		return diffs
+	}
+	return append(diffs, fmt.Sprintf("%s %t -> %t", name, current, *want))
+}
+
+func writeWSLConfChange(d wsl.Distro, contents string) Change {
+	return Change{
+		Action: WriteWSLConf,
+		apply: func(ctx context.Context) error {
+			return asRoot(ctx, d, func() error {
+				fsys := d.FS(ctx)
+				defer fsys.Close()
+
+				if err := fsys.MkdirAll("etc", 0755); err != nil {
+					return err
+				}
+				return fsys.WriteFile(wslConfPath, []byte(contents), 0644)
+			})
+		},
+	}
+}
+
+func restartChange(d wsl.Distro) Change {
+	return Change{
+		Action: Restart,
+		Detail: "to apply /etc/wsl.conf",
+		apply:  d.Terminate,
+	}
+}
+
+// checkVersion checks that the distro uses the WSL version, if any. Distros are imported with the
+// default WSL version of the machine, and GoWSL cannot convert them.
+func checkVersion(ctx context.Context, d wsl.Distro, version uint8) error {
+	if version == 0 {
+		return nil
+	}
+
+	conf, err := d.GetConfiguration(ctx)
+	if err != nil {
+		return err
+	}
+
+	if conf.UndocumentedWSLVersion != version {
+		return fmt.Errorf("the distro was imported as WSL %d instead of WSL %d: change the default version with wsl.exe --set-default-version", conf.UndocumentedWSLVersion, version)
+	}
+	return nil
+}
+
+// readWSLConf returns the contents of /etc/wsl.conf, or nil if it does not exist.
+func readWSLConf(ctx context.Context, d wsl.Distro) (*string, error) {
+	fsys := d.FS(ctx)
+	defer fsys.Close()
+
+	out, err := fsys.ReadFile(wslConfPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(out)
+	return &s, nil
+}
+
+// lookupUser returns the UID of the user, which can be a name or a UID.
+func lookupUser(ctx context.Context, d wsl.Distro, user string) (uint32, error) {
+	if uid, err := strconv.ParseUint(user, 10, 32); err == nil {
+		return uint32(uid), nil
+	}
+
+	out, err := d.Command(ctx, "id -u "+shellQuote(user)).Output()
+	if err != nil {
+		return 0, fmt.Errorf("could not find user %q: %v", user, err)
+	}
+
+	uid, err := strconv.ParseUint(string(bytes.TrimSpace(out)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse the UID of user %q: %v", user, err)
+	}
+
+	return uint32(uid), nil
+}
+
+// configure sets the default user and flags declared in the manifest.
+func configure(ctx context.Context, d wsl.Distro, want Distro) error {
+	if want.DefaultUser != "" {
+		uid, err := lookupUser(ctx, d, want.DefaultUser)
+		if err != nil {
+			return err
+		}
+		if err := d.DefaultUID(ctx, uid); err != nil {
+			return err
+		}
+	}
+
+	if want.Interop != nil {
+		if err := d.InteropEnabled(ctx, *want.Interop); err != nil {
+			return err
+		}
+	}
+
+	if want.PathAppended != nil {
+		if err := d.PathAppended(ctx, *want.PathAppended); err != nil {
+			return err
+		}
+	}
+
+	if want.DriveMounting != nil {
+		if err := d.DriveMountingEnabled(ctx, *want.DriveMounting); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run runs a command as root.
+func run(ctx context.Context, d wsl.Distro, cmd string) error {
+	return asRoot(ctx, d, func() error {
+		out, err := d.Command(ctx, cmd).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%q: %v: %s", cmd, err, bytes.TrimSpace(out))
+		}
+		return nil
+	})
+}
+
+// asRoot calls f with root as the default user of the distro, as commands run as the default user.
+func asRoot(ctx context.Context, d wsl.Distro, f func() error) (err error) {
+	conf, err := d.GetConfiguration(ctx)
+	if err != nil {
+		return err
+	}
+
+	if conf.DefaultUID == 0 {
+		return f()
+	}
+
+	if err := d.DefaultUID(ctx, 0); err != nil {
+		return err
+	}
+	defer func() {
+		if e := d.DefaultUID(ctx, conf.DefaultUID); e != nil && err == nil {
+			err = fmt.Errorf("could not restore the default user: %v", e)
+		}
+	}()
+
+	return f()
+}
+
+// shellQuote quotes s so that the shell reads it as a single word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
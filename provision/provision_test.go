@@ -0,0 +1,291 @@
+package provision_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+	"github.com/ubuntu/gowsl/mock"
+	"github.com/ubuntu/gowsl/provision"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		manifest string
+
+		want    provision.Manifest
+		wantErr bool
+	}{
+		"Success loading YAML": {
+			manifest: "distros:\n  - name: Ubuntu\n    source: rootfs.tar.gz\n    location: Ubuntu\n    version: 2\n    interop: false\n    postInstall:\n      - exit 0\n",
+			want: provision.Manifest{Distros: []provision.Distro{
+				{Name: "Ubuntu", Source: "rootfs.tar.gz", Location: "Ubuntu", Version: 2, Interop: ptr(false), PostInstall: []string{"exit 0"}},
+			}},
+		},
+		"Success loading JSON": {
+			manifest: `{"distros": [{"name": "Ubuntu", "source": "rootfs.tar.gz", "location": "Ubuntu", "defaultUser": "dev", "wslConf": ""}]}`,
+			want: provision.Manifest{Distros: []provision.Distro{
+				{Name: "Ubuntu", Source: "rootfs.tar.gz", Location: "Ubuntu", DefaultUser: "dev", WSLConf: ptr("")},
+			}},
+		},
+		"Success loading an empty manifest": {},
+
+		"Error with an unknown field":              {manifest: "distros:\n  - name: Ubuntu\n    source: rootfs.tar.gz\n    location: Ubuntu\n    colour: orange\n", wantErr: true},
+		"Error with a distro without a name":       {manifest: "distros:\n  - source: rootfs.tar.gz\n    location: Ubuntu\n", wantErr: true},
+		"Error with a distro without a source":     {manifest: "distros:\n  - name: Ubuntu\n    location: Ubuntu\n", wantErr: true},
+		"Error with a distro without a location":   {manifest: "distros:\n  - name: Ubuntu\n    source: rootfs.tar.gz\n", wantErr: true},
+		"Error with an invalid version":            {manifest: "distros:\n  - name: Ubuntu\n    source: rootfs.tar.gz\n    location: Ubuntu\n    version: 3\n", wantErr: true},
+		"Error with a distro declared twice":       {manifest: "distros:\n  - {name: Ubuntu, source: a.tar.gz, location: a}\n  - {name: ubuntu, source: b.tar.gz, location: b}\n", wantErr: true},
+		"Error with a manifest that is not a list": {manifest: "distros: Ubuntu\n", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := provision.Load(strings.NewReader(tc.manifest))
+			if tc.wantErr {
+				require.Error(t, err, "Load should have failed")
+				return
+			}
+			require.NoError(t, err, "Load should have succeeded")
+			require.Equal(t, tc.want, got, "Unexpected manifest")
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("distros:\n  - name: Ubuntu\n    source: rootfs.tar.gz\n    location: /wsl/Ubuntu\n"), 0600), "Setup: could not write manifest")
+
+	m, err := provision.LoadFile(path)
+	require.NoError(t, err, "LoadFile should have succeeded")
+	require.Equal(t, filepath.Join(dir, "rootfs.tar.gz"), m.Distros[0].Source, "Relative sources should be relative to the manifest")
+	require.Equal(t, filepath.Clean("/wsl/Ubuntu"), filepath.Clean(m.Distros[0].Location), "Absolute locations should be kept")
+
+	_, err = provision.LoadFile(filepath.Join(dir, "not-a-file.yaml"))
+	require.Error(t, err, "LoadFile should have failed with a missing file")
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		distro     provision.Distro
+		registered bool // Whether the distro is registered before planning
+		version    uint8
+		linuxOnly  bool // The mock only runs commands in the sandbox on Linux
+
+		wantActions []provision.Action
+		wantErr     bool
+	}{
+		"Success importing a distro": {
+			distro:      provision.Distro{Interop: ptr(false)},
+			wantActions: []provision.Action{provision.Import, provision.Configure},
+		},
+		"Success importing a distro with its WSL version": {
+			distro:      provision.Distro{Version: 2},
+			wantActions: []provision.Action{provision.Import},
+		},
+		"Success updating the flags of a distro": {
+			distro:      provision.Distro{Interop: ptr(false), PathAppended: ptr(true), DriveMounting: ptr(false)},
+			registered:  true,
+			wantActions: []provision.Action{provision.Configure},
+		},
+		"Success updating the default user of a distro with a UID": {
+			distro:      provision.Distro{DefaultUser: "1000"},
+			registered:  true,
+			wantActions: []provision.Action{provision.Configure},
+		},
+		"Success importing a distro with a default user created after install": {
+			distro: provision.Distro{
+				DefaultUser: "dev",
+				WSLConf:     ptr("[boot]\nsystemd=true\n"),
+				PostInstall: []string{"echo 'dev:x:1001:1001::/home/dev:/bin/sh' >> /etc/passwd"},
+			},
+			linuxOnly:   true,
+			wantActions: []provision.Action{provision.Import, provision.WriteWSLConf, provision.Run, provision.Configure, provision.Restart},
+		},
+		"Success updating the default user and wsl.conf of a distro": {
+			distro:      provision.Distro{DefaultUser: "alice", WSLConf: ptr("[interop]\nenabled=false\n")},
+			registered:  true,
+			linuxOnly:   true,
+			wantActions: []provision.Action{provision.WriteWSLConf, provision.Configure, provision.Restart},
+		},
+		"Success with a distro that is up to date": {
+			distro:     provision.Distro{Version: 2, DefaultUser: "0", Interop: ptr(true)},
+			registered: true,
+		},
+
+		"Error applying a plan with a WSL version conflict": {
+			distro:      provision.Distro{Version: 2},
+			registered:  true,
+			version:     1,
+			wantActions: []provision.Action{provision.Conflict},
+			wantErr:     true,
+		},
+		"Error importing a distro with another WSL version": {
+			distro:      provision.Distro{Version: 1},
+			wantActions: []provision.Action{provision.Import},
+			wantErr:     true,
+		},
+		"Error applying a plan with a failing post-install command": {
+			distro:      provision.Distro{PostInstall: []string{"exit 42"}},
+			linuxOnly:   true,
+			wantActions: []provision.Action{provision.Import, provision.Run},
+			wantErr:     true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.linuxOnly && runtime.GOOS != "linux" {
+				t.Skip("This test requires running commands in the mock sandbox, which only works on Linux")
+			}
+
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			tc.distro.Name = "Ubuntu"
+			tc.distro.Source = filepath.Join(dir, "rootfs.tar.gz")
+			tc.distro.Location = filepath.Join(dir, "Ubuntu")
+			writeRootfs(t, tc.distro.Source)
+
+			m := mock.New(mock.WithSandbox(filepath.Join(dir, "sandbox")))
+			c := wsl.NewClient(wsl.WithClientBackend(m))
+
+			if tc.registered {
+				_, err := c.Import(ctx, tc.distro.Name, tc.distro.Source, tc.distro.Location)
+				require.NoError(t, err, "Setup: could not import distro")
+			}
+			if tc.version != 0 {
+				require.NoError(t, m.SetVersion(ctx, tc.distro.Name, tc.version), "Setup: could not convert distro")
+			}
+
+			manifest := provision.Manifest{Distros: []provision.Distro{tc.distro}}
+
+			plan, err := provision.NewPlan(ctx, c, manifest)
+			require.NoError(t, err, "NewPlan should have succeeded")
+
+			require.Equal(t, tc.wantActions, actions(t, plan), "Unexpected changes in plan:\n%s", plan)
+			require.Equal(t, len(tc.wantActions) == 0, plan.Empty(), "Empty should tell whether there are changes")
+			require.NotEmpty(t, plan.String(), "The plan should be printable")
+
+			err = plan.Apply(ctx)
+			if tc.wantErr {
+				require.Error(t, err, "Apply should have failed")
+
+				// Distros whose setup failed are imported again, so that none of their changes is skipped.
+				plan, err = provision.NewPlan(ctx, c, manifest)
+				require.NoError(t, err, "NewPlan should have succeeded after failing to apply the plan")
+				require.Equal(t, tc.wantActions, actions(t, plan), "The same changes should be planned after a failure:\n%s", plan)
+				return
+			}
+			require.NoError(t, err, "Apply should have succeeded")
+
+			plan, err = provision.NewPlan(ctx, c, manifest)
+			require.NoError(t, err, "NewPlan should have succeeded after applying the plan")
+			require.True(t, plan.Empty(), "Applying the plan should have made the distro match the manifest, but changes remain:\n%s", plan)
+
+			d := c.Distro(tc.distro.Name)
+			conf, err := d.GetConfiguration(ctx)
+			require.NoError(t, err, "GetConfiguration should have succeeded")
+			if uid, ok := map[string]uint32{"1000": 1000, "alice": 1000, "dev": 1001}[tc.distro.DefaultUser]; ok {
+				require.Equal(t, uid, conf.DefaultUID, "The default user should have been set")
+			}
+			if tc.distro.Interop != nil {
+				require.Equal(t, *tc.distro.Interop, conf.InteropEnabled, "Interop should have been set")
+			}
+			if tc.distro.WSLConf != nil {
+				root, err := m.RootDir(tc.distro.Name)
+				require.NoError(t, err, "RootDir should have succeeded")
+				got, err := os.ReadFile(filepath.Join(root, "etc", "wsl.conf"))
+				require.NoError(t, err, "wsl.conf should have been written")
+				require.Equal(t, *tc.distro.WSLConf, string(got), "Unexpected contents of wsl.conf")
+			}
+		})
+	}
+}
+
+func TestPlanLeavesOtherDistrosAlone(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	rootfs := filepath.Join(dir, "rootfs.tar.gz")
+	writeRootfs(t, rootfs)
+
+	c := wsl.NewClient(wsl.WithClientBackend(mock.New(mock.WithSandbox(filepath.Join(dir, "sandbox")))))
+	_, err := c.Import(ctx, "Debian", rootfs, filepath.Join(dir, "Debian"))
+	require.NoError(t, err, "Setup: could not import distro")
+
+	plan, err := provision.NewPlan(ctx, c, provision.Manifest{})
+	require.NoError(t, err, "NewPlan should have succeeded")
+	require.True(t, plan.Empty(), "Distros missing from the manifest should not be changed")
+	require.NoError(t, plan.Apply(ctx), "Applying an empty plan should have succeeded")
+
+	registered, err := c.Distro("Debian").IsRegistered(ctx)
+	require.NoError(t, err, "IsRegistered should have succeeded")
+	require.True(t, registered, "Distros missing from the manifest should not be unregistered")
+}
+
+// writeRootfs writes a gzipped tarball with a small root filesystem, with users root and alice.
+func writeRootfs(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err, "Setup: could not create tarball")
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	entries := []struct {
+		hdr      tar.Header
+		contents string
+	}{
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0755}},
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./etc/", Mode: 0755}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./etc/passwd", Mode: 0644}, contents: "root:x:0:0:root:/root:/bin/sh\nalice:x:1000:1000::/home/alice:/bin/sh\n"},
+	}
+
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.contents))
+		require.NoError(t, tw.WriteHeader(&e.hdr), "Setup: could not write tar header")
+		_, err := tw.Write([]byte(e.contents))
+		require.NoError(t, err, "Setup: could not write tar contents")
+	}
+}
+
+// actions returns the actions of the changes in the plan, which all refer to the Ubuntu distro.
+func actions(t *testing.T, plan *provision.Plan) []provision.Action {
+	t.Helper()
+
+	var got []provision.Action
+	for _, change := range plan.Changes {
+		require.Equal(t, "Ubuntu", change.Distro, "Changes should refer to their distro")
+		got = append(got, change.Action)
+	}
+	return got
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
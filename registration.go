@@ -14,13 +14,15 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/ubuntu/decorate"
-	"github.com/ubuntu/gowsl/internal/backend"
+	"github.com/ubuntu/gowsl/backend"
 )
 
 // Register is a wrapper around Win32's WslRegisterDistribution.
 // It creates a new distro with a copy of the given tarball as
-// its filesystem.
-func (d *Distro) Register(rootFsPath string) (err error) {
+// its filesystem. See WithCloudInit to provision it.
+//
+// If the distro is registered but cannot be provisioned, it stays registered.
+func (d *Distro) Register(ctx context.Context, rootFsPath string, args ...RegisterOption) (err error) {
 	defer decorate.OnError(&err, "could not register %s from rootfs in %s", d.name, rootFsPath)
 
 	rootFsPath, err = fixPath(rootFsPath)
@@ -28,7 +30,7 @@ func (d *Distro) Register(rootFsPath string) (err error) {
 		return err
 	}
 
-	r, err := d.isRegistered()
+	r, err := d.isRegistered(ctx)
 	if err != nil {
 		return err
 	}
@@ -36,26 +38,38 @@ func (d *Distro) Register(rootFsPath string) (err error) {
 		return errors.New("already registered")
 	}
 
-	return d.backend.WslRegisterDistribution(d.Name(), rootFsPath)
+	var opts registerOptions
+	for _, f := range args {
+		f(&opts)
+	}
+
+	return d.provision(ctx, func() error {
+		return d.backend.WslRegisterDistribution(ctx, d.Name(), rootFsPath)
+	}, opts)
 }
 
 // RegisteredDistros returns a slice of the registered distros.
 func RegisteredDistros(ctx context.Context) (distros []Distro, err error) {
+	return clientFromContext(ctx).List(ctx)
+}
+
+// List returns a slice of the registered distros.
+func (c *Client) List(ctx context.Context) (distros []Distro, err error) {
 	defer decorate.OnError(&err, "could not obtain registered distros")
 
-	names, err := registeredDistros(selectBackend(ctx))
+	names, err := registeredDistros(ctx, c.backend)
 	if err != nil {
 		return distros, err
 	}
 	for name := range names {
-		distros = append(distros, NewDistro(ctx, name))
+		distros = append(distros, c.Distro(name))
 	}
 	return distros, nil
 }
 
-// RegisteredDistros returns a map of the registered distros and their GUID.
-func registeredDistros(backend backend.Backend) (distros map[string]uuid.UUID, err error) {
-	r, err := backend.OpenLxssRegistry(".")
+// registeredDistros returns a map of the registered distros and their GUID.
+func registeredDistros(ctx context.Context, backend backend.Backend) (distros map[string]uuid.UUID, err error) {
+	r, err := backend.OpenLxssRegistry(ctx, ".")
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +87,7 @@ func registeredDistros(backend backend.Backend) (distros map[string]uuid.UUID, e
 			continue // Not a WSL distro
 		}
 
-		r, err = backend.OpenLxssRegistry(key)
+		r, err = backend.OpenLxssRegistry(ctx, key)
 		if err != nil {
 			return nil, err
 		}
@@ -91,8 +105,8 @@ func registeredDistros(backend backend.Backend) (distros map[string]uuid.UUID, e
 }
 
 // IsRegistered returns a boolean indicating whether a distro is registered or not.
-func (d Distro) IsRegistered() (registered bool, err error) {
-	r, err := d.isRegistered()
+func (d Distro) IsRegistered(ctx context.Context) (registered bool, err error) {
+	r, err := d.isRegistered(ctx)
 	if err != nil {
 		return false, fmt.Errorf("%s: %v", d.name, err)
 	}
@@ -101,9 +115,9 @@ func (d Distro) IsRegistered() (registered bool, err error) {
 
 // isRegistered is the internal way of detecting whether a distro is registered or
 // not. Use this one internally to avoid repeating error information.
-func (d Distro) isRegistered() (registered bool, err error) {
+func (d Distro) isRegistered(ctx context.Context) (registered bool, err error) {
 	defer decorate.OnError(&err, "could not determine if distro is registered")
-	distros, err := registeredDistros(d.backend)
+	distros, err := registeredDistros(ctx, d.backend)
 	if err != nil {
 		return false, err
 	}
@@ -119,19 +133,24 @@ func (d Distro) isRegistered() (registered bool, err error) {
 
 // Unregister is a wrapper around Win32's WslUnregisterDistribution.
 // It irreparably destroys a distro and its filesystem.
-func (d *Distro) Unregister() (err error) {
+func (d *Distro) Unregister(ctx context.Context) (err error) {
 	defer decorate.OnError(&err, "could not unregister %q", d.name)
 
-	if err := d.mustBeRegistered(); err != nil {
+	if err := d.mustBeRegistered(ctx); err != nil {
 		return err
 	}
 
-	return d.backend.WslUnregisterDistribution(d.Name())
+	return d.backend.WslUnregisterDistribution(ctx, d.Name())
 }
 
 // Install installs a new distro from the Windows store.
 func Install(ctx context.Context, appxName string) error {
-	return selectBackend(ctx).Install(ctx, appxName)
+	return clientFromContext(ctx).Install(ctx, appxName)
+}
+
+// Install installs a new distro from the Windows store.
+func (c *Client) Install(ctx context.Context, appxName string) error {
+	return c.backend.Install(ctx, appxName)
 }
 
 // Uninstall removes the distro's associated AppxPackage (if there is one)
@@ -139,12 +158,12 @@ func Install(ctx context.Context, appxName string) error {
 func (d *Distro) Uninstall(ctx context.Context) (err error) {
 	defer decorate.OnError(&err, "Distro %q uninstall", d.name)
 
-	guid, err := d.GUID()
+	guid, err := d.GUID(ctx)
 	if err != nil {
 		return err
 	}
 
-	k, err := d.backend.OpenLxssRegistry(fmt.Sprintf("{%s}", guid))
+	k, err := d.backend.OpenLxssRegistry(ctx, fmt.Sprintf("{%s}", guid))
 	if err != nil {
 		return err
 	}
@@ -153,7 +172,7 @@ func (d *Distro) Uninstall(ctx context.Context) (err error) {
 	packageFamilyName, err := k.Field("PackageFamilyName")
 	if errors.Is(err, fs.ErrNotExist) {
 		// Distro was imported, so there is no Appx associated
-		return d.backend.WslUnregisterDistribution(d.Name())
+		return d.backend.WslUnregisterDistribution(ctx, d.Name())
 	}
 	if err != nil {
 		return err
@@ -163,11 +182,19 @@ func (d *Distro) Uninstall(ctx context.Context) (err error) {
 		return err
 	}
 
-	return d.backend.WslUnregisterDistribution(d.Name())
+	return d.backend.WslUnregisterDistribution(ctx, d.Name())
 }
 
-// Import creates a new distro from a source root filesystem.
-func Import(ctx context.Context, distributionName, sourcePath, destinationPath string) (Distro, error) {
+// Import creates a new distro from a source root filesystem. See WithCloudInit to provision it.
+func Import(ctx context.Context, distributionName, sourcePath, destinationPath string, args ...RegisterOption) (Distro, error) {
+	return clientFromContext(ctx).Import(ctx, distributionName, sourcePath, destinationPath, args...)
+}
+
+// Import creates a new distro from a source root filesystem. See WithCloudInit to provision it.
+//
+// If the distro is imported but cannot be provisioned, it stays registered and is returned
+// along with the error, which is a *CloudInitError if cloud-init failed.
+func (c *Client) Import(ctx context.Context, distributionName, sourcePath, destinationPath string, args ...RegisterOption) (Distro, error) {
 	err := os.MkdirAll(destinationPath, 0700)
 	if err != nil {
 		return Distro{}, fmt.Errorf("could not create destination path: %v", err)
@@ -180,12 +207,27 @@ func Import(ctx context.Context, distributionName, sourcePath, destinationPath s
 		return Distro{}, errors.New("source path is a directory")
 	}
 
-	err = selectBackend(ctx).Import(ctx, distributionName, sourcePath, destinationPath)
-	if err != nil {
+	var opts registerOptions
+	for _, f := range args {
+		f(&opts)
+	}
+
+	d := c.Distro(distributionName)
+
+	var imported bool
+	err = d.provision(ctx, func() error {
+		if err := c.backend.Import(ctx, distributionName, sourcePath, destinationPath); err != nil {
+			return err
+		}
+		imported = true
+		return nil
+	}, opts)
+
+	if err != nil && !imported {
 		return Distro{}, err
 	}
 
-	return NewDistro(ctx, distributionName), nil
+	return d, err
 }
 
 // fixPath deals with the fact that WslRegisterDistribuion is
@@ -202,8 +244,8 @@ func fixPath(relative string) (string, error) {
 	return abs, nil
 }
 
-func (d Distro) mustBeRegistered() error {
-	r, err := d.isRegistered()
+func (d Distro) mustBeRegistered(ctx context.Context) error {
+	r, err := d.isRegistered(ctx)
 	if err != nil {
 		return err
 	}
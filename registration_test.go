@@ -2,7 +2,9 @@ package gowsl_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -45,10 +47,14 @@ func TestRegister(t *testing.T) {
 			ctx, modifyMock := setupBackend(t, context.Background())
 			if tc.syscallError || tc.registryInaccessible {
 				modifyMock(t, func(m *wslmock.Backend) {
-					m.WslRegisterDistributionError = tc.syscallError
-					m.OpenLxssKeyError = tc.registryInaccessible
+					if tc.syscallError {
+						m.InjectFault("WslRegisterDistribution", wslmock.Fault{})
+					}
+					if tc.registryInaccessible {
+						m.InjectFault("OpenLxssRegistry", wslmock.Fault{})
+					}
 				})
-				defer modifyMock(t, (*wslmock.Backend).ResetErrors)
+				defer modifyMock(t, (*wslmock.Backend).ClearFaults)
 			}
 
 			d := wsl.NewDistro(ctx, uniqueDistroName(t)+tc.distroSuffix)
@@ -61,7 +67,7 @@ func TestRegister(t *testing.T) {
 
 			cancel := wslExeGuard(3 * time.Minute)
 			t.Logf("Registering %q", d.Name())
-			err := d.Register(tc.rootfs)
+			err := d.Register(ctx, tc.rootfs)
 			cancel()
 			t.Log("Registration completed")
 
@@ -80,7 +86,7 @@ func TestRegister(t *testing.T) {
 			// Testing double registration failure
 			cancel = wslExeGuard(3 * time.Minute)
 			t.Logf("Registering %q", d.Name())
-			err = d.Register(tc.rootfs)
+			err = d.Register(ctx, tc.rootfs)
 			cancel()
 			t.Log("Registration completed")
 
@@ -89,6 +95,153 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+func TestRegisterCancel(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		cancelBefore bool
+
+		wantAbandoned bool
+	}{
+		"Error when the context is done before registering": {cancelBefore: true},
+		"Error when the context is done while registering":  {wantAbandoned: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Clients do not need the gowslmock build tag.
+			c := wsl.NewClient(wsl.WithClientBackend(wslmock.New(wslmock.WithDurations(wslmock.Durations{Register: time.Hour}))))
+			d := c.Distro(uniqueDistroName(t))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if tc.cancelBefore {
+				cancel()
+			}
+
+			err := d.Register(ctx, rootFS)
+			if tc.wantAbandoned {
+				require.ErrorIs(t, err, wsl.ErrAbandoned, "Register should have reported the registration as abandoned")
+				require.ErrorIs(t, err, context.DeadlineExceeded, "Register should have returned the context's error")
+			} else {
+				require.ErrorIs(t, err, context.Canceled, "Register should have returned the context's error")
+				require.NotErrorIs(t, err, wsl.ErrAbandoned, "Register should not have started the registration")
+			}
+
+			registered, err := d.IsRegistered(context.Background())
+			require.NoError(t, err, "IsRegistered should have succeeded")
+			require.False(t, registered, "The half-registered distro should have been cleaned up")
+		})
+	}
+}
+
+func TestRegisterCloudInit(t *testing.T) {
+	t.Parallel()
+
+	const userData = "#cloud-config\nlocale: en_GB.UTF-8\n"
+
+	testCases := map[string]struct {
+		imported      bool
+		noCloudInit   bool
+		cloudInitCode int
+		cloudInitOut  string
+		userDataExist bool
+
+		wantStatus        string
+		wantErr           bool
+		wantCloudInitErr  bool
+		wantNotRegistered bool
+	}{
+		"Success registering a distro":                          {cloudInitOut: `{"status": "done", "errors": []}`, wantStatus: "done"},
+		"Success importing a distro":                            {imported: true, cloudInitOut: `{"status": "done", "errors": []}`, wantStatus: "done"},
+		"Success with recoverable errors":                       {cloudInitCode: 2, cloudInitOut: `{"status": "done", "extended_status": "degraded done", "errors": [], "recoverable_errors": {"WARNING": ["Deprecated key"]}}`, wantStatus: "done"},
+		"Success registering without cloud-init":                {noCloudInit: true},
+		"Success importing without cloud-init":                  {noCloudInit: true, imported: true},
+		"Error when cloud-init fails":                           {cloudInitCode: 1, cloudInitOut: `{"status": "error", "errors": ["Failed to install packages"]}`, wantErr: true, wantCloudInitErr: true},
+		"Error when importing and cloud-init fails":             {imported: true, cloudInitCode: 1, cloudInitOut: `{"status": "error", "errors": ["Failed to install packages"]}`, wantErr: true, wantCloudInitErr: true},
+		"Error when cloud-init is not installed":                {cloudInitCode: 127, wantErr: true},
+		"Error when importing and cloud-init is not installed":  {imported: true, cloudInitCode: 127, wantErr: true},
+		"Error when cloud-init prints an invalid value":         {cloudInitOut: "done", wantErr: true},
+		"Error when the user-data already exists":               {userDataExist: true, wantErr: true, wantNotRegistered: true},
+		"Error when importing and the user-data already exists": {imported: true, userDataExist: true, wantErr: true, wantNotRegistered: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Clients do not need the gowslmock build tag.
+			m := wslmock.New()
+			c := wsl.NewClient(wsl.WithClientBackend(m))
+			d := c.Distro(uniqueDistroName(t))
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, d.Name()+".user-data")
+			if tc.userDataExist {
+				require.NoError(t, os.WriteFile(path, []byte("#cloud-config\n"), 0600), "Setup: could not write user-data")
+			}
+
+			var called bool
+			m.Handle(wslmock.Exact("cloud-init status --wait --format=json"), func(_ context.Context, _ io.Reader, stdout, _ io.Writer) int {
+				if !called {
+					got, err := os.ReadFile(path)
+					assert.NoError(t, err, "The user-data should be in place while cloud-init runs")
+					assert.Equal(t, userData, string(got), "Unexpected user-data")
+				}
+				called = true
+
+				fmt.Fprint(stdout, tc.cloudInitOut)
+				return tc.cloudInitCode
+			})
+
+			var opts []wsl.RegisterOption
+			if !tc.noCloudInit {
+				opts = append(opts, wsl.WithCloudInit([]byte(userData)), wsl.WithCloudInitDir(dir))
+			}
+
+			var err error
+			if tc.imported {
+				var got wsl.Distro
+				got, err = c.Import(context.Background(), d.Name(), rootFS, t.TempDir(), opts...)
+				// Imported distros are returned even if they cannot be provisioned.
+				require.Equal(t, !tc.wantNotRegistered, got.Equal(d), "Import should return the distro if, and only if, it is registered")
+			} else {
+				err = d.Register(context.Background(), rootFS, opts...)
+			}
+
+			var cloudInitErr *wsl.CloudInitError
+			require.Equal(t, tc.wantCloudInitErr, errors.As(err, &cloudInitErr), "Unexpected type of error: %v", err)
+			if tc.wantErr {
+				require.Error(t, err, "Registering should have failed")
+			} else {
+				require.NoError(t, err, "Registering should have succeeded")
+			}
+			require.Equal(t, !tc.noCloudInit && !tc.userDataExist, called, "cloud-init should have been waited for when, and only when, there is user-data")
+
+			if tc.userDataExist {
+				require.FileExists(t, path, "Existing user-data should not be removed")
+			} else {
+				require.NoFileExists(t, path, "The user-data should have been removed")
+			}
+
+			registered, err := d.IsRegistered(context.Background())
+			require.NoError(t, err, "IsRegistered should have succeeded")
+			require.Equal(t, !tc.wantNotRegistered, registered, "Unexpected registration of the distro")
+
+			if tc.wantStatus == "" {
+				return
+			}
+
+			status, err := d.CloudInitStatus(context.Background())
+			require.NoError(t, err, "CloudInitStatus should have succeeded")
+			require.Equal(t, tc.wantStatus, status.Status, "Unexpected cloud-init status")
+			require.False(t, status.Failed(), "cloud-init should not have failed")
+		})
+	}
+}
+
 func TestRegisteredDistros(t *testing.T) {
 	testCases := map[string]struct {
 		registryInaccessible bool
@@ -115,9 +268,9 @@ func TestRegisteredDistros(t *testing.T) {
 
 			if tc.registryInaccessible {
 				modifyMock(t, func(m *wslmock.Backend) {
-					m.OpenLxssKeyError = true
+					m.InjectFault("OpenLxssRegistry", wslmock.Fault{})
 				})
-				defer modifyMock(t, (*wslmock.Backend).ResetErrors)
+				defer modifyMock(t, (*wslmock.Backend).ClearFaults)
 			}
 
 			list, err := wsl.RegisteredDistros(ctx)
@@ -167,9 +320,9 @@ func TestIsRegistered(t *testing.T) {
 			ctx, modifyMock := setupBackend(t, context.Background())
 			if tc.registryInaccessible {
 				modifyMock(t, func(m *wslmock.Backend) {
-					m.OpenLxssKeyError = true
+					m.InjectFault("OpenLxssRegistry", wslmock.Fault{})
 				})
-				defer modifyMock(t, (*wslmock.Backend).ResetErrors)
+				defer modifyMock(t, (*wslmock.Backend).ClearFaults)
 			}
 
 			var distro wsl.Distro
@@ -179,7 +332,7 @@ func TestIsRegistered(t *testing.T) {
 				distro = wsl.NewDistro(ctx, uniqueDistroName(t))
 			}
 
-			reg, err := distro.IsRegistered()
+			reg, err := distro.IsRegistered(ctx)
 			if tc.wantError {
 				require.Error(t, err)
 			} else {
@@ -232,16 +385,20 @@ func TestUnregister(t *testing.T) {
 
 			if tc.registryInaccessible || tc.syscallError {
 				modifyMock(t, func(m *wslmock.Backend) {
-					m.WslUnregisterDistributionError = tc.syscallError
-					m.OpenLxssKeyError = tc.registryInaccessible
+					if tc.syscallError {
+						m.InjectFault("WslUnregisterDistribution", wslmock.Fault{})
+					}
+					if tc.registryInaccessible {
+						m.InjectFault("OpenLxssRegistry", wslmock.Fault{})
+					}
 				})
-				defer modifyMock(t, (*wslmock.Backend).ResetErrors)
+				defer modifyMock(t, (*wslmock.Backend).ClearFaults)
 			}
 
 			t.Logf("Unregistering %q", d.Name())
 
 			cancel := wslExeGuard(3 * time.Minute)
-			err := d.Unregister()
+			err := d.Unregister(ctx)
 			cancel()
 
 			t.Log("Unregistration completed")
@@ -307,7 +464,9 @@ func TestInstall(t *testing.T) {
 				}
 				t.Parallel()
 				m := wslmock.New()
-				m.InstallError = tc.mockErr
+				if tc.mockErr {
+					m.InjectFault("Install", wslmock.Fault{})
+				}
 				ctx = wsl.WithMock(ctx, m)
 			} else {
 				// Real back-end setup
@@ -415,12 +574,16 @@ func TestUninstall(t *testing.T) {
 
 			// Delayed options to avoid breaking the setup
 			if wsl.MockAvailable() {
-				mock.RemoveAppxFamilyError = tc.mockCannotRemoveAppx
-				mock.OpenLxssKeyError = tc.mockCannotOpenRegistry
+				if tc.mockCannotRemoveAppx {
+					mock.InjectFault("RemoveAppxFamily", wslmock.Fault{})
+				}
+				if tc.mockCannotOpenRegistry {
+					mock.InjectFault("OpenLxssRegistry", wslmock.Fault{})
+				}
 			}
 
 			//nolint:errcheck // Nothing we can do about this error
-			defer d.Unregister()
+			defer d.Unregister(ctx)
 
 			// We cannot cancel the original context because that would break the deferred cleanups
 			uninstallCtx, cancel := context.WithCancel(ctx)
@@ -441,7 +604,7 @@ func TestUninstall(t *testing.T) {
 
 			require.NoError(t, err, "Uninstall should return no error")
 
-			reg, err := d.IsRegistered()
+			reg, err := d.IsRegistered(ctx)
 			require.NoError(t, err, "IsRegistered should return no error")
 			require.False(t, reg, "Uninstall should have unregistered the distro")
 
@@ -572,7 +735,7 @@ func requireInstallFromAppxWindows(t *testing.T, ctx context.Context, appxName s
 	}
 
 	d = wsl.NewDistro(ctx, distroName)
-	_ = d.Unregister()
+	_ = d.Unregister(ctx)
 	// A previous unsuccessful installation may have left leftovers inside HKCU:\Software\Microsoft\Windows\CurrentVersion\Lxss\.
 	// We need to find the key (guid) whose names match appxName and delete that key.
 	if err := cleanupRegistry(t, d); err != nil {
@@ -632,13 +795,13 @@ func requireInstallFromAppxMock(t *testing.T, ctx context.Context, m *wslmock.Ba
 	t.Helper()
 
 	d = wsl.NewDistro(ctx, distroName)
-	err := d.Register(rootFS)
+	err := d.Register(ctx, rootFS)
 	require.NoError(t, err, "Setup: could not register")
 
-	guid, err := d.GUID()
+	guid, err := d.GUID(ctx)
 	require.NoError(t, err, "Setup: could not get GUID to access distro registry")
 
-	k, err := m.OpenLxssRegistry(fmt.Sprintf("{%s}", guid))
+	k, err := m.OpenLxssRegistry(ctx, fmt.Sprintf("{%s}", guid))
 	require.NoError(t, err, "Setup: could not access distro registry")
 	defer k.Close()
 
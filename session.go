@@ -0,0 +1,387 @@
+package gowsl
+
+// This file contains utilities to run many commands over a single long-lived WSL process.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ubuntu/decorate"
+	"github.com/ubuntu/gowsl/internal/session"
+)
+
+// Session is a long-lived shell running inside a distro. Commands run via a Session
+// do not pay the cost of launching a new WSL process every time, which makes it much
+// faster than Cmd when running many short commands.
+//
+// Commands are run one at a time: concurrent calls are serialized. Each command runs
+// in its own subshell, so changes to the working directory or the environment do not
+// persist between them. Commands run with an empty standard input.
+//
+// A Session must be closed after use to release the WSL process.
+type Session struct {
+	distro *Distro
+	cmd    *Cmd
+
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+
+	// marker is the random string used to delimit the output of each command.
+	marker string
+
+	mu  sync.Mutex
+	err error // Sticky error: once the session breaks, it cannot be used anymore.
+}
+
+// errSessionClosed is the error returned when using a Session after it was closed.
+var errSessionClosed = errors.New("session is closed")
+
+// Session starts a long-lived shell inside the distro, over which commands can be run.
+//
+// The provided context is used to kill the shell (by calling CloseHandle) if the
+// context becomes done before the session is closed.
+func (d *Distro) Session(ctx context.Context) (s *Session, err error) {
+	defer decorate.OnError(&err, "could not start session on distro %s", d.name)
+
+	marker := make([]byte, 16)
+	if _, err := rand.Read(marker); err != nil {
+		return nil, fmt.Errorf("could not generate marker: %v", err)
+	}
+
+	s = &Session{
+		distro: d,
+		cmd:    d.Command(ctx, session.Shell),
+		marker: fmt.Sprintf("__GOWSL_%s__", hex.EncodeToString(marker)),
+	}
+
+	if s.stdin, err = s.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	s.stdout = bufio.NewReader(stdout)
+
+	stderr, err := s.cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	s.stderr = bufio.NewReader(stderr)
+
+	if err := s.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close terminates the shell and releases all associated resources.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if errors.Is(s.err, errSessionClosed) {
+		return nil
+	}
+
+	return s.close()
+}
+
+// close terminates the shell. Use with the mutex locked.
+func (s *Session) close() error {
+	graceful := s.err == nil
+	if graceful {
+		s.err = errSessionClosed
+	} else {
+		s.err = errors.Join(errSessionClosed, s.err)
+	}
+
+	// Closing stdin makes the shell exit gracefully. If we got here
+	// due to an error, the shell may be in any state so we kill it.
+	s.stdin.Close()
+	if !graceful {
+		_ = s.cmd.Process.Kill()
+	}
+
+	err := s.cmd.Wait()
+	if !graceful || s.cmd.ctx.Err() != nil {
+		// The shell was killed, so an error is expected.
+		return nil
+	}
+	return err
+}
+
+// SessionCmd is a command to be run in a Session. Its interface mirrors Cmd's.
+//
+// A SessionCmd cannot be reused after calling its Run method.
+type SessionCmd struct {
+	Stdout io.Writer // Writer to write stdout into
+	Stderr io.Writer // Writer to write stderr into
+
+	session *Session
+	command string
+	ctx     context.Context
+	started bool
+}
+
+// Command returns the SessionCmd struct to execute the given command in the session.
+//
+// If the context becomes done before the command completes, the whole session is
+// killed, as there is no way to interrupt a single command.
+func (s *Session) Command(ctx context.Context, cmd string) *SessionCmd {
+	if ctx == nil {
+		panic("nil Context")
+	}
+	return &SessionCmd{
+		session: s,
+		command: cmd,
+		ctx:     ctx,
+	}
+}
+
+// Run runs the command and waits for it to complete.
+//
+// The returned error is nil if the command runs and exits with a zero exit status.
+// If the command fails to run or doesn't complete successfully, the error is of type *ExitError.
+func (c *SessionCmd) Run() (err error) {
+	defer decorate.OnError(&err, "could not run command %q in session on distro %s", c.command, c.session.distro.name)
+
+	if c.started {
+		return errors.New("already started")
+	}
+	c.started = true
+
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	if c.session.err != nil {
+		return c.session.err
+	}
+
+	select {
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	default:
+	}
+
+	// We deviate from Cmd: stdout and stderr are framed by the session,
+	// so there is no need to set up any pipes.
+	stdout := c.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	var stderrTail *prefixSuffixSaver
+	stderr := c.Stderr
+	if stderr == nil {
+		stderrTail = &prefixSuffixSaver{N: 32 << 10}
+		stderr = stderrTail
+	}
+
+	exitCode, err := c.session.run(c.ctx, c.command, stdout, stderr)
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		exitErr := newExitError(exitCode, nil)
+		if stderrTail != nil {
+			exitErr.Stderr = stderrTail.Bytes()
+		}
+		return exitErr
+	}
+
+	return nil
+}
+
+// Output runs the command and returns its standard output.
+// Any returned error will usually be of type *ExitError.
+// If c.Stderr was nil, Output populates ExitError.Stderr.
+func (c *SessionCmd) Output() (out []byte, err error) {
+	// Not decorated to avoid stuttering when calling Run
+	if c.Stdout != nil {
+		return nil, fmt.Errorf("in call to SessionCmd.Output on distro %s with command %q: Stdout already set", c.session.distro.name, c.command)
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+
+	err = c.Run()
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its combined standard
+// output and standard error.
+func (c *SessionCmd) CombinedOutput() (out []byte, err error) {
+	// Not decorated to avoid stuttering when calling Run
+	if c.Stdout != nil {
+		return nil, fmt.Errorf("in call to SessionCmd.CombinedOutput on distro %s with command %q: Stdout already set", c.session.distro.name, c.command)
+	}
+	if c.Stderr != nil {
+		return nil, fmt.Errorf("in call to SessionCmd.CombinedOutput on distro %s with command %q: Stderr already set", c.session.distro.name, c.command)
+	}
+
+	// Stdout and stderr are written to from different goroutines.
+	var b syncBuffer
+	c.Stdout = &b
+	c.Stderr = &b
+	err = c.Run()
+	return b.Bytes(), err
+}
+
+// run sends the command to the shell and copies its output until the end markers
+// are found. Use with the mutex locked.
+//
+// The command is run inside a subshell via eval, so that neither syntax errors nor
+// calls to exit can bring the session down. After it exits, the shell writes the
+// marker into stderr, and the marker followed by the exit code into stdout.
+func (s *Session) run(ctx context.Context, command string, stdout, stderr io.Writer) (exitCode uint32, err error) {
+	script := fmt.Sprintf("( eval %s ) </dev/null; printf '%%s%%d\\n' '%s' \"$?\"; printf '%%s' '%s' >&2\n",
+		shellQuote(command), s.marker, s.marker)
+
+	stderrDone := make(chan error, 1)
+	go func() {
+		stderrDone <- copyUntilMarker(stderr, s.stderr, []byte(s.marker))
+	}()
+
+	type result struct {
+		exitCode uint32
+		err      error
+	}
+
+	stdoutDone := make(chan result, 1)
+	go func() {
+		if _, err := io.WriteString(s.stdin, script); err != nil {
+			stdoutDone <- result{err: fmt.Errorf("could not write into the session: %v", err)}
+			return
+		}
+
+		if err := copyUntilMarker(stdout, s.stdout, []byte(s.marker)); err != nil {
+			stdoutDone <- result{err: err}
+			return
+		}
+
+		line, err := s.stdout.ReadString('\n')
+		if err != nil {
+			stdoutDone <- result{err: fmt.Errorf("could not read exit code: %v", err)}
+			return
+		}
+
+		code, err := strconv.ParseUint(strings.TrimSpace(line), 10, 32)
+		if err != nil {
+			stdoutDone <- result{err: fmt.Errorf("could not parse exit code: %v", err)}
+			return
+		}
+
+		stdoutDone <- result{exitCode: uint32(code)}
+	}()
+
+	var errs error
+	for stdoutDone != nil || stderrDone != nil {
+		select {
+		case res := <-stdoutDone:
+			exitCode = res.exitCode
+			errs = errors.Join(errs, res.err)
+			stdoutDone = nil
+		case err := <-stderrDone:
+			errs = errors.Join(errs, err)
+			stderrDone = nil
+		case <-ctx.Done():
+			// There is no way to stop the command without stopping the session.
+			// Closing it also closes the pipes, so the goroutines will return.
+			s.err = fmt.Errorf("session killed: %v", ctx.Err())
+			_ = s.close()
+			if stdoutDone != nil {
+				<-stdoutDone
+			}
+			if stderrDone != nil {
+				<-stderrDone
+			}
+			return 0, ctx.Err()
+		}
+	}
+
+	if errs != nil {
+		s.err = fmt.Errorf("session broken: %v", errs)
+		_ = s.close()
+		return 0, errs
+	}
+
+	return exitCode, nil
+}
+
+// copyUntilMarker copies from r into w until the marker is found. The marker itself
+// is consumed, but not copied.
+func copyUntilMarker(w io.Writer, r *bufio.Reader, marker []byte) error {
+	var out []byte     // Bytes ready to be written
+	var pending []byte // Bytes that may be the beginning of the marker
+
+	flush := func() error {
+		if len(out) == 0 {
+			return nil
+		}
+		_, err := w.Write(out)
+		out = out[:0]
+		return err
+	}
+
+	for {
+		b, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return errors.Join(flush(), io.ErrUnexpectedEOF)
+		} else if err != nil {
+			return errors.Join(flush(), err)
+		}
+
+		pending = append(pending, b)
+		for len(pending) > 0 && !bytes.HasPrefix(marker, pending) {
+			out = append(out, pending[0])
+			pending = pending[1:]
+		}
+
+		if len(pending) == len(marker) {
+			return flush()
+		}
+
+		// Write as soon as we run out of buffered data, so that output is streamed.
+		if r.Buffered() == 0 || len(out) >= 32<<10 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written into concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// shellQuote quotes a string so that a POSIX shell interprets it as a single literal word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
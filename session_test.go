@@ -0,0 +1,198 @@
+package gowsl_test
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	wsl "github.com/ubuntu/gowsl"
+)
+
+func TestSessionRun(t *testing.T) {
+	skipSessionOnWindowsMock(t)
+	ctx, _ := setupBackend(t, context.Background())
+
+	d := newTestDistro(t, ctx, rootFS)
+
+	s, err := d.Session(context.Background())
+	require.NoError(t, err, "Setup: could not start session")
+	t.Cleanup(func() { s.Close() })
+
+	testCases := map[string]struct {
+		cmd string
+
+		wantStdout   string
+		wantStderr   string
+		wantExitCode uint32
+		wantAnyError bool
+	}{
+		"Success":                                 {cmd: "exit 0"},
+		"Success writing into stdout":             {cmd: "echo 'Hello!'", wantStdout: "Hello!\n"},
+		"Success writing into stderr":             {cmd: "echo 'Error!' >&2", wantStderr: "Error!\n"},
+		"Success writing without trailing EOL":    {cmd: "printf 'Hello!'", wantStdout: "Hello!"},
+		"Success writing a prefix of the marker":  {cmd: "printf '__GOWSL_'", wantStdout: "__GOWSL_"},
+		"Success writing binary data":             {cmd: `printf '\000\001\377'`, wantStdout: "\x00\x01\xff"},
+		"Success with multi-line commands":        {cmd: "echo 'Hello!'\necho 'Error!' >&2", wantStdout: "Hello!\n", wantStderr: "Error!\n"},
+		"Success with quotes in the command":      {cmd: `echo "It's" 'a "test"'`, wantStdout: "It's a \"test\"\n"},
+		"Success with commands that read stdin":   {cmd: "cat"},
+		"Success with commands that change dir":   {cmd: "cd / && pwd", wantStdout: "/\n"},
+		"Error when the exit code is non-zero":    {cmd: "exit 42", wantExitCode: 42},
+		"Error when writing into stdout and exit": {cmd: "echo 'Hello!' && echo 'Error!' >&2 && exit 42", wantStdout: "Hello!\n", wantStderr: "Error!\n", wantExitCode: 42},
+		"Error when the command has a syntax err": {cmd: "echo 'unterminated", wantAnyError: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Commands run in parallel to ensure they are properly serialized.
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			var stdout, stderr strings.Builder
+			cmd := s.Command(ctx, tc.cmd)
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+
+			err := cmd.Run()
+			if tc.wantAnyError {
+				var target *wsl.ExitError
+				require.ErrorAs(t, err, &target, "Run should have returned an ExitError")
+				return
+			}
+
+			require.Equal(t, tc.wantStdout, stdout.String(), "Unexpected contents in stdout")
+			require.Equal(t, tc.wantStderr, stderr.String(), "Unexpected contents in stderr")
+
+			if tc.wantExitCode == 0 {
+				require.NoError(t, err, "Run should have succeeded")
+				return
+			}
+
+			var target *wsl.ExitError
+			require.ErrorAs(t, err, &target, "Run should have returned an ExitError")
+			require.Equal(t, tc.wantExitCode, target.ExitCode(), "Unexpected exit code")
+			require.Equal(t, wsl.OriginLinux, target.Origin, "Unexpected exit origin")
+		})
+	}
+}
+
+func TestSessionOutput(t *testing.T) {
+	skipSessionOnWindowsMock(t)
+	ctx, _ := setupBackend(t, context.Background())
+
+	d := newTestDistro(t, ctx, rootFS)
+
+	s, err := d.Session(context.Background())
+	require.NoError(t, err, "Setup: could not start session")
+	defer s.Close()
+
+	out, err := s.Command(ctx, "echo 'Hello!' && echo 'Error!' >&2").Output()
+	require.NoError(t, err, "Output should have succeeded")
+	require.Equal(t, "Hello!\n", string(out), "Unexpected output")
+
+	out, err = s.Command(ctx, "echo 'Hello!' && echo 'Error!' >&2 && exit 42").Output()
+	require.Equal(t, "Hello!\n", string(out), "Unexpected output")
+	var target *wsl.ExitError
+	require.ErrorAs(t, err, &target, "Output should have returned an ExitError")
+	require.Equal(t, uint32(42), target.ExitCode(), "Unexpected exit code")
+	require.Equal(t, "Error!\n", string(target.Stderr), "Stderr should have been captured into the ExitError")
+
+	out, err = s.Command(ctx, "echo 'Hello!' && echo 'Error!' >&2").CombinedOutput()
+	require.NoError(t, err, "CombinedOutput should have succeeded")
+	require.ElementsMatch(t, []string{"Hello!", "Error!"}, strings.Fields(string(out)), "Unexpected combined output")
+
+	cmd := s.Command(ctx, "exit 0")
+	cmd.Stdout = &strings.Builder{}
+	_, err = cmd.Output()
+	require.Error(t, err, "Output should fail when Stdout is already set")
+
+	cmd = s.Command(ctx, "exit 0")
+	cmd.Stderr = &strings.Builder{}
+	_, err = cmd.CombinedOutput()
+	require.Error(t, err, "CombinedOutput should fail when Stderr is already set")
+
+	cmd = s.Command(ctx, "exit 0")
+	require.NoError(t, cmd.Run(), "Run should have succeeded")
+	require.Error(t, cmd.Run(), "Run should fail when called twice")
+}
+
+func TestSessionLifetime(t *testing.T) {
+	skipSessionOnWindowsMock(t)
+	ctx, _ := setupBackend(t, context.Background())
+
+	realDistro := newTestDistro(t, ctx, rootFS)
+	fakeDistro := wsl.NewDistro(ctx, uniqueDistroName(t))
+
+	testCases := map[string]struct {
+		distro        *wsl.Distro
+		cancelSession bool
+		cancelCommand bool
+		closeSession  bool
+
+		wantStartErr bool
+	}{
+		"Success running commands after the previous one": {distro: &realDistro},
+
+		"Error when the distro is not registered":                 {distro: &fakeDistro, wantStartErr: true},
+		"Error running commands after closing the session":        {distro: &realDistro, closeSession: true},
+		"Error running commands after cancelling the session":     {distro: &realDistro, cancelSession: true},
+		"Error running commands after cancelling a command in it": {distro: &realDistro, cancelCommand: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sessionCtx, cancelSession := context.WithCancel(context.Background())
+			defer cancelSession()
+
+			s, err := tc.distro.Session(sessionCtx)
+			if tc.wantStartErr {
+				require.Error(t, err, "Session should have failed to start")
+				require.ErrorIs(t, err, wsl.ErrNotExist, "Session should have returned ErrNotExist")
+				return
+			}
+			require.NoError(t, err, "Session should have started")
+			defer s.Close()
+
+			require.NoError(t, s.Command(context.Background(), "exit 0").Run(), "First command should have succeeded")
+
+			switch {
+			case tc.closeSession:
+				require.NoError(t, s.Close(), "Close should have succeeded")
+				require.NoError(t, s.Close(), "Closing twice should have succeeded")
+			case tc.cancelSession:
+				cancelSession()
+				time.Sleep(time.Second) // Give time for the process to be killed
+			case tc.cancelCommand:
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				err := s.Command(ctx, "sleep 10").Run()
+				require.ErrorIs(t, err, context.DeadlineExceeded, "Cancelled command should have returned the context's error")
+			default:
+				require.NoError(t, s.Command(context.Background(), "exit 0").Run(), "Second command should have succeeded")
+				return
+			}
+
+			err = s.Command(context.Background(), "exit 0").Run()
+			require.Error(t, err, "Command after the session is gone should have failed")
+
+			var target *wsl.ExitError
+			notErrorAsf(t, err, &target, "Command after the session is gone should not have returned an ExitError")
+		})
+	}
+}
+
+// skipSessionOnWindowsMock skips the test when using the mock on Windows, as it has no POSIX shell to
+// run session commands in.
+func skipSessionOnWindowsMock(t *testing.T) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" && wsl.MockAvailable() {
+		t.Skip("Sessions are not supported by the mock on Windows")
+	}
+}
@@ -1,37 +1,11 @@
 package gowsl
 
 import (
-	"fmt"
+	"context"
 
 	"github.com/ubuntu/decorate"
 )
 
-// ShellError returns error information when shell commands do not succeed.
-type ShellError struct {
-	exitCode uint32
-}
-
-// Error makes it so ShellError implements the error interface. In displays
-// the exit code and some auxiliary info.
-//
-// We know that exit codes above 255 come from Windows, but error codes under
-// 256 can come from both sides.
-func (err *ShellError) Error() string {
-	if int(err.exitCode) > 0xff {
-		// Windows errors are commonly displayed in HEX, so we stick to the standard
-		return fmt.Sprintf("shell failed Windows-side: exit code 0x%x", err.exitCode)
-	}
-	// Linux exit codes are always displayed in decimal
-	return fmt.Sprintf("shell launched but returned exit code %d", err.exitCode)
-}
-
-// ExitCode is a getter for the exit code of the shell when it produces.
-// Experimentally we've seen that linux produces exit codes under 255, and
-// Windows produces them above or equal to 256.
-func (err *ShellError) ExitCode() uint32 {
-	return err.exitCode
-}
-
 // ShellOption is an optional parameter for (*Distro).Shell. Use any of the
 // provided functions such as UseCWD().
 type ShellOption func(*shellOptions)
@@ -77,10 +51,16 @@ func WithCommand(cmd string) ShellOption {
 //	PS> "exit 5" | wsl.exe
 //
 // Can be used with optional helper parameters UseCWD and WithCommand.
-func (d *Distro) Shell(args ...ShellOption) (err error) {
+//
+// Once ctx is done, Shell returns without waiting for the shell to exit, with an
+// error wrapping both the context's error and ErrAbandoned when the shell could
+// not be stopped.
+//
+// If the shell returns a non-zero exit code, the error is of type *ExitError.
+func (d *Distro) Shell(ctx context.Context, args ...ShellOption) (err error) {
 	defer decorate.OnError(&err, "unsuccessful shell into distro %s", d.name)
 
-	if err := d.mustBeRegistered(); err != nil {
+	if err := d.mustBeRegistered(ctx); err != nil {
 		return err
 	}
 
@@ -92,13 +72,13 @@ func (d *Distro) Shell(args ...ShellOption) (err error) {
 		f(&options)
 	}
 
-	exitCode, err := d.backend.WslLaunchInteractive(d.Name(), options.command, options.useCWD)
+	exitCode, err := d.backend.WslLaunchInteractive(ctx, d.Name(), options.command, options.useCWD)
 	if err != nil {
 		return err
 	}
 
 	if exitCode != 0 {
-		return &ShellError{exitCode}
+		return newExitError(exitCode, nil)
 	}
 
 	return nil
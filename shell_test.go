@@ -62,9 +62,9 @@ func TestShell(t *testing.T) {
 			d := *tc.distro
 			if tc.syscallErr {
 				modifyMock(t, func(m *mock.Backend) {
-					m.WslLaunchInteractiveError = true
+					m.InjectFault("WslLaunchInteractive", mock.Fault{})
 				})
-				defer modifyMock(t, (*mock.Backend).ResetErrors)
+				defer modifyMock(t, (*mock.Backend).ClearFaults)
 			} else {
 				t.Parallel()
 			}
@@ -86,13 +86,13 @@ func TestShell(t *testing.T) {
 			// outside. This goroutine sets a fuse before shutting down the distro.
 			tk := time.AfterFunc(1*time.Minute, func() {
 				t.Logf("Command timed out")
-				err := d.Terminate()
+				err := d.Terminate(ctx)
 				if err != nil {
 					t.Log(err)
 				}
 			})
 
-			err := d.Shell(opts...)
+			err := d.Shell(ctx, opts...)
 			tk.Stop()
 
 			if !tc.wantError {
@@ -102,17 +102,18 @@ func TestShell(t *testing.T) {
 
 			require.Error(t, err, "Unexpected success after Distro.Shell")
 
-			var target *wsl.ShellError
+			var target *wsl.ExitError
 			if tc.wantExitCode == 0 {
-				notErrorAsf(t, err, &target, "unexpected ShellError, expected any other type")
+				notErrorAsf(t, err, &target, "unexpected ExitError, expected any other type")
 				if tc.wantErrNotExist {
 					require.ErrorIs(t, err, wsl.ErrNotExist, "unexpected error type for Shell, expected a ErrNotExist")
 				}
 				return
 			}
 
-			require.ErrorAs(t, err, &target, "unexpected error type, expected a ShellError")
+			require.ErrorAs(t, err, &target, "unexpected error type, expected an ExitError")
 			require.Equal(t, tc.wantExitCode, target.ExitCode(), "Unexpected value for ExitCode returned from Distro.Shell")
+			require.Equal(t, wsl.OriginLinux, target.Origin, "Unexpected origin for the ExitError returned from Distro.Shell")
 		})
 	}
 }
@@ -21,7 +21,7 @@ func installDistro(t *testing.T, ctx context.Context, distroName, location, root
 	t.Helper()
 
 	d := wsl.NewDistro(ctx, distroName)
-	err := d.Register(rootfs)
+	err := d.Register(ctx, rootfs)
 	require.NoError(t, err, "Setup: failed to register %q", distroName)
 }
 
@@ -29,7 +29,7 @@ func installDistro(t *testing.T, ctx context.Context, distroName, location, root
 //
 // allowShutdown is unused because it is not necessary in the mock.
 func uninstallDistro(distro wsl.Distro, allowShutdown bool) error {
-	r, err := distro.IsRegistered()
+	r, err := distro.IsRegistered(context.Background())
 	if err != nil {
 		return err
 	}
@@ -37,7 +37,7 @@ func uninstallDistro(distro wsl.Distro, allowShutdown bool) error {
 		return nil
 	}
 
-	return distro.Unregister()
+	return distro.Unregister(context.Background())
 }
 
 // testDistros finds all registered distros.
@@ -54,7 +54,7 @@ func defaultDistro(ctx context.Context) (string, bool, error) {
 // setDefaultDistro sets the default distro.
 func setDefaultDistro(ctx context.Context, distroName string) error {
 	d := wsl.NewDistro(ctx, distroName)
-	return d.SetAsDefault()
+	return d.SetAsDefault(ctx)
 }
 
 // wslExeGuard guard is a dummy function so that the code compiles with mocks enabled.
@@ -97,3 +97,14 @@ func setupBackend(t *testing.T, ctx context.Context) (outCtx context.Context, mo
 
 	return outCtx, modifyMock
 }
+
+// setupSandboxedBackend is like setupBackend, but the mock runs the commands that access the
+// distro's files inside of its own root directory, as they would otherwise refuse to run.
+//
+//nolint:revive // I'll put t before ctx, thank you.
+func setupSandboxedBackend(t *testing.T, ctx context.Context) context.Context {
+	t.Helper()
+	t.Parallel()
+
+	return wsl.WithMock(ctx, wslmock.New(wslmock.WithSandbox(t.TempDir())))
+}
@@ -44,7 +44,7 @@ func installDistro(t *testing.T, ctx context.Context, distroName, location, root
 func uninstallDistro(distro wsl.Distro, allowShutdown bool) (err error) {
 	defer decorate.OnError(&err, "could not uninstall %q", distro.Name())
 
-	if r, err := distro.IsRegistered(); err == nil && !r {
+	if r, err := distro.IsRegistered(context.Background()); err == nil && !r {
 		return nil
 	}
 
@@ -224,3 +224,12 @@ func setupBackend(t *testing.T, ctx context.Context) (outCtx context.Context, mo
 		t.Skip("This test is only available with the mock enabled")
 	}
 }
+
+// setupSandboxedBackend is like setupBackend. The real back-end needs no sandbox.
+//
+//nolint:revive // I'll put t before ctx, thank you.
+func setupSandboxedBackend(t *testing.T, ctx context.Context) context.Context {
+	t.Helper()
+
+	return ctx
+}